@@ -0,0 +1,298 @@
+// Package config loads the server configuration from an optional YAML or
+// TOML file and environment variable overrides.
+package config
+
+import (
+	"errors"
+	"ete3/internal/pricing"
+	"ete3/internal/refunds"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultJWTSecret is only accepted in development, see Server.Development
+const defaultJWTSecret = "your-secret-key"
+
+// Config is the runtime configuration of the server
+type Config struct {
+	Server   Server   `yaml:"server" toml:"server"`
+	Database Database `yaml:"database" toml:"database"`
+	JWT      JWT      `yaml:"jwt" toml:"jwt"`
+	Pricing  Pricing  `yaml:"pricing" toml:"pricing"`
+	Payments Payments `yaml:"payments" toml:"payments"`
+	Refunds  Refunds  `yaml:"refunds" toml:"refunds"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	// Addr is the listen address, e.g. ":8080"
+	Addr string `yaml:"addr" toml:"addr"`
+	// CORSOrigins are the origins allowed to call the API; "*" allows any
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	// DocsDir holds the API docs served under /docs
+	DocsDir string `yaml:"docs_dir" toml:"docs_dir"`
+	// Development lets the server start with the default JWT secret and
+	// without a payment webhook secret; never enable it in production
+	Development bool `yaml:"development" toml:"development"`
+}
+
+// Database configures the storage backend
+type Database struct {
+	// DSN is a postgres:// URL, or a SQLite database file or ":memory:"
+	DSN string `yaml:"dsn" toml:"dsn"`
+}
+
+// JWT configures access and refresh tokens
+type JWT struct {
+	Secret string `yaml:"secret" toml:"secret"`
+	KeyID  string `yaml:"key_id" toml:"key_id"`
+	// PreviousSecret still verifies tokens signed before a key rotation
+	PreviousSecret string   `yaml:"previous_secret" toml:"previous_secret"`
+	PreviousKeyID  string   `yaml:"previous_key_id" toml:"previous_key_id"`
+	Issuer         string   `yaml:"issuer" toml:"issuer"`
+	Audience       string   `yaml:"audience" toml:"audience"`
+	TokenTTL       Duration `yaml:"token_ttl" toml:"token_ttl"`
+	RefreshTTL     Duration `yaml:"refresh_ttl" toml:"refresh_ttl"`
+}
+
+// Pricing configures how seat prices are adjusted from the show prices
+type Pricing struct {
+	// Rules all apply to the seats of the shows they match
+	Rules []pricing.Rule `yaml:"rules" toml:"rules"`
+}
+
+// Payments configures the payment provider bookings are charged through
+type Payments struct {
+	// Provider names the gateway; only "fake", an in-process gateway for
+	// development, is available
+	Provider string `yaml:"provider" toml:"provider"`
+	// WebhookSecret verifies the provider's webhook signatures
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret"`
+}
+
+// Refunds configures how much of a cancelled booking is refunded
+type Refunds struct {
+	// Tiers refund a percentage of the payment depending on how long
+	// before the show a booking is cancelled; an empty list refunds
+	// nothing
+	Tiers []refunds.Tier `yaml:"tiers" toml:"tiers"`
+}
+
+// Duration is a time.Duration written as a string such as "15m"
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default returns the configuration used for anything not set in the file
+// or environment
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:        ":8080",
+			CORSOrigins: []string{"*"},
+			DocsDir:     "./docs",
+		},
+		Database: Database{
+			DSN: "./cinema.db",
+		},
+		JWT: JWT{
+			Secret:     defaultJWTSecret,
+			KeyID:      "default",
+			Issuer:     "cinema-booking",
+			Audience:   "cinema-api",
+			TokenTTL:   Duration(15 * time.Minute),
+			RefreshTTL: Duration(30 * 24 * time.Hour),
+		},
+		Payments: Payments{
+			Provider: "fake",
+		},
+		Refunds: Refunds{
+			Tiers: refunds.DefaultTiers(),
+		},
+	}
+}
+
+// Load reads the file at path over the defaults, applies environment
+// overrides and validates the result. An empty path skips the file. Files
+// ending in .toml are parsed as TOML, anything else as YAML.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if strings.EqualFold(filepath.Ext(path), ".toml") {
+			err = toml.Unmarshal(data, &cfg)
+		} else {
+			err = yaml.Unmarshal(data, &cfg)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// UsesDefaultSecret reports whether tokens would be signed with the
+// built-in, publicly known secret
+func (j JWT) UsesDefaultSecret() bool {
+	return j.Secret == defaultJWTSecret
+}
+
+// applyEnv overrides settings from environment variables
+func (c *Config) applyEnv() error {
+	setString(&c.Server.Addr, "SERVER_ADDR")
+	if origins := os.Getenv("CORS_ORIGINS"); origins != "" {
+		c.Server.CORSOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.Server.CORSOrigins = append(c.Server.CORSOrigins, origin)
+			}
+		}
+	}
+	setString(&c.Server.DocsDir, "DOCS_DIR")
+	if err := setBool(&c.Server.Development, "DEVELOPMENT"); err != nil {
+		return err
+	}
+
+	setString(&c.Database.DSN, "DB_PATH")
+	setString(&c.Database.DSN, "DATABASE_URL")
+
+	setString(&c.JWT.Secret, "JWT_SECRET")
+	setString(&c.JWT.KeyID, "JWT_KEY_ID")
+	setString(&c.JWT.PreviousSecret, "JWT_PREVIOUS_SECRET")
+	setString(&c.JWT.PreviousKeyID, "JWT_PREVIOUS_KEY_ID")
+	setString(&c.JWT.Issuer, "JWT_ISSUER")
+	setString(&c.JWT.Audience, "JWT_AUDIENCE")
+	if err := setDuration(&c.JWT.TokenTTL, "JWT_TOKEN_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.JWT.RefreshTTL, "JWT_REFRESH_TTL"); err != nil {
+		return err
+	}
+
+	setString(&c.Payments.Provider, "PAYMENTS_PROVIDER")
+	setString(&c.Payments.WebhookSecret, "PAYMENTS_WEBHOOK_SECRET")
+	return nil
+}
+
+// Validate reports the first setting that is invalid for any command
+func (c *Config) Validate() error {
+	if c.Server.Addr == "" {
+		return errors.New("server.addr must be set")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		return errors.New("server.cors_origins must list at least one origin")
+	}
+
+	if c.Database.DSN == "" {
+		return errors.New("database.dsn must be set")
+	}
+
+	if c.JWT.Secret == "" || c.JWT.KeyID == "" {
+		return errors.New("jwt.secret and jwt.key_id must be set")
+	}
+	if c.JWT.PreviousSecret != "" && (c.JWT.PreviousKeyID == "" || c.JWT.PreviousKeyID == c.JWT.KeyID) {
+		return errors.New("jwt.previous_key_id must be set and differ from jwt.key_id")
+	}
+	if c.JWT.Issuer == "" || c.JWT.Audience == "" {
+		return errors.New("jwt.issuer and jwt.audience must be set")
+	}
+	if c.JWT.TokenTTL <= 0 || c.JWT.RefreshTTL <= 0 {
+		return errors.New("jwt.token_ttl and jwt.refresh_ttl must be positive")
+	}
+
+	if _, err := pricing.New(c.Pricing.Rules, nil); err != nil {
+		return fmt.Errorf("pricing.rules: %w", err)
+	}
+
+	if c.Payments.Provider != "fake" {
+		return fmt.Errorf("payments.provider %q is not supported, use \"fake\"", c.Payments.Provider)
+	}
+
+	if _, err := refunds.New(c.Refunds.Tiers); err != nil {
+		return fmt.Errorf("refunds.tiers: %w", err)
+	}
+	return nil
+}
+
+// ValidateServer reports the first setting that the HTTP server can't start
+// with, beyond those Validate checks. The other commands don't serve the
+// docs, sign tokens or take payments, so they skip it.
+func (c *Config) ValidateServer() error {
+	if info, err := os.Stat(c.Server.DocsDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("server.docs_dir %q is not a directory", c.Server.DocsDir)
+	}
+	if c.JWT.UsesDefaultSecret() && !c.Server.Development {
+		return errors.New("jwt.secret must be changed from the default, or server.development enabled")
+	}
+	if c.Payments.WebhookSecret == "" && !c.Server.Development {
+		return errors.New("payments.webhook_secret must be set, or server.development enabled")
+	}
+	if c.Payments.Provider == "fake" && !c.Server.Development {
+		return errors.New("payments.provider \"fake\" charges nothing and needs server.development enabled")
+	}
+	return nil
+}
+
+// setString overrides *dst with the environment variable key if it's set
+func setString(dst *string, key string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+// setBool overrides *dst with the environment variable key if it's set
+func setBool(dst *bool, key string) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", key, value)
+	}
+	*dst = parsed
+	return nil
+}
+
+// setDuration overrides *dst with the environment variable key if it's set
+func setDuration(dst *Duration, key string) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	if err := dst.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("invalid %s %q", key, value)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes content to a file called name in a temp directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, []string{"*"}, cfg.Server.CORSOrigins)
+	assert.Equal(t, "./cinema.db", cfg.Database.DSN)
+	assert.Equal(t, Duration(15*time.Minute), cfg.JWT.TokenTTL)
+}
+
+func TestLoadFile(t *testing.T) {
+	docs := t.TempDir()
+
+	yamlPath := writeConfig(t, "config.yaml", `
+server:
+  addr: ":9090"
+  cors_origins: ["https://cinema.example.com"]
+  docs_dir: "`+docs+`"
+database:
+  dsn: "/var/lib/cinema.db"
+jwt:
+  secret: "from-yaml"
+  token_ttl: "5m"
+`)
+	tomlPath := writeConfig(t, "config.toml", `
+[server]
+addr = ":9090"
+cors_origins = ["https://cinema.example.com"]
+docs_dir = "`+docs+`"
+
+[database]
+dsn = "/var/lib/cinema.db"
+
+[jwt]
+secret = "from-yaml"
+token_ttl = "5m"
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			cfg, err := Load(path)
+			require.NoError(t, err)
+			assert.Equal(t, ":9090", cfg.Server.Addr)
+			assert.Equal(t, []string{"https://cinema.example.com"}, cfg.Server.CORSOrigins)
+			assert.Equal(t, docs, cfg.Server.DocsDir)
+			assert.Equal(t, "/var/lib/cinema.db", cfg.Database.DSN)
+			assert.Equal(t, "from-yaml", cfg.JWT.Secret)
+			assert.Equal(t, Duration(5*time.Minute), cfg.JWT.TokenTTL)
+			// Unset keys keep their defaults
+			assert.Equal(t, "cinema-api", cfg.JWT.Audience)
+		})
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+database:
+  dsn: "from-file.db"
+`)
+	t.Setenv("DB_PATH", ":memory:")
+	t.Setenv("SERVER_ADDR", "127.0.0.1:8000")
+	t.Setenv("CORS_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("JWT_SECRET", "s3cret")
+	t.Setenv("JWT_KEY_ID", "k2")
+	t.Setenv("JWT_PREVIOUS_SECRET", "old")
+	t.Setenv("JWT_PREVIOUS_KEY_ID", "k1")
+	t.Setenv("JWT_REFRESH_TTL", "24h")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, ":memory:", cfg.Database.DSN)
+	assert.Equal(t, "127.0.0.1:8000", cfg.Server.Addr)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.Server.CORSOrigins)
+	assert.Equal(t, "s3cret", cfg.JWT.Secret)
+	assert.Equal(t, "k2", cfg.JWT.KeyID)
+	assert.Equal(t, "old", cfg.JWT.PreviousSecret)
+	assert.Equal(t, "k1", cfg.JWT.PreviousKeyID)
+	assert.Equal(t, Duration(24*time.Hour), cfg.JWT.RefreshTTL)
+
+	// DATABASE_URL wins over DB_PATH
+	t.Setenv("DATABASE_URL", "postgres://cinema@localhost/cinema")
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://cinema@localhost/cinema", cfg.Database.DSN)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"Bad Duration", map[string]string{"JWT_TOKEN_TTL": "soon"}},
+		{"Negative Duration", map[string]string{"JWT_TOKEN_TTL": "-1m"}},
+		{"Previous Key Without ID", map[string]string{"JWT_PREVIOUS_SECRET": "old"}},
+		{"Previous Key Reuses ID", map[string]string{"JWT_PREVIOUS_SECRET": "old", "JWT_PREVIOUS_KEY_ID": "default"}},
+		{"No CORS Origins", map[string]string{"CORS_ORIGINS": " , "}},
+		{"Bad Development Flag", map[string]string{"DEVELOPMENT": "maybe"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load("")
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidateServer(t *testing.T) {
+	docs := t.TempDir()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Missing Docs Dir", map[string]string{"DOCS_DIR": filepath.Join(docs, "missing"), "DEVELOPMENT": "true"}, "server.docs_dir"},
+		{"Default JWT Secret", map[string]string{"PAYMENTS_WEBHOOK_SECRET": "whsec"}, "jwt.secret"},
+		{"No Webhook Secret", map[string]string{"JWT_SECRET": "s3cret"}, "payments.webhook_secret"},
+		// The fake gateway would confirm bookings without charging anyone
+		{"Fake Provider", map[string]string{"JWT_SECRET": "s3cret", "PAYMENTS_WEBHOOK_SECRET": "whsec"}, "payments.provider"},
+		{"Development", map[string]string{"DEVELOPMENT": "true"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCS_DIR", docs)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			// The other commands load the same config fine
+			cfg, err := Load("")
+			require.NoError(t, err)
+			err = cfg.ValidateServer()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	// An empty secret is rejected even in development
+	path := writeConfig(t, "config.yaml", `
+server:
+  development: true
+jwt:
+  secret: ""
+`)
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "jwt.secret")
+}
+
+func TestLoadPricingRules(t *testing.T) {
+
+	path := writeConfig(t, "config.yaml", `
+pricing:
+  rules:
+    - name: "Matinee"
+      type: "matinee"
+      before: "16:00"
+      percent: -20
+    - type: "occupancy"
+      min_occupancy: 0.8
+      percent: 15
+`)
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Pricing.Rules, 2)
+	assert.Equal(t, "16:00", cfg.Pricing.Rules[0].Before)
+	assert.Equal(t, 0.8, cfg.Pricing.Rules[1].MinOccupancy)
+
+	path = writeConfig(t, "config.yaml", `
+pricing:
+  rules:
+    - type: "full_moon"
+      percent: 10
+`)
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "pricing.rules")
+}
+
+func TestLoadRefundTiers(t *testing.T) {
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Len(t, cfg.Refunds.Tiers, 2)
+
+	path := writeConfig(t, "config.toml", `
+[[refunds.tiers]]
+name = "week"
+before = "168h"
+percent = 80
+`)
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Refunds.Tiers, 1)
+	assert.Equal(t, "168h", cfg.Refunds.Tiers[0].Before)
+
+	path = writeConfig(t, "config.yaml", `
+refunds:
+  tiers:
+    - before: "a week"
+      percent: 80
+`)
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "refunds.tiers")
+}
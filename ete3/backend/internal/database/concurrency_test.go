@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentHoldsOneWinnerPerSeat(t *testing.T) {
+	show, seats := createTestShow(t)
+	contested := seats[:10]
+
+	const attempts = 300
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := make(map[int64]int)
+	var errs []error
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			seatID := contested[i%len(contested)].ID
+			response, err := store.CreateHold(int64(1000+i), show.ID, []int64{seatID}, "", time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, ErrSeatUnavailable) {
+				return
+			}
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if response.Status == "pending" {
+				wins[seatID]++
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Empty(t, errs)
+
+	// Every seat was held exactly once
+	for _, seat := range contested {
+		assert.Equal(t, 1, wins[seat.ID], "seat %d held %d times", seat.ID, wins[seat.ID])
+	}
+
+	// The database agrees
+	for _, seat := range contested {
+		var count int
+		err := store.db.QueryRow(`
+			SELECT COUNT(*) FROM bookings
+			WHERE show_id = ? AND seat_id = ? AND status = 'pending'`, show.ID, seat.ID).Scan(&count)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	}
+}
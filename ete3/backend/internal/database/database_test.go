@@ -1,25 +1,102 @@
 package database
 
 import (
+	"database/sql"
 	"ete3/internal/models"
+	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// store is the database shared by the tests in this package
+var store *Store
+
 func TestMain(m *testing.M) {
-	// Set up test database
-	os.Setenv("DB_PATH", ":memory:")
-	InitDB()
-	
+	// TEST_DATABASE_URL runs the tests against PostgreSQL; the database is
+	// reset first. Without it they run on SQLite, and TestPostgres runs
+	// them again on a local PostgreSQL cluster when one can be started.
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		resetDatabase(dsn)
+		store = InitDB(dsn)
+		seedFixtures()
+		code := m.Run()
+		store.Close()
+		os.Exit(code)
+	}
+
+	// Set up test database in a scratch directory so the tests never touch
+	// a real cinema.db
+	dir, err := os.MkdirTemp("", "cinema-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	store = InitDB(filepath.Join(dir, "cinema.db"))
+	seedFixtures()
+
 	// Run tests
 	code := m.Run()
-	
+
 	// Clean up
+	store.Close()
+	os.RemoveAll(dir)
 	os.Exit(code)
 }
 
+// resetDatabase reverts every migration applied to the database at dsn
+func resetDatabase(dsn string) {
+	s, err := Open(dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	for {
+		m, err := s.MigrateDown()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if m == nil {
+			return
+		}
+	}
+}
+
+// seedFixtures creates what the tests rely on: theater 1 with 7 rows of 20
+// seats, IDs 1 to 140, and movie 1 showing there daily for the next week
+func seedFixtures() {
+	theater := &models.Theater{Name: "Main Theater"}
+	if err := store.CreateTheater(theater, &models.SeatMap{Rows: 7, SeatsPerRow: 20}); err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now().Add(time.Hour).Truncate(time.Hour)
+	var shows []models.Show
+	for day := 0; day < 7; day++ {
+		shows = append(shows, models.Show{TheaterID: theater.ID, StartTime: start.AddDate(0, 0, day), Price: 10})
+	}
+	movie := &models.Movie{Title: "Fixture Movie", Duration: 120}
+	if err := store.CreateMovie(movie, shows); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// insertRow runs an INSERT against the test database and returns the new ID
+func insertRow(t *testing.T, query string, args ...interface{}) int64 {
+	t.Helper()
+	tx, err := store.db.Begin()
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	id, err := tx.Insert(query, args...)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	return id
+}
+
 func TestCreateMovie(t *testing.T) {
 	movie := &models.Movie{
 		Title:       "Test Movie",
@@ -27,12 +104,12 @@ func TestCreateMovie(t *testing.T) {
 		Duration:    120,
 	}
 
-	err := CreateMovie(movie)
+	err := store.CreateMovie(movie, nil)
 	assert.NoError(t, err)
 	assert.NotZero(t, movie.ID)
 
 	// Verify movie was created
-	createdMovie, err := GetMovieByID(movie.ID)
+	createdMovie, err := store.GetMovieByID(movie.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, movie.Title, createdMovie.Title)
 	assert.Equal(t, movie.Description, createdMovie.Description)
@@ -52,70 +129,219 @@ func TestGetMovies(t *testing.T) {
 		Duration:    150,
 	}
 
-	CreateMovie(movie1)
-	CreateMovie(movie2)
+	store.CreateMovie(movie1, nil)
+	store.CreateMovie(movie2, nil)
 
 	// Get all movies
-	movies, err := GetMovies()
+	page, err := store.GetMovies(&models.MovieQuery{Limit: models.MaxMovieLimit})
 	assert.NoError(t, err)
-	assert.GreaterOrEqual(t, len(movies), 2)
+	assert.GreaterOrEqual(t, len(page.Movies), 2)
+}
+
+// upcomingShowID returns the first show of movie 1 that hasn't started yet,
+// so booking tests don't depend on the time of day they run at
+func upcomingShowID(t *testing.T) int64 {
+	t.Helper()
+	shows, err := store.GetShowsByMovie(1)
+	if err != nil || len(shows) == 0 {
+		t.Fatalf("No upcoming show for movie 1: %v", err)
+	}
+	return shows[0].ID
 }
 
 func TestCreateBooking(t *testing.T) {
 	// Create a test show and seats first
-	showID := int64(1)
+	userID := int64(1)
+	showID := upcomingShowID(t)
 	seatIDs := []int64{1, 2, 3}
 
-	response, err := CreateBooking(showID, seatIDs)
+	response, err := bookSeats(t, userID, showID, seatIDs, "")
 	assert.NoError(t, err)
 	assert.NotZero(t, response.BookingID)
 	assert.Equal(t, len(seatIDs), len(response.Seats))
 }
 
 func TestGetBookings(t *testing.T) {
-	bookings, err := GetBookings()
+	bookings, err := store.GetBookings(1)
 	assert.NoError(t, err)
 	assert.NotNil(t, bookings)
 }
 
 func TestCancelBooking(t *testing.T) {
 	// Create a test booking first
-	showID := int64(1)
-	seatIDs := []int64{1, 2, 3}
-	booking, err := CreateBooking(showID, seatIDs)
+	userID := int64(1)
+	showID := upcomingShowID(t)
+	seatIDs := []int64{4, 5, 6}
+	booking, err := bookSeats(t, userID, showID, seatIDs, "")
 	assert.NoError(t, err)
 
 	// Cancel the booking
-	err = CancelBooking(booking.BookingID)
+	_, err = store.CancelBooking(booking.BookingID, userID, nil)
 	assert.NoError(t, err)
 
 	// Verify booking is cancelled
-	bookings, err := GetBookings()
+	bookings, err := store.GetBookings(userID)
 	assert.NoError(t, err)
 	for _, b := range bookings {
-		if b.ID == booking.BookingID {
-			assert.True(t, b.Cancelled)
+		if b.OrderID == booking.BookingID {
+			assert.Equal(t, "cancelled", b.Status)
 		}
 	}
 }
 
+func TestCreateBookingValidation(t *testing.T) {
+	showID := upcomingShowID(t)
+	show, err := store.GetShowByID(showID)
+	assert.NoError(t, err)
+
+	// A seat from another theater
+	otherTheaterID := insertRow(t, `INSERT INTO theaters (name, capacity) VALUES ('Other', 1)`)
+	foreignSeatID := insertRow(t, `INSERT INTO seats (theater_id, row_number, seat_number) VALUES (?, 1, 1)`, otherTheaterID)
+
+	// A show that already started
+	d := store.db.dialect
+	pastShowID := insertRow(t, `
+		INSERT INTO shows (movie_id, theater_id, start_time, end_time, price)
+		VALUES (?, ?, `+d.addTime(d.now(), "-1", "hours")+`, `+d.addTime(d.now(), "1", "hours")+`, 10)`,
+		show.MovieID, show.TheaterID)
+
+	tests := []struct {
+		name    string
+		showID  int64
+		seatIDs []int64
+		wantErr error
+	}{
+		{"Unknown Show", 99999, []int64{11}, ErrShowNotFound},
+		{"Started Show", pastShowID, []int64{11}, ErrShowStarted},
+		{"Nonexistent Seat", showID, []int64{9999}, ErrSeatNotInTheater},
+		{"Seat In Other Theater", showID, []int64{foreignSeatID}, ErrSeatNotInTheater},
+		{"Duplicate Seat", showID, []int64{11, 12, 11}, ErrDuplicateSeatInRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bookSeats(t, 1, tt.showID, tt.seatIDs, "")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	// Nothing was booked by the rejected requests
+	available, err := store.GetAvailableSeats(showID)
+	assert.NoError(t, err)
+	ids := make([]int64, 0, len(available))
+	for _, s := range available {
+		ids = append(ids, s.ID)
+	}
+	assert.Contains(t, ids, int64(11))
+	assert.Contains(t, ids, int64(12))
+}
+
+func TestCancelBookingSeat(t *testing.T) {
+	userID := int64(1)
+	showID := upcomingShowID(t)
+	booking, err := bookSeats(t, userID, showID, []int64{7, 8}, "")
+	assert.NoError(t, err)
+	assert.Len(t, booking.Seats, 2)
+
+	// Cancel one seat; the order stays active
+	_, err = store.CancelBookingSeat(booking.BookingID, 7, userID, nil)
+	assert.NoError(t, err)
+	_, err = store.CancelBookingSeat(booking.BookingID, 7, userID, nil)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// The released seat can be booked again
+	rebooked, err := bookSeats(t, userID, showID, []int64{7}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "success", rebooked.Status)
+
+	// Cancelling the last seat cancels the order
+	_, err = store.CancelBookingSeat(booking.BookingID, 8, userID, nil)
+	assert.NoError(t, err)
+	_, err = store.CancelBooking(booking.BookingID, userID, nil)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestBookingOwnership(t *testing.T) {
+	ownerID, otherID := int64(2), int64(3)
+	booking, err := bookSeats(t, ownerID, upcomingShowID(t), []int64{10}, "")
+	assert.NoError(t, err)
+
+	owned, err := store.GetBookings(ownerID)
+	assert.NoError(t, err)
+	assert.Len(t, owned, 1)
+	assert.Equal(t, ownerID, owned[0].UserID)
+
+	others, err := store.GetBookings(otherID)
+	assert.NoError(t, err)
+	assert.Empty(t, others)
+
+	_, err = store.CancelBooking(booking.BookingID, otherID, nil)
+	assert.ErrorIs(t, err, ErrBookingNotOwned)
+
+	_, err = store.CancelBooking(booking.BookingID, ownerID, nil)
+	assert.NoError(t, err)
+}
+
 func TestGetTheaterLayout(t *testing.T) {
 	showID := int64(1)
-	layout, err := GetTheaterLayout(showID)
+	layout, err := store.GetTheaterLayout(showID)
 	assert.NoError(t, err)
 	assert.NotNil(t, layout)
 }
 
 func TestGetAvailableSeats(t *testing.T) {
 	showID := int64(1)
-	seats, err := GetAvailableSeats(showID)
+	seats, err := store.GetAvailableSeats(showID)
 	assert.NoError(t, err)
 	assert.NotNil(t, seats)
 }
 
 func TestGetShowsByMovie(t *testing.T) {
 	movieID := int64(1)
-	shows, err := GetShowsByMovie(movieID)
+	shows, err := store.GetShowsByMovie(movieID)
 	assert.NoError(t, err)
 	assert.NotNil(t, shows)
-} 
\ No newline at end of file
+}
+
+func TestSetUserRole(t *testing.T) {
+	err := store.CreateUser(&models.RegisterRequest{Username: "usher", Email: "usher@example.com", Password: "secret1"}, models.RoleCustomer)
+	assert.NoError(t, err)
+	user, err := store.GetUserByUsername("usher")
+	assert.NoError(t, err)
+
+	updated, err := store.SetUserRole(user.ID, models.RoleStaff)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleStaff, updated.Role)
+	user, err = store.GetUserByID(user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleStaff, user.Role)
+
+	_, err = store.SetUserRole(99999, models.RoleStaff)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestErrorMatching(t *testing.T) {
+	err := ErrSeatNotInTheater.WithDetails(map[string]int64{"seat_id": 5})
+	assert.ErrorIs(t, err, ErrSeatNotInTheater)
+	assert.NotErrorIs(t, err, ErrDuplicateSeatInRequest)
+	assert.Equal(t, map[string]int64{"seat_id": 5}, err.Details)
+	assert.Nil(t, ErrSeatNotInTheater.Details)
+
+	// Not-found errors still match sql.ErrNoRows
+	_, notFoundErr := store.GetMovieByID(99999)
+	assert.ErrorIs(t, notFoundErr, ErrMovieNotFound)
+	assert.ErrorIs(t, notFoundErr, sql.ErrNoRows)
+}
+
+func TestInitDBInMemory(t *testing.T) {
+	memory := InitDB(":memory:")
+	defer memory.Close()
+
+	movie := &models.Movie{Title: "In Memory", Duration: 90}
+	assert.NoError(t, memory.CreateMovie(movie, nil))
+
+	// Reads must see the same database as the write
+	found, err := memory.GetMovieByID(movie.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "In Memory", found.Title)
+}
@@ -2,147 +2,104 @@ package database
 
 import (
 	"database/sql"
+	"ete3/internal/live"
 	"ete3/internal/models"
+	"ete3/internal/pricing"
+	"ete3/internal/refunds"
 	"log"
-	"sync"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	DB *sql.DB
-	// Mutex for handling concurrent bookings
-	bookingMutex sync.Mutex
-)
+// Store implements the repository interfaces on SQLite or PostgreSQL
+type Store struct {
+	db      *conn
+	fts     bool // movies_fts indexes movie titles and descriptions
+	pricing *pricing.Engine
+	refunds *refunds.Policy
+	events  *live.Hub
+}
+
+// activeBooking matches booking rows that occupy their seat: confirmed
+// bookings and holds that haven't expired yet
+func activeBooking(d dialect) string {
+	return `(status = 'confirmed' OR (status = 'pending' AND expires_at > ` + d.now() + `))`
+}
+
+// Open opens the database at dsn without touching its schema. A
+// postgres:// URL selects PostgreSQL; anything else is a SQLite file path
+// or ":memory:".
+func Open(dsn string) (*Store, error) {
+	d := dialectFor(dsn)
 
-func InitDB() {
-	var err error
-	DB, err = sql.Open("sqlite3", "./cinema.db")
+	source := dsn
+	if d.name() == "sqlite" {
+		// Writers take the lock up front and wait for each other instead of
+		// failing with SQLITE_BUSY; seat conflicts are caught by a unique index
+		source = withSQLiteOptions(dsn, "_busy_timeout=5000&_txlock=immediate")
+	}
+	db, err := sql.Open(d.driver(), source)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	createTables()
-	migrateDatabase()
+	switch {
+	case dsn == ":memory:":
+		// Every connection to :memory: gets a database of its own
+		db.SetMaxOpenConns(1)
+	case d.name() == "sqlite":
+		// SQLite runs one writer at a time; a bounded pool keeps bursts of
+		// requests queued in Go rather than spinning on the busy timeout
+		db.SetMaxOpenConns(8)
+	default:
+		// Stay well below the server's default connection limit
+		db.SetMaxOpenConns(20)
+	}
+
+	return &Store{db: &conn{db: db, dialect: d}}, nil
 }
 
-// migrateDatabase runs any required database migrations
-func migrateDatabase() {
-	// Check if poster_url column exists in movies table
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('movies') WHERE name='poster_url'").Scan(&count)
+// InitDB opens the database at dsn and applies any pending migrations
+func InitDB(dsn string) *Store {
+	s, err := Open(dsn)
 	if err != nil {
-		log.Printf("Error checking for poster_url column: %v", err)
-		return
+		log.Fatal(err)
 	}
 
-	// If poster_url column doesn't exist, add it
-	if count == 0 {
-		log.Println("Adding poster_url column to movies table")
-		_, err := DB.Exec("ALTER TABLE movies ADD COLUMN poster_url TEXT")
-		if err != nil {
-			log.Printf("Error adding poster_url column: %v", err)
-			return
-		}
-		log.Println("poster_url column added successfully")
-	}
-}
-
-func createTables() {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS movies (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			description TEXT,
-			duration INTEGER NOT NULL,
-			genre TEXT,
-			poster_url TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE IF NOT EXISTS theaters (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			capacity INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE IF NOT EXISTS shows (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			movie_id INTEGER NOT NULL,
-			theater_id INTEGER NOT NULL,
-			start_time DATETIME NOT NULL,
-			end_time DATETIME NOT NULL,
-			price REAL NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (movie_id) REFERENCES movies(id),
-			FOREIGN KEY (theater_id) REFERENCES theaters(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS seats (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			theater_id INTEGER NOT NULL,
-			row_number INTEGER NOT NULL,
-			seat_number INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (theater_id) REFERENCES theaters(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS bookings (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			show_id INTEGER NOT NULL,
-			seat_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (show_id) REFERENCES shows(id),
-			FOREIGN KEY (seat_id) REFERENCES seats(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-	}
-
-	for _, query := range queries {
-		_, err := DB.Exec(query)
-		if err != nil {
-			log.Fatal(err)
-		}
+	applied, err := s.MigrateUp()
+	if err != nil {
+		log.Fatal("Error migrating database: ", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
 	}
+	if err := s.initSearch(); err != nil {
+		log.Fatal("Error indexing movies: ", err)
+	}
+	return s
 }
 
-// Movie operations
-func GetMovies() ([]models.Movie, error) {
-	rows, err := DB.Query("SELECT id, title, description, duration, genre, poster_url, created_at, updated_at FROM movies")
-	if err != nil {
-		return nil, err
+// withSQLiteOptions appends go-sqlite3 query options to dsn
+func withSQLiteOptions(dsn, options string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + options
 	}
-	defer rows.Close()
+	return dsn + "?" + options
+}
 
-	var movies []models.Movie
-	for rows.Next() {
-		var m models.Movie
-		err := rows.Scan(&m.ID, &m.Title, &m.Description, &m.Duration, &m.Genre, &m.PosterURL, &m.CreatedAt, &m.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		movies = append(movies, m)
-	}
-	return movies, nil
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
 }
 
 // Show operations
-func GetShowsByMovie(movieID int64) ([]models.Show, error) {
-	rows, err := DB.Query(`
+func (s *Store) GetShowsByMovie(movieID int64) ([]models.Show, error) {
+	rows, err := s.db.Query(`
 		SELECT s.id, s.movie_id, s.theater_id, s.start_time, s.end_time, s.price, s.created_at, s.updated_at
 		FROM shows s
-		WHERE s.movie_id = ? AND s.start_time > datetime('now')
+		WHERE s.movie_id = ? AND s.start_time > `+s.db.dialect.now()+`
 		ORDER BY s.start_time`, movieID)
 	if err != nil {
 		return nil, err
@@ -158,91 +115,356 @@ func GetShowsByMovie(movieID int64) ([]models.Show, error) {
 		}
 		shows = append(shows, s)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ids := make([]int64, len(shows))
+	for i := range shows {
+		ids[i] = shows[i].ID
+	}
+	prices, err := s.pricesByShow(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shows {
+		shows[i].Prices = prices[shows[i].ID]
+	}
 	return shows, nil
 }
 
+// showPrices returns the category prices of a show, or nil if it has none
+func (s *Store) showPrices(showID int64) (map[string]float64, error) {
+	rows, err := s.db.Query(`
+		SELECT category, price FROM show_prices WHERE show_id = ?`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices map[string]float64
+	for rows.Next() {
+		var category string
+		var price float64
+		if err := rows.Scan(&category, &price); err != nil {
+			return nil, err
+		}
+		if prices == nil {
+			prices = make(map[string]float64)
+		}
+		prices[category] = price
+	}
+	return prices, rows.Err()
+}
+
+// pricesByShow returns the category prices of the shows with the given IDs
+// in one query, keyed by show ID. Shows without prices have no entry.
+func (s *Store) pricesByShow(showIDs []int64) (map[int64]map[string]float64, error) {
+	prices := make(map[int64]map[string]float64)
+	if len(showIDs) == 0 {
+		return prices, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(showIDs)-1) + "?"
+	args := make([]interface{}, len(showIDs))
+	for i, id := range showIDs {
+		args[i] = id
+	}
+	rows, err := s.db.Query(`
+		SELECT show_id, category, price FROM show_prices WHERE show_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var showID int64
+		var category string
+		var price float64
+		if err := rows.Scan(&showID, &category, &price); err != nil {
+			return nil, err
+		}
+		if prices[showID] == nil {
+			prices[showID] = make(map[string]float64)
+		}
+		prices[showID][category] = price
+	}
+	return prices, rows.Err()
+}
+
 // Seat operations
-func GetAvailableSeats(showID int64) ([]models.Seat, error) {
-	rows, err := DB.Query(`
-		SELECT s.id, s.theater_id, s.row_number, s.seat_number, s.created_at, s.updated_at
+func (s *Store) GetAvailableSeats(showID int64) ([]models.Seat, error) {
+	rows, err := s.db.Query(`
+		SELECT `+seatColumns+`
 		FROM seats s
 		JOIN shows sh ON s.theater_id = sh.theater_id
 		WHERE sh.id = ? AND s.id NOT IN (
-			SELECT seat_id FROM bookings WHERE show_id = ? AND status != 'cancelled'
+			SELECT seat_id FROM bookings WHERE show_id = ? AND `+activeBooking(s.db.dialect)+`
 		)`, showID, showID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanSeats(rows)
+}
+
+// seatColumns are the columns of seats aliased s read by scanSeats
+const seatColumns = `s.id, s.theater_id, s.row_number, s.seat_number, s.category, s.created_at, s.updated_at`
+
+// scanSeats reads the seat rows selected with seatColumns
+func scanSeats(rows *sql.Rows) ([]models.Seat, error) {
 	var seats []models.Seat
 	for rows.Next() {
 		var s models.Seat
-		err := rows.Scan(&s.ID, &s.TheaterID, &s.RowNumber, &s.SeatNumber, &s.CreatedAt, &s.UpdatedAt)
+		err := rows.Scan(&s.ID, &s.TheaterID, &s.RowNumber, &s.SeatNumber, &s.Category, &s.CreatedAt, &s.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		seats = append(seats, s)
 	}
-	return seats, nil
+	return seats, rows.Err()
 }
 
-// Booking operations with concurrency control
-func CreateBooking(showID int64, seatIDs []int64) (*models.BookingResponse, error) {
-	bookingMutex.Lock()
-	defer bookingMutex.Unlock()
+// Booking operations. Double booking is prevented by the
+// idx_bookings_active_seat unique index rather than an application lock, so
+// it holds across processes. Every booking starts as a hold that is only
+// confirmed once paid for, see CreateHold and ConfirmHold.
 
-	// Start transaction
-	tx, err := DB.Begin()
+// createOrder inserts a pending order that expires after ttl, with one
+// booking row per seat priced by engine, and redeems promoCode on it unless
+// that is empty. If a seat is already taken it returns ErrSeatUnavailable.
+// The events it returns release the lapsed holds it expired on the way, for
+// the caller to publish after commit.
+func createOrder(tx *txn, engine *pricing.Engine, userID, showID int64, seatIDs []int64, promoCode string, ttl time.Duration) (*models.BookingResponse, []models.SeatEvent, error) {
+	if err := validateBooking(tx, showID, seatIDs); err != nil {
+		return nil, nil, err
+	}
+
+	released, err := expireStaleHolds(tx, showID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	quote, err := quoteSeats(tx, engine, showID, seatIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var promo *models.PromoCode
+	if promoCode != "" {
+		if promo, err = checkPromoCode(tx, promoCode, userID, showID, len(seatIDs)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	orderID, err := tx.Insert(`
+		INSERT INTO orders (user_id, show_id, status, expires_at)
+		VALUES (?, ?, 'pending', `+tx.dialect.addTime(tx.dialect.now(), "?", "seconds")+`)`,
+		userID, showID, int(ttl.Seconds()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create bookings at their quoted prices
+	for i, seatID := range seatIDs {
+		_, err := tx.Exec(`
+			INSERT INTO bookings (order_id, show_id, seat_id, user_id, status, price, expires_at)
+			VALUES (?, ?, ?, ?, 'pending', ?, (SELECT expires_at FROM orders WHERE id = ?))`,
+			orderID, showID, seatID, userID, quote.Seats[i].Price, orderID)
+		if tx.dialect.isUniqueViolation(err) {
+			return nil, nil, ErrSeatUnavailable.WithDetails(map[string]int64{"seat_id": seatID})
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if promo != nil {
+		_, err := tx.Exec(`
+			INSERT INTO promo_redemptions (order_id, promo_code_id, user_id, discount)
+			VALUES (?, ?, ?, ?)`,
+			orderID, promo.ID, userID, promo.Discount(quote.Total))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	order, err := getOrder(tx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &models.BookingResponse{
+		BookingID: order.ID,
+		Status:    "success",
+		ExpiresAt: order.ExpiresAt,
+		Seats:     order.Items,
+		PromoCode: order.PromoCode,
+		Discount:  order.Discount,
+		Total:     order.Total(),
+	}, released, nil
+}
+
+// validateBooking checks that the show exists and hasn't started and that
+// every seat is requested once and belongs to the show's theater
+func validateBooking(tx *txn, showID int64, seatIDs []int64) error {
+	seen := make(map[int64]bool, len(seatIDs))
+	for _, seatID := range seatIDs {
+		if seen[seatID] {
+			return ErrDuplicateSeatInRequest.WithDetails(map[string]int64{"seat_id": seatID})
+		}
+		seen[seatID] = true
+	}
+
+	var theaterID int64
+	var started bool
+	err := tx.QueryRow(`
+		SELECT theater_id, start_time <= `+tx.dialect.now()+`
+		FROM shows
+		WHERE id = ?`, showID).Scan(&theaterID, &started)
+	if err == sql.ErrNoRows {
+		return ErrShowNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if started {
+		return ErrShowStarted
 	}
-	defer tx.Rollback()
 
-	// Check if seats are available
 	for _, seatID := range seatIDs {
 		var count int
 		err := tx.QueryRow(`
-			SELECT COUNT(*) FROM bookings 
-			WHERE show_id = ? AND seat_id = ? AND status != 'cancelled'`, showID, seatID).Scan(&count)
+			SELECT COUNT(*) FROM seats WHERE id = ? AND theater_id = ?`, seatID, theaterID).Scan(&count)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if count > 0 {
-			return &models.BookingResponse{
-				Status:  "failed",
-				Message: "One or more seats are already booked",
-			}, nil
+		if count == 0 {
+			return ErrSeatNotInTheater.WithDetails(map[string]int64{"seat_id": seatID})
 		}
 	}
+	return nil
+}
 
-	// Create bookings
-	for _, seatID := range seatIDs {
-		_, err := tx.Exec(`
-			INSERT INTO bookings (show_id, seat_id, status)
-			VALUES (?, ?, 'confirmed')`, showID, seatID)
+// getOrder loads an order, the promo code redeemed on it and its line items
+func getOrder(tx *txn, orderID int64) (*models.Order, error) {
+	order := &models.Order{}
+	var expiresAt sql.NullTime
+	err := tx.QueryRow(`
+		SELECT o.id, o.user_id, o.show_id, o.status, o.expires_at, COALESCE(p.code, ''), COALESCE(r.discount, 0), o.created_at, o.updated_at
+		FROM orders o
+		LEFT JOIN promo_redemptions r ON r.order_id = o.id
+		LEFT JOIN promo_codes p ON p.id = r.promo_code_id
+		WHERE o.id = ?`, orderID).Scan(
+		&order.ID, &order.UserID, &order.ShowID, &order.Status, &expiresAt,
+		&order.PromoCode, &order.Discount, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		order.ExpiresAt = &expiresAt.Time
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE order_id = ?
+		ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, err := scanBooking(rows)
 		if err != nil {
 			return nil, err
 		}
+		order.Items = append(order.Items, *b)
 	}
+	return order, rows.Err()
+}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
+// expireStaleHolds marks lapsed holds for a show as expired so they no
+// longer occupy the unique index, and returns the seats it released for
+// the caller to publish after commit. The reaper does the same in bulk;
+// this covers holds that lapsed since it last ran.
+func expireStaleHolds(tx *txn, showID int64) ([]models.SeatEvent, error) {
+	// One cutoff, so the published seats are exactly the released ones
+	now := tx.dialect.timestamp(time.Now())
+	events, err := seatEvents(tx, models.SeatReleased, `
+		SELECT show_id, seat_id FROM bookings WHERE show_id = ? AND status = 'pending' AND expires_at <= ?`, showID, now)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings
+		SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+		WHERE show_id = ? AND status = 'pending' AND expires_at <= ?`, showID, now)
+	if err != nil {
 		return nil, err
 	}
 
-	return &models.BookingResponse{
-		Status:  "success",
-		Message: "Booking confirmed successfully",
-	}, nil
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+		WHERE show_id = ? AND status = 'pending' AND expires_at <= ?`, showID, now)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// bookingColumns are the columns read by scanBooking
+const bookingColumns = `id, COALESCE(order_id, 0), show_id, seat_id, COALESCE(user_id, 0), status, price, expires_at, created_at, updated_at`
+
+// scanBooking reads a booking row selected with bookingColumns
+func scanBooking(row interface{ Scan(...interface{}) error }) (*models.Booking, error) {
+	var b models.Booking
+	var expiresAt sql.NullTime
+	err := row.Scan(&b.ID, &b.OrderID, &b.ShowID, &b.SeatID, &b.UserID, &b.Status, &b.Price, &expiresAt, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		b.ExpiresAt = &expiresAt.Time
+	}
+	return &b, nil
+}
+
+// GetOrder returns an order owned by userID in any status. It returns
+// ErrBookingNotFound for unknown orders and ErrBookingNotOwned for another
+// user's.
+func (s *Store) GetOrder(orderID, userID int64) (*models.Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Only reads
+	defer tx.Rollback()
+
+	order, err := getOrder(tx, orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, ErrBookingNotOwned
+	}
+	return order, nil
 }
 
-// Get all bookings
-func GetBookings() ([]models.Booking, error) {
-	rows, err := DB.Query(`
-		SELECT id, show_id, seat_id, status, created_at, updated_at
+// GetBookings returns all bookings made by a user
+func (s *Store) GetBookings(userID int64) ([]models.Booking, error) {
+	rows, err := s.db.Query(`
+		SELECT `+bookingColumns+`
 		FROM bookings
-		ORDER BY created_at DESC`)
+		WHERE user_id = ?
+		ORDER BY created_at DESC`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -250,123 +472,189 @@ func GetBookings() ([]models.Booking, error) {
 
 	var bookings []models.Booking
 	for rows.Next() {
-		var b models.Booking
-		err := rows.Scan(&b.ID, &b.ShowID, &b.SeatID, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+		b, err := scanBooking(rows)
 		if err != nil {
 			return nil, err
 		}
-		bookings = append(bookings, b)
+		bookings = append(bookings, *b)
 	}
-	return bookings, nil
+	return bookings, rows.Err()
 }
 
-// Cancel booking
-func CancelBooking(bookingID int64) error {
-	result, err := DB.Exec(`
-		UPDATE bookings 
+// CancelBooking cancels a confirmed order owned by userID together with all
+// of its seats, and records the refund its payment is due under the
+// refund policy. Staff pass override to refund a percentage of their
+// choosing instead, which also lets them cancel other users' orders. It
+// returns ErrBookingNotFound if the order doesn't exist or is already
+// cancelled and ErrBookingNotOwned if it belongs to another user.
+func (s *Store) CancelBooking(orderID, userID int64, override *models.RefundOverride) (*models.Refund, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if override != nil {
+		// Staff cancel on behalf of the order's owner
+		if userID, err = orderOwner(tx, orderID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkOrderOwner(tx, orderID, userID); err != nil {
+		return nil, err
+	}
+
+	events, err := seatEvents(tx, models.SeatReleased, `
+		SELECT show_id, seat_id FROM bookings WHERE order_id = ? AND status = 'confirmed'`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refund, err := recordRefund(tx, s.refunds, orderID, 0, override)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings
 		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND status = 'confirmed'`, bookingID)
+		WHERE order_id = ? AND status = 'confirmed'`, orderID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rows, err := result.RowsAffected()
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.events.Publish(events...)
+	return refund, nil
+}
+
+// CancelBookingSeat cancels a single seat of a confirmed order and records
+// the refund of its share of the payment, like CancelBooking does for the
+// whole order. The order itself is cancelled once its last seat is. It
+// returns ErrSeatNotInBooking if the seat isn't an active part of the
+// order.
+func (s *Store) CancelBookingSeat(orderID, seatID, userID int64, override *models.RefundOverride) (*models.Refund, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if override != nil {
+		if userID, err = orderOwner(tx, orderID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkOrderOwner(tx, orderID, userID); err != nil {
+		return nil, err
+	}
+
+	events, err := seatEvents(tx, models.SeatReleased, `
+		SELECT show_id, seat_id FROM bookings WHERE order_id = ? AND seat_id = ? AND status = 'confirmed'`, orderID, seatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrSeatNotInBooking
+	}
+	refund, err := recordRefund(tx, s.refunds, orderID, seatID, override)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings
+		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE order_id = ? AND seat_id = ? AND status = 'confirmed'`, orderID, seatID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND NOT EXISTS (
+			SELECT 1 FROM bookings WHERE order_id = ? AND status = 'confirmed'
+		)`, orderID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.events.Publish(events...)
+	return refund, nil
+}
+
+// orderOwner returns the user orderID belongs to, or ErrBookingNotFound
+func orderOwner(tx *txn, orderID int64) (int64, error) {
+	var userID int64
+	err := tx.QueryRow(`SELECT user_id FROM orders WHERE id = ?`, orderID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrBookingNotFound
+	}
+	return userID, err
+}
+
+// checkOrderOwner returns ErrBookingNotFound unless orderID is a confirmed
+// order and ErrBookingNotOwned unless it belongs to userID
+func checkOrderOwner(tx *txn, orderID, userID int64) error {
+	var ownerID int64
+	var status string
+	err := tx.QueryRow(`
+		SELECT user_id, status FROM orders WHERE id = ?`, orderID).Scan(&ownerID, &status)
+	if err == sql.ErrNoRows {
+		return ErrBookingNotFound
+	}
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+	if ownerID != userID {
+		return ErrBookingNotOwned
+	}
+	if status != "confirmed" {
+		return ErrBookingNotFound
 	}
 	return nil
 }
 
-// CreateMovie adds a new movie to the database and creates shows with seats
-func CreateMovie(movie *models.Movie) error {
+// CreateMovie adds a new movie to the database together with shows, which
+// may be empty. The shows are scheduled as by CreateShows, all or none, and
+// get their ID, MovieID and EndTime set.
+func (s *Store) CreateMovie(movie *models.Movie, shows []models.Show) error {
 	// Start transaction
-	tx, err := DB.Begin()
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	// Insert movie
-	result, err := tx.Exec(`
+	movieID, err := tx.Insert(`
 		INSERT INTO movies (title, description, duration, genre, poster_url)
 		VALUES (?, ?, ?, ?, ?)`,
 		movie.Title, movie.Description, movie.Duration, movie.Genre, movie.PosterURL)
-
 	if err != nil {
 		return err
 	}
-
-	movieID, err := result.LastInsertId()
-	if err != nil {
+	movie.ID = movieID
+	if err := s.indexMovie(tx, movie); err != nil {
 		return err
 	}
-	movie.ID = movieID
 
-	// Create a theater if none exists
-	var theaterID int64
-	err = tx.QueryRow("SELECT id FROM theaters LIMIT 1").Scan(&theaterID)
-	if err != nil {
-		// Create a theater if none exists
-		res, err := tx.Exec(`
-			INSERT INTO theaters (name, capacity)
-			VALUES (?, ?)`,
-			"Main Theater", 140)
-		if err != nil {
-			return err
-		}
-		theaterID, err = res.LastInsertId()
-		if err != nil {
-			return err
-		}
-
-		// Create seats for the theater (A1-A20 to G1-G20)
-		for row := 0; row < 7; row++ {
-			for seat := 1; seat <= 20; seat++ {
-				_, err = tx.Exec(`
-					INSERT INTO seats (theater_id, row_number, seat_number)
-					VALUES (?, ?, ?)`,
-					theaterID, row+1, seat)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	for i := range shows {
+		shows[i].MovieID = movieID
 	}
-
-	// Create shows for the next 7 days at 6pm, 8pm, and 10pm
-	for day := 0; day < 7; day++ {
-		// 6:00 PM show
-		_, err = tx.Exec(`
-			INSERT INTO shows (movie_id, theater_id, start_time, end_time, price)
-			VALUES (?, ?, datetime('now', '+' || ? || ' days', '18:00:00'), 
-				datetime('now', '+' || ? || ' days', '18:00:00', '+' || ? || ' minutes'), ?)`,
-			movieID, theaterID, day, day, movie.Duration, 10.00)
-		if err != nil {
-			return err
-		}
-
-		// 8:00 PM show
-		_, err = tx.Exec(`
-			INSERT INTO shows (movie_id, theater_id, start_time, end_time, price)
-			VALUES (?, ?, datetime('now', '+' || ? || ' days', '20:00:00'), 
-				datetime('now', '+' || ? || ' days', '20:00:00', '+' || ? || ' minutes'), ?)`,
-			movieID, theaterID, day, day, movie.Duration, 12.00)
-		if err != nil {
-			return err
-		}
-
-		// 10:00 PM show
-		_, err = tx.Exec(`
-			INSERT INTO shows (movie_id, theater_id, start_time, end_time, price)
-			VALUES (?, ?, datetime('now', '+' || ? || ' days', '22:00:00'), 
-				datetime('now', '+' || ? || ' days', '22:00:00', '+' || ? || ' minutes'), ?)`,
-			movieID, theaterID, day, day, movie.Duration, 8.00)
-		if err != nil {
-			return err
-		}
+	if err := createShows(tx, shows); err != nil {
+		return err
 	}
 
 	// Commit transaction
@@ -377,27 +665,50 @@ func CreateMovie(movie *models.Movie) error {
 	return nil
 }
 
-// UpdateMovie updates an existing movie in the database
-func UpdateMovie(movie *models.Movie) error {
-	_, err := DB.Exec(`
+// UpdateMovie updates an existing movie in the database. It returns
+// ErrMovieNotFound if there is no such movie.
+func (s *Store) UpdateMovie(movie *models.Movie) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
 		UPDATE movies 
 		SET title = ?, description = ?, duration = ?, genre = ?, poster_url = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		movie.Title, movie.Description, movie.Duration, movie.Genre, movie.PosterURL, movie.ID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrMovieNotFound
+	}
+	if err := s.indexMovie(tx, movie); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetMovieByID retrieves a movie by its ID
-func GetMovieByID(movieID int64) (*models.Movie, error) {
+func (s *Store) GetMovieByID(movieID int64) (*models.Movie, error) {
 	movie := &models.Movie{}
-	err := DB.QueryRow(`
+	err := s.db.QueryRow(`
 		SELECT id, title, description, duration, genre, poster_url, created_at, updated_at 
 		FROM movies 
 		WHERE id = ?`, movieID).Scan(
 		&movie.ID, &movie.Title, &movie.Description, &movie.Duration,
 		&movie.Genre, &movie.PosterURL, &movie.CreatedAt, &movie.UpdatedAt)
 
+	if err == sql.ErrNoRows {
+		return nil, ErrMovieNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -406,66 +717,69 @@ func GetMovieByID(movieID int64) (*models.Movie, error) {
 }
 
 // GetShowByID retrieves a show by its ID
-func GetShowByID(showID int64) (*models.Show, error) {
+func (s *Store) GetShowByID(showID int64) (*models.Show, error) {
 	show := &models.Show{}
-	err := DB.QueryRow(`
+	err := s.db.QueryRow(`
 		SELECT id, movie_id, theater_id, start_time, end_time, price, created_at, updated_at
 		FROM shows
 		WHERE id = ?`, showID).Scan(
 		&show.ID, &show.MovieID, &show.TheaterID, &show.StartTime, &show.EndTime,
 		&show.Price, &show.CreatedAt, &show.UpdatedAt)
 
+	if err == sql.ErrNoRows {
+		return nil, ErrShowNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if show.Prices, err = s.showPrices(showID); err != nil {
+		return nil, err
+	}
 	return show, nil
 }
 
-// GetTheaterByID retrieves a theater by its ID
-func GetTheaterByID(theaterID int64) (*models.Theater, error) {
+// GetTheaterByID retrieves a theater by its ID. It returns
+// ErrTheaterNotFound if there is no such theater.
+func (s *Store) GetTheaterByID(theaterID int64) (*models.Theater, error) {
 	theater := &models.Theater{}
-	err := DB.QueryRow(`
+	err := s.db.QueryRow(`
 		SELECT id, name, capacity, created_at, updated_at
 		FROM theaters
 		WHERE id = ?`, theaterID).Scan(
 		&theater.ID, &theater.Name, &theater.Capacity, &theater.CreatedAt, &theater.UpdatedAt)
-
+	if err == sql.ErrNoRows {
+		return nil, ErrTheaterNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if theater.Categories, err = s.theaterCategories(theaterID); err != nil {
+		return nil, err
+	}
 	return theater, nil
 }
 
 // GetAllSeatsForTheater retrieves all seats for a specific theater
-func GetAllSeatsForTheater(theaterID int64) ([]models.Seat, error) {
-	rows, err := DB.Query(`
-		SELECT id, theater_id, row_number, seat_number, created_at, updated_at
-		FROM seats
-		WHERE theater_id = ?
-		ORDER BY row_number, seat_number`, theaterID)
+func (s *Store) GetAllSeatsForTheater(theaterID int64) ([]models.Seat, error) {
+	rows, err := s.db.Query(`
+		SELECT `+seatColumns+`
+		FROM seats s
+		WHERE s.theater_id = ?
+		ORDER BY s.row_number, s.seat_number`, theaterID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var seats []models.Seat
-	for rows.Next() {
-		var s models.Seat
-		err := rows.Scan(&s.ID, &s.TheaterID, &s.RowNumber, &s.SeatNumber, &s.CreatedAt, &s.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		seats = append(seats, s)
-	}
-	return seats, nil
+	return scanSeats(rows)
 }
 
 // GetBookedSeatsForShow retrieves all booked seats for a specific show
-func GetBookedSeatsForShow(showID int64) ([]models.Seat, error) {
-	rows, err := DB.Query(`
-		SELECT s.id, s.theater_id, s.row_number, s.seat_number, s.created_at, s.updated_at
+func (s *Store) GetBookedSeatsForShow(showID int64) ([]models.Seat, error) {
+	rows, err := s.db.Query(`
+		SELECT `+seatColumns+`
 		FROM seats s
 		INNER JOIN bookings b ON s.id = b.seat_id
 		WHERE b.show_id = ? AND b.status = 'confirmed'
@@ -475,40 +789,92 @@ func GetBookedSeatsForShow(showID int64) ([]models.Seat, error) {
 	}
 	defer rows.Close()
 
-	var seats []models.Seat
-	for rows.Next() {
-		var s models.Seat
-		err := rows.Scan(&s.ID, &s.TheaterID, &s.RowNumber, &s.SeatNumber, &s.CreatedAt, &s.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		seats = append(seats, s)
+	return scanSeats(rows)
+}
+
+// GetHeldSeatsForShow retrieves all seats under an unexpired hold for a specific show
+func (s *Store) GetHeldSeatsForShow(showID int64) ([]models.Seat, error) {
+	rows, err := s.db.Query(`
+		SELECT `+seatColumns+`
+		FROM seats s
+		INNER JOIN bookings b ON s.id = b.seat_id
+		WHERE b.show_id = ? AND b.status = 'pending' AND b.expires_at > `+s.db.dialect.now()+`
+		ORDER BY s.row_number, s.seat_number`, showID)
+	if err != nil {
+		return nil, err
 	}
-	return seats, nil
+	defer rows.Close()
+
+	return scanSeats(rows)
+}
+
+// GetTheaterLayout builds the seat map of a show's theater with the status
+// of every seat. It returns ErrShowNotFound if the show doesn't exist.
+func (s *Store) GetTheaterLayout(showID int64) (*models.TheaterLayout, error) {
+	show, err := s.GetShowByID(showID)
+	if err != nil {
+		return nil, err
+	}
+
+	theater, err := s.GetTheaterByID(show.TheaterID)
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := s.GetAllSeatsForTheater(show.TheaterID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookedSeats, err := s.GetBookedSeatsForShow(showID)
+	if err != nil {
+		return nil, err
+	}
+
+	heldSeats, err := s.GetHeldSeatsForShow(showID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map occupied seat IDs to the status they are shown with
+	occupied := make(map[int64]string)
+	for _, seat := range bookedSeats {
+		occupied[seat.ID] = "booked"
+	}
+	for _, seat := range heldSeats {
+		occupied[seat.ID] = "unavailable"
+	}
+
+	layout := models.NewTheaterLayout(theater, seats, occupied)
+	layout.SetPrices(show)
+	return layout, nil
 }
 
 // User operations
-func CreateUser(req *models.RegisterRequest) error {
+func (s *Store) CreateUser(req *models.RegisterRequest, role string) error {
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	_, err = DB.Exec(`
-		INSERT INTO users (username, email, password)
-		VALUES (?, ?, ?)`,
-		req.Username, req.Email, string(hashedPassword))
+	_, err = s.db.Exec(`
+		INSERT INTO users (username, email, password, role)
+		VALUES (?, ?, ?, ?)`,
+		req.Username, req.Email, string(hashedPassword), role)
+	if s.db.dialect.isUniqueViolation(err) {
+		return ErrUserExists
+	}
 
 	return err
 }
 
-func GetUserByUsername(username string) (*models.User, error) {
+func (s *Store) GetUserByUsername(username string) (*models.User, error) {
 	user := &models.User{}
-	err := DB.QueryRow(`
-		SELECT id, username, email, password 
+	err := s.db.QueryRow(`
+		SELECT id, username, email, password, role
 		FROM users 
-		WHERE username = ?`, username).Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+		WHERE username = ?`, username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
 
 	if err != nil {
 		return nil, err
@@ -516,3 +882,36 @@ func GetUserByUsername(username string) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetUserByID retrieves a user by ID
+func (s *Store) GetUserByID(userID int64) (*models.User, error) {
+	user := &models.User{}
+	err := s.db.QueryRow(`
+		SELECT id, username, email, password, role
+		FROM users
+		WHERE id = ?`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetUserRole changes the role of a user and returns the updated user. It
+// returns ErrUserNotFound if there is no such user. Access tokens already
+// issued keep the old role until they're refreshed.
+func (s *Store) SetUserRole(userID int64, role string) (*models.User, error) {
+	result, err := s.db.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrUserNotFound
+	}
+	return s.GetUserByID(userID)
+}
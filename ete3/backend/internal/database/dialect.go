@@ -0,0 +1,196 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// dialect captures the SQL differences between the supported databases.
+// Queries are written with ? placeholders and portable SQL; the few
+// engine-specific expressions come from the dialect. Both engines share
+// the INSERT ... ON CONFLICT upsert syntax, so it needs no hook here.
+type dialect interface {
+	// name identifies the dialect and its migrations directory
+	name() string
+	// driver is the database/sql driver name
+	driver() string
+	// rebind rewrites ? placeholders into the engine's form
+	rebind(query string) string
+	// now is an expression for the current time
+	now() string
+	// addTime is an expression for base moved forward by amount units, where
+	// amount is an SQL expression such as a placeholder and unit is one of
+	// "seconds", "minutes", "hours" or "days". A NULL amount yields NULL.
+	addTime(base, amount, unit string) string
+	// timestamp converts t into a query argument comparable with the
+	// engine's stored times
+	timestamp(t time.Time) interface{}
+	// returningID is appended to an INSERT to read back the new row's ID,
+	// or empty if the driver reports it through sql.Result
+	returningID() string
+	// tableExists is a query with one placeholder, the table name, that
+	// selects whether the table exists
+	tableExists() string
+	// isUniqueViolation reports whether err is a UNIQUE constraint failure
+	isUniqueViolation(err error) bool
+}
+
+// dialectFor picks the dialect from the DSN: postgres:// and postgresql://
+// URLs select PostgreSQL, anything else is a SQLite path
+func dialectFor(dsn string) dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresDialect{}
+	}
+	return sqliteDialect{}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string               { return "sqlite" }
+func (sqliteDialect) driver() string             { return "sqlite3" }
+func (sqliteDialect) rebind(query string) string { return query }
+func (sqliteDialect) now() string                { return "datetime('now')" }
+func (sqliteDialect) returningID() string        { return "" }
+
+// timestamp formats t like datetime() does, so stored times compare as text
+func (sqliteDialect) timestamp(t time.Time) interface{} {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func (sqliteDialect) addTime(base, amount, unit string) string {
+	return "datetime(" + base + ", (" + amount + ") || ' " + unit + "')"
+}
+
+func (sqliteDialect) tableExists() string {
+	return `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`
+}
+
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) name() string        { return "postgres" }
+func (postgresDialect) driver() string      { return "postgres" }
+func (postgresDialect) now() string         { return "NOW()" }
+func (postgresDialect) returningID() string { return " RETURNING id" }
+
+// rebind numbers the placeholders $1, $2, ... skipping quoted text
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) timestamp(t time.Time) interface{} {
+	return t
+}
+
+func (postgresDialect) addTime(base, amount, unit string) string {
+	return "(" + base + " + CAST(" + amount + " AS DOUBLE PRECISION) * INTERVAL '1 " + strings.TrimSuffix(unit, "s") + "')"
+}
+
+func (postgresDialect) tableExists() string {
+	return `SELECT to_regclass(?) IS NOT NULL`
+}
+
+func (postgresDialect) isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// conn is a database handle that rewrites queries for its dialect
+type conn struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.Exec(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.Query(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRow(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) Begin() (*txn, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{tx: tx, dialect: c.dialect}, nil
+}
+
+func (c *conn) Close() error {
+	return c.db.Close()
+}
+
+// txn is a transaction that rewrites queries for its dialect
+type txn struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+func (t *txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// Insert runs an INSERT statement and returns the ID of the new row
+func (t *txn) Insert(query string, args ...interface{}) (int64, error) {
+	suffix := t.dialect.returningID()
+	if suffix == "" {
+		result, err := t.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	}
+
+	var id int64
+	err := t.QueryRow(query+suffix, args...).Scan(&id)
+	return id, err
+}
+
+func (t *txn) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *txn) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectFor(t *testing.T) {
+	assert.Equal(t, "postgres", dialectFor("postgres://cinema@localhost/cinema").name())
+	assert.Equal(t, "postgres", dialectFor("postgresql://cinema@localhost/cinema").name())
+	assert.Equal(t, "sqlite", dialectFor("./cinema.db").name())
+	assert.Equal(t, "sqlite", dialectFor(":memory:").name())
+}
+
+func TestPostgresRebind(t *testing.T) {
+	d := postgresDialect{}
+	assert.Equal(t,
+		`SELECT * FROM shows WHERE movie_id = $1 AND status = '?' AND id IN ($2, $3)`,
+		d.rebind(`SELECT * FROM shows WHERE movie_id = ? AND status = '?' AND id IN (?, ?)`))
+	assert.Equal(t, "SELECT 1", d.rebind("SELECT 1"))
+}
+
+func TestAddTime(t *testing.T) {
+	assert.Equal(t,
+		`datetime(datetime('now'), (?) || ' minutes')`,
+		sqliteDialect{}.addTime(sqliteDialect{}.now(), "?", "minutes"))
+	assert.Equal(t,
+		`(NOW() + CAST(? AS DOUBLE PRECISION) * INTERVAL '1 minute')`,
+		postgresDialect{}.addTime(postgresDialect{}.now(), "?", "minutes"))
+
+	// The database does the arithmetic; check it against the test database
+	var later bool
+	d := store.db.dialect
+	err := store.db.QueryRow(`SELECT `+d.addTime(d.now(), "?", "hours")+` > `+d.addTime(d.now(), "-1", "days"), 2).Scan(&later)
+	assert.NoError(t, err)
+	assert.True(t, later)
+}
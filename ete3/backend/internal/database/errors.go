@@ -0,0 +1,154 @@
+package database
+
+import "database/sql"
+
+// Kind classifies an Error so callers can map it to a response without
+// knowing every individual error
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindPaymentRequired
+	KindUnavailable
+)
+
+// Error is a domain error with a stable machine-readable code and a message
+// that is safe to show to API clients
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// Details carries structured context such as the offending seat ID
+	Details interface{}
+	// Err is the underlying cause, if any
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any Error with the same code, so errors returned with details
+// still compare equal to the sentinel they were derived from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e carrying details
+func (e *Error) WithDetails(details interface{}) *Error {
+	copy := *e
+	copy.Details = details
+	return &copy
+}
+
+// NewError creates an Error of the given kind
+func NewError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// notFound creates a KindNotFound Error that also matches sql.ErrNoRows
+func notFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message, Err: sql.ErrNoRows}
+}
+
+var (
+	ErrMovieNotFound     = notFound("movie_not_found", "Movie not found")
+	ErrShowNotFound      = notFound("show_not_found", "Show not found")
+	ErrBookingNotFound   = notFound("booking_not_found", "Booking not found or already cancelled")
+	ErrSeatNotInBooking  = notFound("seat_not_in_booking", "Seat not found in booking or already cancelled")
+	ErrHoldNotFound      = notFound("hold_not_found", "Hold not found or no longer pending")
+	ErrTheaterNotFound   = notFound("theater_not_found", "Theater not found")
+	ErrPromoCodeNotFound = notFound("promo_code_not_found", "Promo code not found")
+	ErrPaymentNotFound   = notFound("payment_not_found", "Payment not found")
+	ErrRefundNotFound    = notFound("refund_not_found", "Refund not found")
+	ErrUserNotFound      = notFound("user_not_found", "User not found")
+
+	// ErrBookingNotOwned is returned when a user acts on someone else's booking
+	ErrBookingNotOwned = NewError(KindForbidden, "booking_not_owned", "Booking belongs to another user")
+	// ErrHoldExpired is returned when confirming a hold after its expiry
+	ErrHoldExpired = NewError(KindGone, "hold_expired", "Hold has expired")
+	// ErrSeatUnavailable is returned when a requested seat is already held or booked
+	ErrSeatUnavailable = NewError(KindConflict, "seat_unavailable", "One or more seats are already booked")
+
+	// Booking validation errors
+	ErrShowStarted            = NewError(KindConflict, "show_started", "Show has already started")
+	ErrSeatNotInTheater       = NewError(KindInvalid, "seat_not_in_theater", "Seat does not belong to the show's theater")
+	ErrDuplicateSeatInRequest = NewError(KindInvalid, "duplicate_seat", "Seat requested more than once")
+
+	// ErrTheaterInUse is returned when deleting a theater or replacing its
+	// seats while shows are scheduled in it
+	ErrTheaterInUse = NewError(KindConflict, "theater_in_use", "Theater has scheduled shows")
+	// ErrInvalidSeatMap is returned for a seat map that leaves no seats or
+	// places aisles or gaps outside its grid
+	ErrInvalidSeatMap = NewError(KindInvalid, "invalid_seat_map", "Seat map is invalid")
+
+	// ErrShowOverlap is returned when a show, including the cleaning buffer
+	// around it, overlaps another show in the same theater
+	ErrShowOverlap = NewError(KindConflict, "show_overlap", "Show overlaps another show in the theater")
+	// ErrShowInPast is returned when scheduling a show that would already
+	// have started
+	ErrShowInPast = NewError(KindInvalid, "show_in_past", "Show must start in the future")
+	// ErrShowHasBookings is returned when deleting or rescheduling a show
+	// while seats are held or booked for it
+	ErrShowHasBookings = NewError(KindConflict, "show_has_bookings", "Show has active bookings")
+	// ErrShowHasPayments is returned when deleting a show that has paid or
+	// refunded orders, even cancelled ones, so their payments and refunds
+	// stay on record
+	ErrShowHasPayments = NewError(KindConflict, "show_has_payments", "Show has paid orders")
+	// ErrUnknownSeatCategory is returned when pricing a seat category the
+	// show's theater doesn't have
+	ErrUnknownSeatCategory = NewError(KindInvalid, "unknown_seat_category", "Theater has no such seat category")
+	// ErrScheduleConflict is returned when committing a schedule some of
+	// whose shows can't be created; its details list them
+	ErrScheduleConflict = NewError(KindConflict, "schedule_conflict", "Some scheduled shows conflict with the theater's program")
+	// ErrInvalidSchedule is returned for a schedule template that can't be
+	// expanded into shows
+	ErrInvalidSchedule = NewError(KindInvalid, "invalid_schedule", "Schedule is invalid")
+
+	// ErrPromoCodeExists is returned when creating a promo code that is taken
+	ErrPromoCodeExists = NewError(KindConflict, "promo_code_exists", "Promo code already exists")
+	// ErrPromoCodeInvalid is returned when booking with a promo code that
+	// doesn't exist or is outside its validity window
+	ErrPromoCodeInvalid = NewError(KindInvalid, "invalid_promo_code", "Promo code is unknown or not valid now")
+	// ErrPromoCodeNotApplicable is returned when a booking doesn't meet a
+	// promo code's movie, show or minimum seat restriction; its details
+	// name the restriction
+	ErrPromoCodeNotApplicable = NewError(KindInvalid, "promo_code_not_applicable", "Promo code doesn't apply to this booking")
+	// ErrPromoCodeExhausted is returned when a promo code has reached its
+	// overall or per-user redemption limit
+	ErrPromoCodeExhausted = NewError(KindConflict, "promo_code_exhausted", "Promo code has reached its redemption limit")
+
+	// ErrPaymentRequired is returned when confirming a hold whose total
+	// isn't covered by a captured payment
+	ErrPaymentRequired = NewError(KindPaymentRequired, "payment_required", "Booking must be paid before it is confirmed")
+	// ErrPaymentDeclined is returned when the payment provider refuses to
+	// authorize or capture a booking's payment
+	ErrPaymentDeclined = NewError(KindPaymentRequired, "payment_declined", "Payment was declined")
+	// ErrPaymentUnavailable is returned when the payment provider doesn't
+	// answer in time
+	ErrPaymentUnavailable = NewError(KindUnavailable, "payment_unavailable", "Payment provider is not responding")
+
+	// ErrInvalidCursor is returned when listing movies with a cursor that
+	// wasn't issued for the query's sort order
+	ErrInvalidCursor = NewError(KindInvalid, "invalid_cursor", "Cursor is invalid or belongs to another sort order")
+
+	// ErrUserExists is returned when registering a taken username or email
+	ErrUserExists = NewError(KindConflict, "user_exists", "Username or email already registered")
+
+	// ErrRefreshTokenInvalid is returned for unknown or expired refresh tokens
+	ErrRefreshTokenInvalid = NewError(KindUnauthorized, "invalid_refresh_token", "Refresh token is invalid or expired")
+	// ErrRefreshTokenReused is returned when an already rotated or revoked
+	// refresh token is presented again; its whole family is revoked
+	ErrRefreshTokenReused = NewError(KindUnauthorized, "refresh_token_reused", "Refresh token reuse detected")
+)
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"database/sql"
+	"ete3/internal/models"
+	"log"
+	"math"
+	"time"
+)
+
+// CreateHold reserves seats for userID as a pending order that expires after
+// ttl unless confirmed. The returned BookingID identifies the hold. A
+// non-empty promoCode is redeemed in the same transaction, so the hold
+// fails if the code can't be redeemed, and released if the hold expires.
+func (s *Store) CreateHold(userID, showID int64, seatIDs []int64, promoCode string, ttl time.Duration) (*models.BookingResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	response, released, err := createOrder(tx, s.pricing, userID, showID, seatIDs, promoCode, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.events.Publish(append(released, models.SeatEvent{ShowID: showID, SeatIDs: seatIDs, State: models.SeatHeld})...)
+
+	response.Status = "pending"
+	response.Message = "Seats held successfully"
+	return response, nil
+}
+
+// ConfirmHold turns a pending order owned by userID into a confirmed one
+// once captured payments cover its total. It returns ErrHoldNotFound if the
+// hold doesn't exist or is no longer pending, ErrBookingNotOwned for
+// another user's hold, ErrHoldExpired if it has already expired and
+// ErrPaymentRequired if it isn't paid for.
+func (s *Store) ConfirmHold(holdID, userID int64) (*models.BookingResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkHold(tx, holdID, userID); err != nil {
+		return nil, err
+	}
+
+	order, err := getOrder(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	var paid float64
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(amount - refunded), 0)
+		FROM payments
+		WHERE order_id = ? AND status = ?`, holdID, models.PaymentCaptured).Scan(&paid)
+	if err != nil {
+		return nil, err
+	}
+	if math.Round(paid*100) < math.Round(order.Total()*100) {
+		return nil, ErrPaymentRequired
+	}
+
+	events, err := seatEvents(tx, models.SeatBooked, `
+		SELECT show_id, seat_id FROM bookings WHERE order_id = ? AND status = 'pending'`, holdID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings
+		SET status = 'confirmed', expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE order_id = ? AND status = 'pending'`, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'confirmed', expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if order, err = getOrder(tx, holdID); err != nil {
+		return nil, err
+	}
+	payment, err := capturedPayment(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.events.Publish(events...)
+
+	return &models.BookingResponse{
+		BookingID: holdID,
+		Status:    "success",
+		Message:   "Booking confirmed successfully",
+		Seats:     order.Items,
+		PromoCode: order.PromoCode,
+		Discount:  order.Discount,
+		Total:     order.Total(),
+		Payment:   payment,
+	}, nil
+}
+
+// ReleaseHold gives up a pending order owned by userID before it expires,
+// freeing its seats and any promo code redeemed on it. It fails like
+// ConfirmHold for holds that can't be confirmed.
+func (s *Store) ReleaseHold(holdID, userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkHold(tx, holdID, userID); err != nil {
+		return err
+	}
+
+	events, err := seatEvents(tx, models.SeatReleased, `
+		SELECT show_id, seat_id FROM bookings WHERE order_id = ? AND status = 'pending'`, holdID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings
+		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE order_id = ? AND status = 'pending'`, holdID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, holdID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.events.Publish(events...)
+	return nil
+}
+
+// checkHold returns ErrHoldNotFound, ErrBookingNotOwned or ErrHoldExpired
+// unless holdID is a live pending order owned by userID
+func checkHold(tx *txn, holdID, userID int64) error {
+	var ownerID int64
+	var status string
+	var expired bool
+	err := tx.QueryRow(`
+		SELECT user_id, status, COALESCE(expires_at <= `+tx.dialect.now()+`, FALSE)
+		FROM orders
+		WHERE id = ?`, holdID).Scan(&ownerID, &status, &expired)
+	if err == sql.ErrNoRows {
+		return ErrHoldNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerID != userID {
+		return ErrBookingNotOwned
+	}
+	if status == "expired" || (status == "pending" && expired) {
+		return ErrHoldExpired
+	}
+	if status != "pending" {
+		return ErrHoldNotFound
+	}
+	return nil
+}
+
+// ReleaseExpiredHolds marks pending orders past their expiry as expired
+// and returns how many seats were released
+func (s *Store) ReleaseExpiredHolds() (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// One cutoff, so the published seats are exactly the released ones
+	now := tx.dialect.timestamp(time.Now())
+	events, err := seatEvents(tx, models.SeatReleased, `
+		SELECT show_id, seat_id FROM bookings WHERE status = 'pending' AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`
+		UPDATE bookings
+		SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.events.Publish(events...)
+	return released, nil
+}
+
+// StartHoldReaper releases expired holds every interval until the returned
+// stop function is called
+func (s *Store) StartHoldReaper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				released, err := s.ReleaseExpiredHolds()
+				if err != nil {
+					log.Printf("Error releasing expired holds: %v", err)
+				} else if released > 0 {
+					log.Printf("Released %d expired seat holds", released)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestShow creates a theater of its own with a movie showing there
+// tomorrow, and returns the show with the theater's seats. The fixtures in
+// database_test.go stay untouched.
+func createTestShow(t *testing.T) (*models.Show, []models.Seat) {
+	t.Helper()
+	theater := &models.Theater{Name: "Hold Test"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 5, SeatsPerRow: 10}))
+
+	movie := &models.Movie{Title: "Hold Test", Duration: 90}
+	shows := []models.Show{{TheaterID: theater.ID, StartTime: time.Now().Add(24 * time.Hour), Price: 10}}
+	require.NoError(t, store.CreateMovie(movie, shows))
+
+	show := shows[0]
+	seats, err := store.GetAllSeatsForTheater(show.TheaterID)
+	require.NoError(t, err)
+	require.NotEmpty(t, seats)
+	return &show, seats
+}
+
+func TestCreateAndConfirmHold(t *testing.T) {
+	show, seats := createTestShow(t)
+	userID := int64(20)
+	seatIDs := []int64{seats[0].ID, seats[1].ID}
+
+	hold, err := store.CreateHold(userID, show.ID, seatIDs, "", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", hold.Status)
+	assert.NotZero(t, hold.BookingID)
+	require.NotNil(t, hold.ExpiresAt)
+	assert.True(t, hold.ExpiresAt.After(time.Now()))
+
+	// Held seats are no longer available
+	available, err := store.GetAvailableSeats(show.ID)
+	require.NoError(t, err)
+	for _, seat := range available {
+		assert.NotContains(t, seatIDs, seat.ID)
+	}
+
+	layout, err := store.GetTheaterLayout(show.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "unavailable", layout.Layout[seats[0].RowNumber-1][seats[0].SeatNumber-1].Status)
+
+	// Nobody else can take them
+	_, err = store.CreateHold(userID+1, show.ID, seatIDs[:1], "", 10*time.Minute)
+	assert.ErrorIs(t, err, ErrSeatUnavailable)
+
+	_, err = store.ConfirmHold(hold.BookingID, userID+1)
+	assert.ErrorIs(t, err, ErrBookingNotOwned)
+
+	// It has to be paid in full first
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	assert.ErrorIs(t, err, ErrPaymentRequired)
+	payHold(t, hold.BookingID, hold.Total-1)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	assert.ErrorIs(t, err, ErrPaymentRequired)
+	payHold(t, hold.BookingID, 1)
+
+	confirmed, err := store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "success", confirmed.Status)
+
+	layout, err = store.GetTheaterLayout(show.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "booked", layout.Layout[seats[0].RowNumber-1][seats[0].SeatNumber-1].Status)
+
+	// A hold can only be confirmed once
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	assert.Error(t, err)
+}
+
+func TestReleaseHold(t *testing.T) {
+	show, seats := createTestShow(t)
+	userID := int64(22)
+	seatIDs := []int64{seats[3].ID}
+
+	hold, err := store.CreateHold(userID, show.ID, seatIDs, "", 10*time.Minute)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, store.ReleaseHold(hold.BookingID, userID+1), ErrBookingNotOwned)
+	require.NoError(t, store.ReleaseHold(hold.BookingID, userID))
+
+	order, err := store.GetOrder(hold.BookingID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", order.Status)
+
+	// The seat is free again and the released hold can't be confirmed
+	_, err = store.CreateHold(userID+1, show.ID, seatIDs, "", 10*time.Minute)
+	assert.NoError(t, err)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	assert.Error(t, err)
+}
+
+func TestExpiredHoldIsReleased(t *testing.T) {
+	show, seats := createTestShow(t)
+	userID := int64(21)
+	seatIDs := []int64{seats[2].ID}
+
+	hold, err := store.CreateHold(userID, show.ID, seatIDs, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, "pending", hold.Status)
+
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	assert.ErrorIs(t, err, ErrHoldExpired)
+
+	released, err := store.ReleaseExpiredHolds()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, released, int64(1))
+
+	// The seat can be booked again
+	response, err := bookSeats(t, userID+1, show.ID, seatIDs, "")
+	require.NoError(t, err)
+	assert.Equal(t, "success", response.Status)
+}
+
+// payHold records a captured payment of amount for a hold
+func payHold(t *testing.T, orderID int64, amount float64) {
+	t.Helper()
+	payment := &models.Payment{
+		OrderID:     orderID,
+		Provider:    "test",
+		ProviderRef: fmt.Sprintf("test_%d_%v", orderID, amount),
+		Amount:      amount,
+		Status:      models.PaymentCaptured,
+	}
+	require.NoError(t, store.CreatePayment(payment))
+}
+
+// bookSeats books seats the way customers do: a hold, a captured payment
+// of its total and the confirmation
+func bookSeats(t *testing.T, userID, showID int64, seatIDs []int64, promoCode string) (*models.BookingResponse, error) {
+	t.Helper()
+	hold, err := store.CreateHold(userID, showID, seatIDs, promoCode, 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	payHold(t, hold.BookingID, hold.Total)
+	return store.ConfirmHold(hold.BookingID, userID)
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"ete3/internal/live"
+	"ete3/internal/models"
+)
+
+// SetSeatEvents makes bookings, holds and cancellations publish the new
+// state of their seats to hub once they commit
+func (s *Store) SetSeatEvents(hub *live.Hub) {
+	s.events = hub
+}
+
+// seatEvents reads the show_id and seat_id pairs query selects from
+// bookings into one event per show with the given state
+func seatEvents(tx *txn, state, query string, args ...interface{}) ([]models.SeatEvent, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.SeatEvent
+	byShow := make(map[int64]int)
+	for rows.Next() {
+		var showID, seatID int64
+		if err := rows.Scan(&showID, &seatID); err != nil {
+			return nil, err
+		}
+		i, ok := byShow[showID]
+		if !ok {
+			i = len(events)
+			byShow[showID] = i
+			events = append(events, models.SeatEvent{ShowID: showID, State: state})
+		}
+		events[i].SeatIDs = append(events[i].SeatIDs, seatID)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"ete3/internal/live"
+	"ete3/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeatEvents(t *testing.T) {
+	hub := live.NewHub(16)
+	store.SetSeatEvents(hub)
+	t.Cleanup(func() { store.SetSeatEvents(nil) })
+
+	show, seats := createTestShow(t)
+	sub := hub.Subscribe(show.ID)
+	defer sub.Close()
+	userID := int64(40)
+
+	expect := func(state string, seatIDs ...int64) {
+		t.Helper()
+		select {
+		case event := <-sub.Events():
+			assert.Equal(t, models.SeatEvent{ShowID: show.ID, SeatIDs: seatIDs, State: state}, event)
+		default:
+			t.Fatalf("No %s event for seats %v", state, seatIDs)
+		}
+	}
+
+	hold, err := store.CreateHold(userID, show.ID, []int64{seats[0].ID, seats[1].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+	expect(models.SeatHeld, seats[0].ID, seats[1].ID)
+	payHold(t, hold.BookingID, hold.Total)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+	expect(models.SeatBooked, seats[0].ID, seats[1].ID)
+
+	_, err = store.CancelBookingSeat(hold.BookingID, seats[0].ID, userID, nil)
+	require.NoError(t, err)
+	expect(models.SeatReleased, seats[0].ID)
+	_, err = store.CancelBooking(hold.BookingID, userID, nil)
+	require.NoError(t, err)
+	expect(models.SeatReleased, seats[1].ID)
+
+	booking, err := bookSeats(t, userID, show.ID, []int64{seats[2].ID}, "")
+	require.NoError(t, err)
+	expect(models.SeatHeld, seats[2].ID)
+	expect(models.SeatBooked, seats[2].ID)
+
+	hold, err = store.CreateHold(userID, show.ID, []int64{seats[3].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+	expect(models.SeatHeld, seats[3].ID)
+	require.NoError(t, store.ReleaseHold(hold.BookingID, userID))
+	expect(models.SeatReleased, seats[3].ID)
+
+	_, err = store.CreateHold(userID, show.ID, []int64{seats[4].ID}, "", 0)
+	require.NoError(t, err)
+	expect(models.SeatHeld, seats[4].ID)
+	_, err = store.ReleaseExpiredHolds()
+	require.NoError(t, err)
+	expect(models.SeatReleased, seats[4].ID)
+
+	// Booking expires the show's lapsed holds on the way
+	_, err = store.CreateHold(userID, show.ID, []int64{seats[5].ID}, "", 0)
+	require.NoError(t, err)
+	expect(models.SeatHeld, seats[5].ID)
+	_, err = store.CreateHold(userID, show.ID, []int64{seats[6].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+	expect(models.SeatReleased, seats[5].ID)
+	expect(models.SeatHeld, seats[6].ID)
+
+	// Failed bookings change nothing
+	_, err = bookSeats(t, userID, show.ID, []int64{seats[2].ID}, "")
+	assert.ErrorIs(t, err, ErrSeatUnavailable)
+	assert.Empty(t, sub.Events())
+
+	_, err = store.CancelBooking(booking.BookingID, userID, nil)
+	require.NoError(t, err)
+	expect(models.SeatReleased, seats[2].ID)
+}
@@ -0,0 +1,327 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the schema migrations in a directory per dialect,
+// named NNNN_description.up.sql and NNNN_description.down.sql
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches migration file names
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports when a migration was applied; AppliedAt is nil
+// while it is pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations for d in version order
+func loadMigrations(d dialect) ([]Migration, error) {
+	dir := "migrations/" + d.name()
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := migrationFiles.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in version order, each in its
+// own transaction, and returns the ones it applied
+func (s *Store) MigrateUp() ([]Migration, error) {
+	migrations, err := loadMigrations(s.db.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureMigrationsTable(migrations[0]); err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := s.runMigration(m, m.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the most recently applied migration and returns it,
+// or nil if no migration is applied
+func (s *Store) MigrateDown() (*Migration, error) {
+	migrations, err := loadMigrations(s.db.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureMigrationsTable(migrations[0]); err != nil {
+		return nil, err
+	}
+
+	var version int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, nil
+	}
+
+	for _, m := range migrations {
+		if m.Version == version {
+			return &m, s.runMigration(m, m.Down, false)
+		}
+	}
+	return nil, fmt.Errorf("applied migration %04d is not known to this build", version)
+}
+
+// MigrationStatus lists every known migration with the time it was applied
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.db.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureMigrationsTable(migrations[0]); err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// runMigration executes script and records m as applied or reverted in the
+// same transaction
+func (s *Store) runMigration(m Migration, script string, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Scripts run as they are: they hold several statements and no
+	// placeholders
+	if _, err := tx.tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedMigrations maps applied versions to when they were applied
+func (s *Store) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := s.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// ensureMigrationsTable creates schema_migrations if needed. A database
+// that has tables but no schema_migrations predates versioned migrations;
+// it is upgraded to the initial schema and recorded at that version.
+func (s *Store) ensureMigrationsTable(initial Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tracked, legacy bool
+	if err := tx.QueryRow(tx.dialect.tableExists(), "schema_migrations").Scan(&tracked); err != nil {
+		return err
+	}
+	if tracked {
+		return nil
+	}
+	if err := tx.QueryRow(tx.dialect.tableExists(), "movies").Scan(&legacy); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		// Only SQLite databases predate versioned migrations
+		if tx.dialect.name() != "sqlite" {
+			return fmt.Errorf("database has tables but no schema_migrations")
+		}
+		if err := adoptLegacySchema(tx, initial); err != nil {
+			return fmt.Errorf("upgrading pre-migration schema: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// adoptLegacySchema adds the columns that databases created before
+// versioned migrations may lack, creates the missing tables from initial
+// and records it as applied
+func adoptLegacySchema(tx *txn, initial Migration) error {
+	columns := []struct{ table, column, definition string }{
+		{"movies", "poster_url", "TEXT"},
+		{"bookings", "user_id", "INTEGER REFERENCES users(id)"},
+		{"users", "role", "TEXT NOT NULL DEFAULT 'customer'"},
+		{"bookings", "expires_at", "DATETIME"},
+		{"bookings", "order_id", "INTEGER REFERENCES orders(id)"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.tx.Exec(initial.Up); err != nil {
+		return err
+	}
+
+	// Movies added before poster_url existed have no poster
+	if _, err := tx.Exec(`UPDATE movies SET poster_url = '' WHERE poster_url IS NULL`); err != nil {
+		return err
+	}
+
+	// Wrap each booking made before orders existed in an order of its own
+	rows, err := tx.Query(`SELECT id FROM bookings WHERE order_id IS NULL`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		orderID, err := tx.Insert(`
+			INSERT INTO orders (user_id, show_id, status, expires_at, created_at, updated_at)
+			SELECT COALESCE(user_id, 0), show_id, status, expires_at, created_at, updated_at
+			FROM bookings WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE bookings SET order_id = ? WHERE id = ?`, orderID, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, initial.Version, initial.Name)
+	return err
+}
+
+// addColumnIfMissing adds a column to a table created by an older schema.
+// Missing tables are left for the initial migration to create.
+func addColumnIfMissing(tx *txn, table, column, definition string) error {
+	var columns, found int
+	err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(name = ?), 0) FROM pragma_table_info(?)`, column, table).Scan(&columns, &found)
+	if err != nil || columns == 0 || found > 0 {
+		return err
+	}
+
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
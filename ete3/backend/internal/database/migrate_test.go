@@ -0,0 +1,130 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tableExists reports whether the store's database has the named table
+func tableExists(t *testing.T, s *Store, name string) bool {
+	t.Helper()
+	var exists bool
+	err := s.db.QueryRow(s.db.dialect.tableExists(), name).Scan(&exists)
+	require.NoError(t, err)
+	return exists
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	migrations, err := loadMigrations(s.db.dialect)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, 1, migrations[0].Version)
+
+	applied, err := s.MigrateUp()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+	assert.True(t, tableExists(t, s, "bookings"))
+
+	// Applying again is a no-op
+	applied, err = s.MigrateUp()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := s.MigrationStatus()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, "migration %d should be applied", status.Version)
+	}
+
+	// Revert everything, newest first
+	for i := len(migrations) - 1; i >= 0; i-- {
+		reverted, err := s.MigrateDown()
+		require.NoError(t, err)
+		require.NotNil(t, reverted)
+		assert.Equal(t, migrations[i].Version, reverted.Version)
+	}
+	assert.False(t, tableExists(t, s, "bookings"))
+
+	reverted, err := s.MigrateDown()
+	assert.NoError(t, err)
+	assert.Nil(t, reverted)
+
+	statuses, err = s.MigrationStatus()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt, "migration %d should be pending", status.Version)
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqlite, err := loadMigrations(sqliteDialect{})
+	require.NoError(t, err)
+	postgres, err := loadMigrations(postgresDialect{})
+	require.NoError(t, err)
+
+	require.Equal(t, len(sqlite), len(postgres))
+	for i := range sqlite {
+		assert.Equal(t, sqlite[i].Version, postgres[i].Version)
+		assert.Equal(t, sqlite[i].Name, postgres[i].Name)
+	}
+}
+
+func TestMigrateAdoptsLegacySchema(t *testing.T) {
+	s, err := Open(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	// The schema and data of a database created before migrations existed
+	_, err = s.db.Exec(`
+		CREATE TABLE movies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT,
+			duration INTEGER NOT NULL,
+			genre TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE bookings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			show_id INTEGER NOT NULL,
+			seat_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL UNIQUE,
+			email TEXT NOT NULL UNIQUE,
+			password TEXT NOT NULL
+		);
+		INSERT INTO movies (title, description, duration, genre) VALUES ('Legacy', '', 100, '');
+		INSERT INTO bookings (show_id, seat_id, status) VALUES (1, 1, 'confirmed'), (1, 2, 'confirmed');`)
+	require.NoError(t, err)
+
+	_, err = s.MigrateUp()
+	require.NoError(t, err)
+
+	movie, err := s.GetMovieByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", movie.Title)
+	assert.True(t, tableExists(t, s, "refresh_tokens"))
+
+	// Every legacy booking gets an order of its own
+	var unlinked, orders int
+	require.NoError(t, s.db.QueryRow(`SELECT COUNT(*) FROM bookings WHERE order_id IS NULL`).Scan(&unlinked))
+	require.NoError(t, s.db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&orders))
+	assert.Zero(t, unlinked)
+	assert.Equal(t, 2, orders)
+
+	statuses, err := s.MigrationStatus()
+	require.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+}
@@ -0,0 +1,90 @@
+-- PostgreSQL counterpart of the SQLite initial schema. user_id columns are
+-- not foreign keys: SQLite never enforced them, and orders carried over
+-- from before accounts existed belong to user 0.
+
+CREATE TABLE movies (
+	id BIGSERIAL PRIMARY KEY,
+	title TEXT NOT NULL,
+	description TEXT,
+	duration INTEGER NOT NULL,
+	genre TEXT,
+	poster_url TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE theaters (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	capacity INTEGER NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE shows (
+	id BIGSERIAL PRIMARY KEY,
+	movie_id BIGINT NOT NULL REFERENCES movies(id),
+	theater_id BIGINT NOT NULL REFERENCES theaters(id),
+	start_time TIMESTAMPTZ NOT NULL,
+	end_time TIMESTAMPTZ NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE seats (
+	id BIGSERIAL PRIMARY KEY,
+	theater_id BIGINT NOT NULL REFERENCES theaters(id),
+	row_number INTEGER NOT NULL,
+	seat_number INTEGER NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE users (
+	id BIGSERIAL PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'customer',
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE orders (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	show_id BIGINT NOT NULL REFERENCES shows(id),
+	status TEXT NOT NULL,
+	expires_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE bookings (
+	id BIGSERIAL PRIMARY KEY,
+	order_id BIGINT REFERENCES orders(id),
+	show_id BIGINT NOT NULL REFERENCES shows(id),
+	seat_id BIGINT NOT NULL REFERENCES seats(id),
+	user_id BIGINT,
+	status TEXT NOT NULL,
+	expires_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one pending or confirmed booking per seat and show
+CREATE UNIQUE INDEX idx_bookings_active_seat
+	ON bookings(show_id, seat_id) WHERE status IN ('pending', 'confirmed');
+
+CREATE TABLE refresh_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP COLUMN price;
+DROP TABLE IF EXISTS show_prices;
+ALTER TABLE seats DROP COLUMN category;
+DROP TABLE IF EXISTS seat_categories;
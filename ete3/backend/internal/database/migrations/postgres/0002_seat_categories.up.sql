@@ -0,0 +1,23 @@
+-- Seat categories per theater and seat prices per show and category. Every
+-- seat starts out in the implicit standard category "A", and every booking
+-- is priced at its show's price.
+
+CREATE TABLE seat_categories (
+	theater_id BIGINT NOT NULL REFERENCES theaters(id),
+	code TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (theater_id, code)
+);
+
+ALTER TABLE seats ADD COLUMN category TEXT NOT NULL DEFAULT 'A';
+
+CREATE TABLE show_prices (
+	show_id BIGINT NOT NULL REFERENCES shows(id),
+	category TEXT NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (show_id, category)
+);
+
+ALTER TABLE bookings ADD COLUMN price DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE bookings SET price = shows.price FROM shows WHERE shows.id = bookings.show_id;
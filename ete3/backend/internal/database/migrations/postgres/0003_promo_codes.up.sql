@@ -0,0 +1,30 @@
+-- Promo codes and the orders they were redeemed on. An order carries at
+-- most one code, and its discount comes off the order total. A code's
+-- show_id is not a foreign key, so deleting the show leaves the code
+-- restricted to a show that no longer exists.
+
+CREATE TABLE promo_codes (
+	id BIGSERIAL PRIMARY KEY,
+	code TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL,
+	value DOUBLE PRECISION NOT NULL,
+	min_seats INTEGER NOT NULL DEFAULT 0,
+	movie_id BIGINT REFERENCES movies(id),
+	show_id BIGINT,
+	valid_from TIMESTAMPTZ,
+	valid_until TIMESTAMPTZ,
+	max_redemptions INTEGER NOT NULL DEFAULT 0,
+	max_per_user INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE promo_redemptions (
+	order_id BIGINT PRIMARY KEY REFERENCES orders(id),
+	promo_code_id BIGINT NOT NULL REFERENCES promo_codes(id),
+	user_id BIGINT NOT NULL,
+	discount DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_promo_redemptions_code ON promo_redemptions(promo_code_id, user_id);
@@ -0,0 +1,19 @@
+-- Payments taken for orders. An order may have several attempts, of which
+-- at most one is captured. Orders confirmed before payments existed have
+-- none.
+
+CREATE TABLE payments (
+	id BIGSERIAL PRIMARY KEY,
+	order_id BIGINT NOT NULL REFERENCES orders(id),
+	provider TEXT NOT NULL,
+	provider_ref TEXT,
+	amount DOUBLE PRECISION NOT NULL,
+	refunded DOUBLE PRECISION NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	error TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_order ON payments(order_id);
+CREATE UNIQUE INDEX idx_payments_provider_ref ON payments(provider, provider_ref);
@@ -0,0 +1,19 @@
+-- Refunds of cancelled orders. Each cancellation records one, even when
+-- nothing is due, so overrides by staff keep an audit trail.
+
+CREATE TABLE refunds (
+	id BIGSERIAL PRIMARY KEY,
+	order_id BIGINT NOT NULL REFERENCES orders(id),
+	payment_id BIGINT REFERENCES payments(id),
+	amount DOUBLE PRECISION NOT NULL,
+	percent DOUBLE PRECISION NOT NULL,
+	tier TEXT,
+	reason TEXT,
+	issued_by BIGINT,
+	status TEXT NOT NULL,
+	error TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refunds_order ON refunds(order_id);
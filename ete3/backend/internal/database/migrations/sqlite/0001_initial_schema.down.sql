@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP INDEX IF EXISTS idx_bookings_active_seat;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS seats;
+DROP TABLE IF EXISTS shows;
+DROP TABLE IF EXISTS theaters;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS movies;
@@ -0,0 +1,99 @@
+-- Schema as of the introduction of versioned migrations. Tables use IF NOT
+-- EXISTS so databases created before then can adopt this version.
+
+CREATE TABLE IF NOT EXISTS movies (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	description TEXT,
+	duration INTEGER NOT NULL,
+	genre TEXT,
+	poster_url TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS theaters (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	capacity INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shows (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	movie_id INTEGER NOT NULL,
+	theater_id INTEGER NOT NULL,
+	start_time DATETIME NOT NULL,
+	end_time DATETIME NOT NULL,
+	price REAL NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (movie_id) REFERENCES movies(id),
+	FOREIGN KEY (theater_id) REFERENCES theaters(id)
+);
+
+CREATE TABLE IF NOT EXISTS seats (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	theater_id INTEGER NOT NULL,
+	row_number INTEGER NOT NULL,
+	seat_number INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (theater_id) REFERENCES theaters(id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	show_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	expires_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (show_id) REFERENCES shows(id)
+);
+
+CREATE TABLE IF NOT EXISTS bookings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER,
+	show_id INTEGER NOT NULL,
+	seat_id INTEGER NOT NULL,
+	user_id INTEGER,
+	status TEXT NOT NULL,
+	expires_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id),
+	FOREIGN KEY (show_id) REFERENCES shows(id),
+	FOREIGN KEY (seat_id) REFERENCES seats(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- At most one pending or confirmed booking per seat and show
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_seat
+	ON bookings(show_id, seat_id) WHERE status IN ('pending', 'confirmed');
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'customer',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at DATETIME NOT NULL,
+	revoked_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
@@ -0,0 +1,25 @@
+-- Seat categories per theater and seat prices per show and category. Every
+-- seat starts out in the implicit standard category "A", and every booking
+-- is priced at its show's price.
+
+CREATE TABLE seat_categories (
+	theater_id INTEGER NOT NULL,
+	code TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (theater_id, code),
+	FOREIGN KEY (theater_id) REFERENCES theaters(id)
+);
+
+ALTER TABLE seats ADD COLUMN category TEXT NOT NULL DEFAULT 'A';
+
+CREATE TABLE show_prices (
+	show_id INTEGER NOT NULL,
+	category TEXT NOT NULL,
+	price REAL NOT NULL,
+	PRIMARY KEY (show_id, category),
+	FOREIGN KEY (show_id) REFERENCES shows(id)
+);
+
+ALTER TABLE bookings ADD COLUMN price REAL NOT NULL DEFAULT 0;
+
+UPDATE bookings SET price = COALESCE((SELECT price FROM shows WHERE shows.id = bookings.show_id), 0);
@@ -0,0 +1,33 @@
+-- Promo codes and the orders they were redeemed on. An order carries at
+-- most one code, and its discount comes off the order total. A code's
+-- show_id is not a foreign key, so deleting the show leaves the code
+-- restricted to a show that no longer exists.
+
+CREATE TABLE promo_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL,
+	value REAL NOT NULL,
+	min_seats INTEGER NOT NULL DEFAULT 0,
+	movie_id INTEGER,
+	show_id INTEGER,
+	valid_from DATETIME,
+	valid_until DATETIME,
+	max_redemptions INTEGER NOT NULL DEFAULT 0,
+	max_per_user INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (movie_id) REFERENCES movies(id)
+);
+
+CREATE TABLE promo_redemptions (
+	order_id INTEGER PRIMARY KEY,
+	promo_code_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	discount REAL NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id),
+	FOREIGN KEY (promo_code_id) REFERENCES promo_codes(id)
+);
+
+CREATE INDEX idx_promo_redemptions_code ON promo_redemptions(promo_code_id, user_id);
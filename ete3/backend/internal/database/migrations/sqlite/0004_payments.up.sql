@@ -0,0 +1,20 @@
+-- Payments taken for orders. An order may have several attempts, of which
+-- at most one is captured. Orders confirmed before payments existed have
+-- none.
+
+CREATE TABLE payments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	provider TEXT NOT NULL,
+	provider_ref TEXT,
+	amount REAL NOT NULL,
+	refunded REAL NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE INDEX idx_payments_order ON payments(order_id);
+CREATE UNIQUE INDEX idx_payments_provider_ref ON payments(provider, provider_ref);
@@ -0,0 +1,21 @@
+-- Refunds of cancelled orders. Each cancellation records one, even when
+-- nothing is due, so overrides by staff keep an audit trail.
+
+CREATE TABLE refunds (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	payment_id INTEGER,
+	amount REAL NOT NULL,
+	percent REAL NOT NULL,
+	tier TEXT,
+	reason TEXT,
+	issued_by INTEGER,
+	status TEXT NOT NULL,
+	error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id),
+	FOREIGN KEY (payment_id) REFERENCES payments(id)
+);
+
+CREATE INDEX idx_refunds_order ON refunds(order_id);
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"ete3/internal/models"
+)
+
+// paymentColumns are the columns read by scanPayment
+const paymentColumns = `id, order_id, provider, COALESCE(provider_ref, ''), amount, refunded, status, COALESCE(error, ''), created_at, updated_at`
+
+// CreatePayment records a payment attempt for an order and sets its ID
+// and timestamps
+func (s *Store) CreatePayment(payment *models.Payment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := tx.Insert(`
+		INSERT INTO payments (order_id, provider, provider_ref, amount, refunded, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		payment.OrderID, payment.Provider, optionalString(payment.ProviderRef), payment.Amount,
+		payment.Refunded, payment.Status, optionalString(payment.Error))
+	if err != nil {
+		return err
+	}
+
+	stored, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*payment = *stored
+	return nil
+}
+
+// UpdatePayment stores the gateway reference, amounts, status and error of
+// a payment. It returns ErrPaymentNotFound for unknown IDs.
+func (s *Store) UpdatePayment(payment *models.Payment) error {
+	result, err := s.db.Exec(`
+		UPDATE payments
+		SET provider_ref = ?, amount = ?, refunded = ?, status = ?, error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		optionalString(payment.ProviderRef), payment.Amount, payment.Refunded, payment.Status,
+		optionalString(payment.Error), payment.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrPaymentNotFound
+	}
+	return nil
+}
+
+// GetPaymentByRef finds a payment by the provider's reference for it. It
+// returns ErrPaymentNotFound if there is none.
+func (s *Store) GetPaymentByRef(provider, ref string) (*models.Payment, error) {
+	payment, err := scanPayment(s.db.QueryRow(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE provider = ? AND provider_ref = ?`, provider, ref))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+// capturedPayment returns the order's latest captured payment, or nil if
+// it has none
+func capturedPayment(tx *txn, orderID int64) (*models.Payment, error) {
+	payment, err := scanPayment(tx.QueryRow(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE order_id = ? AND status = ?
+		ORDER BY id DESC
+		LIMIT 1`, orderID, models.PaymentCaptured))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return payment, err
+}
+
+// scanPayment reads a payment row selected with paymentColumns
+func scanPayment(row interface{ Scan(...interface{}) error }) (*models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.ProviderRef, &p.Amount, &p.Refunded,
+		&p.Status, &p.Error, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// optionalString converts s into a query argument that is NULL when s is
+// empty
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// errNoPostgres means no PostgreSQL server binaries were found
+var errNoPostgres = errors.New("no PostgreSQL binaries found; install PostgreSQL, set PG_BIN or set TEST_DATABASE_URL")
+
+// TestPostgres runs every test in this package again against a throwaway
+// PostgreSQL cluster started from the local server binaries. It's skipped
+// when the suite already runs on PostgreSQL through TEST_DATABASE_URL,
+// with -short, or when no binaries are available. On CI, where the CI
+// variable is set, missing binaries fail it instead so the PostgreSQL run
+// can't be lost without notice.
+func TestPostgres(t *testing.T) {
+	if store.db.dialect.name() == "postgres" {
+		t.Skip("already running against PostgreSQL")
+	}
+	if testing.Short() {
+		t.Skip("skipping the PostgreSQL run in short mode")
+	}
+
+	dsn, err := startPostgres(t)
+	if errors.Is(err, errNoPostgres) && os.Getenv("CI") == "" {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-test.count=1"}
+	if testing.Verbose() {
+		args = append(args, "-test.v")
+	}
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "TEST_DATABASE_URL="+dsn)
+	out, err := cmd.CombinedOutput()
+	t.Log(string(out))
+	if err != nil {
+		t.Fatalf("tests failed against PostgreSQL: %v", err)
+	}
+}
+
+// startPostgres initialises a cluster in a scratch directory, starts it on a
+// free local port and returns its DSN. The server is stopped and its files
+// removed when the test ends.
+func startPostgres(t *testing.T) (string, error) {
+	bin, err := postgresBinDir()
+	if err != nil {
+		return "", err
+	}
+	// initdb refuses to run as root, leaving nothing to start
+	if os.Geteuid() == 0 {
+		return "", fmt.Errorf("%w as a non-root user", errNoPostgres)
+	}
+
+	// A short path keeps the server's Unix socket within its length limit
+	dir, err := os.MkdirTemp("", "pg")
+	if err != nil {
+		return "", err
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(filepath.Join(bin, "initdb"),
+		"-D", data, "-U", "postgres", "-A", "trust", "-N").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("initdb: %v\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	pgCtl := filepath.Join(bin, "pg_ctl")
+	out, err = exec.Command(pgCtl, "-D", data, "-o", options,
+		"-l", filepath.Join(dir, "server.log"), "-w", "start").CombinedOutput()
+	if err != nil {
+		log, _ := os.ReadFile(filepath.Join(dir, "server.log"))
+		return "", fmt.Errorf("pg_ctl start: %v\n%s%s", err, out, log)
+	}
+	t.Cleanup(func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+	})
+
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port), nil
+}
+
+// postgresBinDir finds the directory holding initdb and pg_ctl: PG_BIN, the
+// PATH, pg_config --bindir or the newest Debian-style install
+func postgresBinDir() (string, error) {
+	hasServer := func(dir string) bool {
+		for _, name := range []string{"initdb", "pg_ctl"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		if !hasServer(dir) {
+			return "", fmt.Errorf("PG_BIN %q has no initdb and pg_ctl", dir)
+		}
+		return dir, nil
+	}
+	if path, err := exec.LookPath("pg_ctl"); err == nil && hasServer(filepath.Dir(path)) {
+		return filepath.Dir(path), nil
+	}
+	if out, err := exec.Command("pg_config", "--bindir").Output(); err == nil {
+		if dir := strings.TrimSpace(string(out)); hasServer(dir) {
+			return dir, nil
+		}
+	}
+	dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Slice(dirs, func(i, j int) bool { return versionOf(dirs[i]) > versionOf(dirs[j]) })
+	for _, dir := range dirs {
+		if hasServer(dir) {
+			return dir, nil
+		}
+	}
+	return "", errNoPostgres
+}
+
+// versionOf returns the major version in a /usr/lib/postgresql/<n>/bin path
+func versionOf(dir string) int {
+	var version int
+	fmt.Sscanf(filepath.Base(filepath.Dir(dir)), "%d", &version)
+	return version
+}
+
+// freePort returns a local TCP port nothing is listening on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
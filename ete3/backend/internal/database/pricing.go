@@ -0,0 +1,72 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"ete3/internal/pricing"
+	"math"
+	"time"
+)
+
+// SetPricing makes bookings and quotes apply engine's rules to the show
+// prices. Without an engine seats cost their category's show price.
+func (s *Store) SetPricing(engine *pricing.Engine) {
+	s.pricing = engine
+}
+
+// QuoteSeats prices seats of a show the way booking them now would,
+// without booking them. It fails like CreateHold for unknown shows and
+// seats, but doesn't check that the seats are free.
+func (s *Store) QuoteSeats(showID int64, seatIDs []int64) (*models.Quote, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Only reads
+	defer tx.Rollback()
+
+	if err := validateBooking(tx, showID, seatIDs); err != nil {
+		return nil, err
+	}
+	return quoteSeats(tx, s.pricing, showID, seatIDs)
+}
+
+// quoteSeats prices each seat at the show's price for its category with
+// engine's rules applied. Occupancy counts the seats held or booked so far.
+func quoteSeats(tx *txn, engine *pricing.Engine, showID int64, seatIDs []int64) (*models.Quote, error) {
+	var ctx pricing.Context
+	var capacity, taken int
+	err := tx.QueryRow(`
+		SELECT sh.start_time, t.capacity, (
+			SELECT COUNT(*) FROM bookings WHERE show_id = sh.id AND `+activeBooking(tx.dialect)+`
+		)
+		FROM shows sh
+		JOIN theaters t ON t.id = sh.theater_id
+		WHERE sh.id = ?`, showID).Scan(&ctx.Start, &capacity, &taken)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Now = time.Now()
+	if capacity > 0 {
+		ctx.Occupancy = float64(taken) / float64(capacity)
+	}
+
+	quote := &models.Quote{ShowID: showID}
+	for _, seatID := range seatIDs {
+		seat := models.SeatQuote{SeatID: seatID}
+		err := tx.QueryRow(`
+			SELECT s.category, COALESCE(sp.price, sh.price)
+			FROM shows sh
+			JOIN seats s ON s.id = ?
+			LEFT JOIN show_prices sp ON sp.show_id = sh.id AND sp.category = s.category
+			WHERE sh.id = ?`, seatID, showID).Scan(&seat.Category, &seat.BasePrice)
+		if err != nil {
+			return nil, err
+		}
+
+		seat.Price, seat.Adjustments = engine.Price(seat.BasePrice, ctx)
+		quote.Seats = append(quote.Seats, seat)
+		quote.Total += seat.Price
+	}
+	quote.Total = math.Round(quote.Total*100) / 100
+	return quote, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"ete3/internal/pricing"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicPricing(t *testing.T) {
+	engine, err := pricing.New([]pricing.Rule{
+		{Name: "Early bird", Type: pricing.EarlyBird, DaysAhead: 7, Percent: -20},
+		{Name: "Nearly full", Type: pricing.Occupancy, MinOccupancy: 0.5, Percent: 50},
+	}, nil)
+	require.NoError(t, err)
+	store.SetPricing(engine)
+	defer store.SetPricing(nil)
+
+	theater := &models.Theater{Name: "Dynamic"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 2, SeatsPerRow: 2}))
+	seats, err := store.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	movie := &models.Movie{Title: "Dynamic", Duration: 90}
+	require.NoError(t, store.CreateMovie(movie, nil))
+	show := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: time.Now().Add(10 * 24 * time.Hour), Price: 10}
+	require.NoError(t, store.CreateShow(show))
+
+	quote, err := store.QuoteSeats(show.ID, []int64{seats[0].ID, seats[1].ID})
+	require.NoError(t, err)
+	require.Len(t, quote.Seats, 2)
+	assert.Equal(t, 10.0, quote.Seats[0].BasePrice)
+	assert.Equal(t, 8.0, quote.Seats[0].Price)
+	assert.Equal(t, []models.PriceAdjustment{{Rule: "Early bird", Type: pricing.EarlyBird, Percent: -20, Amount: -2}}, quote.Seats[0].Adjustments)
+	assert.Equal(t, 16.0, quote.Total)
+
+	// Bookings cost what the quote said
+	booking, err := bookSeats(t, 1, show.ID, []int64{seats[0].ID, seats[1].ID}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, booking.Seats[0].Price)
+	assert.Equal(t, 16.0, booking.Total)
+
+	// Half the seats are now taken
+	quote, err = store.QuoteSeats(show.ID, []int64{seats[2].ID})
+	require.NoError(t, err)
+	assert.Equal(t, 13.0, quote.Total)
+	assert.Len(t, quote.Seats[0].Adjustments, 2)
+
+	_, err = store.QuoteSeats(show.ID, []int64{999999})
+	assert.ErrorIs(t, err, ErrSeatNotInTheater)
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"database/sql"
+	"ete3/internal/models"
+	"math"
+	"time"
+)
+
+// promoColumns are the columns read by scanPromoCode
+const promoColumns = `p.id, p.code, p.type, p.value, p.min_seats, p.movie_id, p.show_id, p.valid_from, p.valid_until, p.max_redemptions, p.max_per_user, p.created_at`
+
+// CreatePromoCode stores a promo code and sets its ID. It returns
+// ErrPromoCodeExists for a taken code and ErrMovieNotFound or
+// ErrShowNotFound if the code is restricted to an unknown movie or show.
+func (s *Store) CreatePromoCode(promo *models.PromoCode) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if promo.MovieID != nil {
+		if err := checkExists(tx, `SELECT COUNT(*) FROM movies WHERE id = ?`, *promo.MovieID, ErrMovieNotFound); err != nil {
+			return err
+		}
+	}
+	if promo.ShowID != nil {
+		if err := checkExists(tx, `SELECT COUNT(*) FROM shows WHERE id = ?`, *promo.ShowID, ErrShowNotFound); err != nil {
+			return err
+		}
+	}
+
+	d := tx.dialect
+	id, err := tx.Insert(`
+		INSERT INTO promo_codes (code, type, value, min_seats, movie_id, show_id, valid_from, valid_until, max_redemptions, max_per_user)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		promo.Code, promo.Type, promo.Value, promo.MinSeats, promo.MovieID, promo.ShowID,
+		optionalTimestamp(d, promo.ValidFrom), optionalTimestamp(d, promo.ValidUntil),
+		promo.MaxRedemptions, promo.MaxPerUser)
+	if d.isUniqueViolation(err) {
+		return ErrPromoCodeExists
+	}
+	if err != nil {
+		return err
+	}
+
+	stored, err := scanPromoCode(tx.QueryRow(`SELECT `+promoColumns+` FROM promo_codes p WHERE p.id = ?`, id))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*promo = *stored
+	return nil
+}
+
+// GetPromoCodes returns every promo code with its usage, ordered by code
+func (s *Store) GetPromoCodes() ([]models.PromoCodeUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT ` + promoColumns + `, COUNT(r.order_id), COUNT(DISTINCT r.user_id), COALESCE(SUM(r.discount), 0)
+		FROM promo_codes p
+		LEFT JOIN promo_redemptions r ON r.promo_code_id = p.id AND r.order_id IN (
+			SELECT id FROM orders WHERE ` + activeBooking(s.db.dialect) + `
+		)
+		GROUP BY ` + promoColumns + `
+		ORDER BY p.code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usages := []models.PromoCodeUsage{}
+	for rows.Next() {
+		var usage models.PromoCodeUsage
+		promo, err := scanPromoCode(rows, &usage.Redemptions, &usage.Users, &usage.TotalDiscount)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromoCode = *promo
+		usage.TotalDiscount = math.Round(usage.TotalDiscount*100) / 100
+		usages = append(usages, usage)
+	}
+	return usages, rows.Err()
+}
+
+// GetPromoCodeUsage reports on one promo code and lists the orders it was
+// redeemed on, newest first. It returns ErrPromoCodeNotFound for unknown
+// IDs.
+func (s *Store) GetPromoCodeUsage(promoID int64) (*models.PromoCodeUsage, error) {
+	promo, err := scanPromoCode(s.db.QueryRow(`SELECT `+promoColumns+` FROM promo_codes p WHERE p.id = ?`, promoID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT o.id, r.user_id, o.show_id, o.status, r.discount, r.created_at, `+activeBooking(s.db.dialect)+`
+		FROM promo_redemptions r
+		JOIN orders o ON o.id = r.order_id
+		WHERE r.promo_code_id = ?
+		ORDER BY r.created_at DESC, o.id DESC`, promoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := &models.PromoCodeUsage{PromoCode: *promo, Orders: []models.PromoRedemption{}}
+	users := make(map[int64]bool)
+	for rows.Next() {
+		var r models.PromoRedemption
+		var active bool
+		if err := rows.Scan(&r.OrderID, &r.UserID, &r.ShowID, &r.Status, &r.Discount, &r.CreatedAt, &active); err != nil {
+			return nil, err
+		}
+		// Holds that lapsed since the reaper last ran no longer count
+		if r.Status == "pending" && !active {
+			r.Status = "expired"
+		}
+		if active {
+			usage.Redemptions++
+			usage.TotalDiscount += r.Discount
+			users[r.UserID] = true
+		}
+		usage.Orders = append(usage.Orders, r)
+	}
+	usage.Users = len(users)
+	usage.TotalDiscount = math.Round(usage.TotalDiscount*100) / 100
+	return usage, rows.Err()
+}
+
+// checkPromoCode finds the promo code a customer entered and checks that
+// userID may redeem it on seats seats of showID. It locks the code's row
+// on PostgreSQL, and takes SQLite's write lock, so concurrent bookings
+// can't exceed its limits.
+func checkPromoCode(tx *txn, code string, userID, showID int64, seats int) (*models.PromoCode, error) {
+	code = models.NormalizePromoCode(code)
+	result, err := tx.Exec(`UPDATE promo_codes SET updated_at = updated_at WHERE code = ?`, code)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrPromoCodeInvalid
+	}
+
+	promo, err := scanPromoCode(tx.QueryRow(`SELECT `+promoColumns+` FROM promo_codes p WHERE p.code = ?`, code))
+	if err != nil {
+		return nil, err
+	}
+	if !promo.ValidAt(time.Now()) {
+		return nil, ErrPromoCodeInvalid
+	}
+
+	if promo.ShowID != nil && *promo.ShowID != showID {
+		return nil, ErrPromoCodeNotApplicable.WithDetails(map[string]int64{"show_id": *promo.ShowID})
+	}
+	if promo.MovieID != nil {
+		var movieID int64
+		if err := tx.QueryRow(`SELECT movie_id FROM shows WHERE id = ?`, showID).Scan(&movieID); err != nil {
+			return nil, err
+		}
+		if movieID != *promo.MovieID {
+			return nil, ErrPromoCodeNotApplicable.WithDetails(map[string]int64{"movie_id": *promo.MovieID})
+		}
+	}
+	if seats < promo.MinSeats {
+		return nil, ErrPromoCodeNotApplicable.WithDetails(map[string]int{"min_seats": promo.MinSeats})
+	}
+
+	var total, byUser int
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END), 0)
+		FROM promo_redemptions
+		WHERE promo_code_id = ? AND order_id IN (
+			SELECT id FROM orders WHERE `+activeBooking(tx.dialect)+`
+		)`, userID, promo.ID).Scan(&total, &byUser)
+	if err != nil {
+		return nil, err
+	}
+	if promo.MaxRedemptions > 0 && total >= promo.MaxRedemptions {
+		return nil, ErrPromoCodeExhausted
+	}
+	if promo.MaxPerUser > 0 && byUser >= promo.MaxPerUser {
+		return nil, ErrPromoCodeExhausted.WithDetails(map[string]int{"max_per_user": promo.MaxPerUser})
+	}
+	return promo, nil
+}
+
+// scanPromoCode reads a promo code selected with promoColumns, followed by
+// extra columns scanned into extra
+func scanPromoCode(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.PromoCode, error) {
+	var p models.PromoCode
+	var movieID, showID sql.NullInt64
+	var validFrom, validUntil sql.NullTime
+	dest := []interface{}{
+		&p.ID, &p.Code, &p.Type, &p.Value, &p.MinSeats, &movieID, &showID,
+		&validFrom, &validUntil, &p.MaxRedemptions, &p.MaxPerUser, &p.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if movieID.Valid {
+		p.MovieID = &movieID.Int64
+	}
+	if showID.Valid {
+		p.ShowID = &showID.Int64
+	}
+	if validFrom.Valid {
+		p.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		p.ValidUntil = &validUntil.Time
+	}
+	return &p, nil
+}
+
+// checkExists returns notFoundErr unless countQuery counts a row for id
+func checkExists(tx *txn, countQuery string, id int64, notFoundErr error) error {
+	var count int
+	if err := tx.QueryRow(countQuery, id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFoundErr
+	}
+	return nil
+}
+
+// optionalTimestamp converts t like dialect.timestamp, or to NULL if t is
+// nil
+func optionalTimestamp(d dialect, t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return d.timestamp(*t)
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoCodeRedemption(t *testing.T) {
+	show, seats := createTestShow(t)
+	other, otherSeats := createTestShow(t)
+
+	promo := &models.PromoCode{Code: "FAMILY", Type: models.PromoPercentage, Value: 25, MinSeats: 2, ShowID: &show.ID, MaxRedemptions: 2, MaxPerUser: 1}
+	require.NoError(t, store.CreatePromoCode(promo))
+	assert.NotZero(t, promo.ID)
+	assert.False(t, promo.CreatedAt.IsZero())
+	assert.ErrorIs(t, store.CreatePromoCode(&models.PromoCode{Code: "FAMILY", Type: models.PromoFixed, Value: 1}), ErrPromoCodeExists)
+
+	_, err := bookSeats(t, 1, show.ID, []int64{seats[0].ID}, "family")
+	assert.ErrorIs(t, err, ErrPromoCodeNotApplicable)
+	assert.Equal(t, map[string]int{"min_seats": 2}, err.(*Error).Details)
+	_, err = bookSeats(t, 1, other.ID, []int64{otherSeats[0].ID, otherSeats[1].ID}, "family")
+	assert.ErrorIs(t, err, ErrPromoCodeNotApplicable)
+	_, err = bookSeats(t, 1, show.ID, []int64{seats[0].ID, seats[1].ID}, "NOSUCHCODE")
+	assert.ErrorIs(t, err, ErrPromoCodeInvalid)
+
+	booking, err := bookSeats(t, 1, show.ID, []int64{seats[0].ID, seats[1].ID}, " family ")
+	require.NoError(t, err)
+	assert.Equal(t, "FAMILY", booking.PromoCode)
+	assert.Equal(t, 5.0, booking.Discount)
+	assert.Equal(t, 15.0, booking.Total)
+
+	// One redemption per user
+	_, err = bookSeats(t, 1, show.ID, []int64{seats[2].ID, seats[3].ID}, "FAMILY")
+	assert.ErrorIs(t, err, ErrPromoCodeExhausted)
+	// A failed redemption books nothing
+	available, err := store.GetAvailableSeats(show.ID)
+	require.NoError(t, err)
+	assert.Len(t, available, len(seats)-2)
+
+	_, err = bookSeats(t, 2, show.ID, []int64{seats[2].ID, seats[3].ID}, "FAMILY")
+	require.NoError(t, err)
+	_, err = bookSeats(t, 3, show.ID, []int64{seats[4].ID, seats[5].ID}, "FAMILY")
+	assert.ErrorIs(t, err, ErrPromoCodeExhausted)
+
+	// Cancelling gives the redemption back
+	_, err = store.CancelBooking(booking.BookingID, 1, nil)
+	require.NoError(t, err)
+	_, err = bookSeats(t, 3, show.ID, []int64{seats[4].ID, seats[5].ID}, "FAMILY")
+	require.NoError(t, err)
+
+	usage, err := store.GetPromoCodeUsage(promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, usage.Redemptions)
+	assert.Equal(t, 2, usage.Users)
+	assert.Equal(t, 10.0, usage.TotalDiscount)
+	require.Len(t, usage.Orders, 3)
+	assert.Equal(t, "cancelled", usage.Orders[2].Status)
+
+	_, err = store.GetPromoCodeUsage(999999)
+	assert.ErrorIs(t, err, ErrPromoCodeNotFound)
+}
+
+func TestPromoCodeRestrictions(t *testing.T) {
+	show, seats := createTestShow(t)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	movieID := show.MovieID + 1000
+	for _, promo := range []*models.PromoCode{
+		{Code: "FIVEOFF", Type: models.PromoFixed, Value: 5, MovieID: &show.MovieID},
+		{Code: "EXPIRED", Type: models.PromoFixed, Value: 5, ValidUntil: &past},
+		{Code: "SOON", Type: models.PromoFixed, Value: 5, ValidFrom: &future},
+	} {
+		require.NoError(t, store.CreatePromoCode(promo))
+	}
+	assert.ErrorIs(t, store.CreatePromoCode(&models.PromoCode{Code: "NOMOVIE", Type: models.PromoFixed, Value: 5, MovieID: &movieID}), ErrMovieNotFound)
+
+	for _, code := range []string{"EXPIRED", "SOON"} {
+		_, err := bookSeats(t, 1, show.ID, []int64{seats[0].ID}, code)
+		assert.ErrorIs(t, err, ErrPromoCodeInvalid, code)
+	}
+
+	// A held code counts until the hold lapses
+	hold, err := store.CreateHold(1, show.ID, []int64{seats[0].ID}, "fiveoff", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, hold.Discount)
+	assert.Equal(t, 5.0, hold.Total)
+
+	payHold(t, hold.BookingID, hold.Total)
+	confirmed, err := store.ConfirmHold(hold.BookingID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "FIVEOFF", confirmed.PromoCode)
+	assert.Equal(t, 5.0, confirmed.Total)
+
+	usages, err := store.GetPromoCodes()
+	require.NoError(t, err)
+	byCode := make(map[string]models.PromoCodeUsage)
+	for _, usage := range usages {
+		byCode[usage.Code] = usage
+	}
+	assert.Equal(t, 1, byCode["FIVEOFF"].Redemptions)
+	assert.Equal(t, 5.0, byCode["FIVEOFF"].TotalDiscount)
+	assert.Equal(t, &show.MovieID, byCode["FIVEOFF"].MovieID)
+	assert.Equal(t, 0, byCode["EXPIRED"].Redemptions)
+	require.NotNil(t, byCode["EXPIRED"].ValidUntil)
+	assert.WithinDuration(t, past, *byCode["EXPIRED"].ValidUntil, time.Second)
+}
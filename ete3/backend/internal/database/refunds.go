@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"ete3/internal/models"
+	"ete3/internal/refunds"
+	"time"
+)
+
+// refundColumns are the columns read by scanRefund
+const refundColumns = `id, order_id, payment_id, amount, percent, COALESCE(tier, ''), COALESCE(reason, ''), issued_by, status, COALESCE(error, ''), created_at, updated_at`
+
+// SetRefundPolicy makes cancellations refund what policy allows. Without
+// a policy cancelled bookings are refunded in full.
+func (s *Store) SetRefundPolicy(policy *refunds.Policy) {
+	s.refunds = policy
+}
+
+// UpdateRefund stores the status and error of a refund. It returns
+// ErrRefundNotFound for unknown IDs.
+func (s *Store) UpdateRefund(refund *models.Refund) error {
+	result, err := s.db.Exec(`
+		UPDATE refunds
+		SET status = ?, error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, refund.Status, optionalString(refund.Error), refund.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRefundNotFound
+	}
+	return nil
+}
+
+// GetPayment returns a payment by ID, or ErrPaymentNotFound
+func (s *Store) GetPayment(paymentID int64) (*models.Payment, error) {
+	payment, err := scanPayment(s.db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = ?`, paymentID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+// recordRefund records the refund due for cancelling seatID of orderID
+// now, or all of its confirmed seats if seatID is 0: the share of its
+// captured payment that policy, or override if it isn't nil, allows. It
+// must run before the seats are cancelled. A refund of nothing is
+// completed at once; anything else is left pending until it is returned
+// through the payment provider.
+func recordRefund(tx *txn, policy *refunds.Policy, orderID, seatID int64, override *models.RefundOverride) (*models.Refund, error) {
+	var start time.Time
+	err := tx.QueryRow(`
+		SELECT s.start_time
+		FROM orders o
+		JOIN shows s ON s.id = o.show_id
+		WHERE o.id = ?`, orderID).Scan(&start)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := capturedPayment(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refund := &models.Refund{OrderID: orderID}
+	var paid float64
+	if payment != nil {
+		refund.PaymentID = &payment.ID
+		if paid, err = paidShare(tx, payment, orderID, seatID); err != nil {
+			return nil, err
+		}
+	}
+
+	if override != nil {
+		refund.Amount = refunds.Amount(paid, override.Percent)
+		refund.Percent = override.Percent
+		refund.Tier = models.OverrideTier
+		refund.Reason = override.Reason
+		refund.IssuedBy = &override.IssuedBy
+	} else {
+		refund.Amount, refund.Percent, refund.Tier = policy.Refund(paid, start, time.Now())
+	}
+	refund.Status = models.RefundCompleted
+	if refund.Amount > 0 {
+		refund.Status = models.RefundPending
+	}
+
+	id, err := tx.Insert(`
+		INSERT INTO refunds (order_id, payment_id, amount, percent, tier, reason, issued_by, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		refund.OrderID, refund.PaymentID, refund.Amount, refund.Percent, optionalString(refund.Tier),
+		optionalString(refund.Reason), refund.IssuedBy, refund.Status)
+	if err != nil {
+		return nil, err
+	}
+	return scanRefund(tx.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = ?`, id))
+}
+
+// paidShare returns the part of payment that paid for seatID of orderID,
+// or for all of its confirmed seats if seatID is 0, as refunds.Share
+// splits it by seat price
+func paidShare(tx *txn, payment *models.Payment, orderID, seatID int64) (float64, error) {
+	rows, err := tx.Query(`
+		SELECT seat_id, price, status FROM bookings WHERE order_id = ?`, orderID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var total, before, cancelling float64
+	for rows.Next() {
+		var seat int64
+		var price float64
+		var status string
+		if err := rows.Scan(&seat, &price, &status); err != nil {
+			return 0, err
+		}
+		total += price
+		switch {
+		case status == "cancelled":
+			before += price
+		case status == "confirmed" && (seatID == 0 || seat == seatID):
+			cancelling += price
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return refunds.Share(payment.Amount, payment.Refunded, total, before, cancelling), nil
+}
+
+// scanRefund reads a refund row selected with refundColumns
+func scanRefund(row interface{ Scan(...interface{}) error }) (*models.Refund, error) {
+	var r models.Refund
+	var paymentID, issuedBy sql.NullInt64
+	err := row.Scan(&r.ID, &r.OrderID, &paymentID, &r.Amount, &r.Percent, &r.Tier, &r.Reason,
+		&issuedBy, &r.Status, &r.Error, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if paymentID.Valid {
+		r.PaymentID = &paymentID.Int64
+	}
+	if issuedBy.Valid {
+		r.IssuedBy = &issuedBy.Int64
+	}
+	return &r, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"ete3/internal/refunds"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelBookingRefund(t *testing.T) {
+	// The test show is tomorrow, so only the week's notice tier is missed
+	policy, err := refunds.New([]refunds.Tier{
+		{Name: "week", Before: "168h", Percent: 100},
+		{Name: "day", Before: "12h", Percent: 40},
+	})
+	require.NoError(t, err)
+	store.SetRefundPolicy(policy)
+	t.Cleanup(func() { store.SetRefundPolicy(nil) })
+
+	show, seats := createTestShow(t)
+	userID := int64(30)
+
+	hold, err := store.CreateHold(userID, show.ID, []int64{seats[0].ID, seats[1].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+	payHold(t, hold.BookingID, hold.Total)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+
+	_, err = store.CancelBooking(hold.BookingID, userID+1, nil)
+	assert.ErrorIs(t, err, ErrBookingNotOwned)
+
+	refund, err := store.CancelBooking(hold.BookingID, userID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, refund.Amount)
+	assert.Equal(t, 40.0, refund.Percent)
+	assert.Equal(t, "day", refund.Tier)
+	assert.Equal(t, models.RefundPending, refund.Status)
+	require.NotNil(t, refund.PaymentID)
+	assert.Nil(t, refund.IssuedBy)
+
+	refund.Status = models.RefundFailed
+	refund.Error = "gateway down"
+	require.NoError(t, store.UpdateRefund(refund))
+	assert.ErrorIs(t, store.UpdateRefund(&models.Refund{ID: 99999}), ErrRefundNotFound)
+
+	payment, err := store.GetPayment(*refund.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, hold.Total, payment.Amount)
+	_, err = store.GetPayment(99999)
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+
+	// Staff override the policy for someone else's booking
+	booking, err := bookSeats(t, userID, show.ID, []int64{seats[2].ID}, "")
+	require.NoError(t, err)
+	refund, err = store.CancelBooking(booking.BookingID, 1, &models.RefundOverride{Percent: 100, Reason: "Show moved", IssuedBy: 1})
+	require.NoError(t, err)
+	assert.Equal(t, booking.Total, refund.Amount)
+	require.NotNil(t, refund.PaymentID)
+	assert.Equal(t, models.OverrideTier, refund.Tier)
+	assert.Equal(t, "Show moved", refund.Reason)
+	require.NotNil(t, refund.IssuedBy)
+	assert.Equal(t, int64(1), *refund.IssuedBy)
+	assert.Equal(t, models.RefundPending, refund.Status)
+
+	_, err = store.CancelBooking(booking.BookingID, 1, &models.RefundOverride{Percent: 100, Reason: "Again", IssuedBy: 1})
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+	_, err = store.CancelBooking(99999, 1, &models.RefundOverride{Percent: 100, Reason: "Unknown", IssuedBy: 1})
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+
+	// The show stays while its orders have payments, and so do the refunds
+	// with who issued them and why
+	assert.ErrorIs(t, store.DeleteShow(show.ID), ErrShowHasPayments)
+	kept, err := scanRefund(store.db.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = ?`, refund.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Show moved", kept.Reason)
+	require.NotNil(t, kept.IssuedBy)
+	assert.Equal(t, int64(1), *kept.IssuedBy)
+	var count int
+	require.NoError(t, store.db.QueryRow(`
+		SELECT COUNT(*) FROM refunds
+		WHERE order_id IN (?, ?)`, hold.BookingID, booking.BookingID).Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestCancelBookingSeatRefunds(t *testing.T) {
+	show, seats := createTestShow(t)
+	userID := int64(31)
+	require.NoError(t, store.CreatePromoCode(&models.PromoCode{Code: "SEATREFUND", Type: models.PromoFixed, Value: 5, ShowID: &show.ID}))
+
+	// Three 10.00 seats less 5.00 off don't split evenly
+	seatIDs := []int64{seats[0].ID, seats[1].ID, seats[2].ID}
+	hold, err := store.CreateHold(userID, show.ID, seatIDs, "SEATREFUND", 10*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 25.0, hold.Total)
+	payHold(t, hold.BookingID, hold.Total)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+
+	_, err = store.CancelBookingSeat(hold.BookingID, seats[3].ID, userID, nil)
+	assert.ErrorIs(t, err, ErrSeatNotInBooking)
+
+	var amounts []float64
+	for _, seatID := range seatIDs {
+		refund, err := store.CancelBookingSeat(hold.BookingID, seatID, userID, nil)
+		require.NoError(t, err)
+		require.NotNil(t, refund.PaymentID)
+		assert.Equal(t, models.RefundPending, refund.Status)
+		amounts = append(amounts, refund.Amount)
+	}
+	assert.Equal(t, []float64{8.33, 8.34, 8.33}, amounts)
+
+	// The order is gone once its last seat is
+	_, err = store.CancelBooking(hold.BookingID, userID, nil)
+	assert.ErrorIs(t, err, ErrBookingNotFound)
+
+	// Staff overrides apply to single seats, and the rest of the order is
+	// refunded by what is left of the payment
+	hold, err = store.CreateHold(userID, show.ID, seatIDs, "", 10*time.Minute)
+	require.NoError(t, err)
+	payHold(t, hold.BookingID, hold.Total)
+	_, err = store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+
+	refund, err := store.CancelBookingSeat(hold.BookingID, seats[0].ID, 1, &models.RefundOverride{Percent: 50, Reason: "Broken seat", IssuedBy: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, refund.Amount)
+	assert.Equal(t, models.OverrideTier, refund.Tier)
+	refund, err = store.CancelBooking(hold.BookingID, userID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, refund.Amount)
+}
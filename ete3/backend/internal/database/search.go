@@ -0,0 +1,220 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"log"
+	"strings"
+)
+
+// initSearch sets up full-text search over movie titles and descriptions.
+// On SQLite built with FTS5 (go build -tags sqlite_fts5) it creates the
+// movies_fts index if needed and rebuilds it from movies, so edits made by
+// a build without FTS5 are picked up. Otherwise search falls back to
+// matching words with LIKE, and results sorted by relevance come out in
+// title order.
+func (s *Store) initSearch() error {
+	if s.db.dialect.name() != "sqlite" {
+		return nil
+	}
+	_, err := s.db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS movies_fts USING fts5(title, description)`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			log.Print("WARNING: this build's SQLite has no FTS5, rebuild with -tags sqlite_fts5 for full-text movie search")
+			return nil
+		}
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM movies_fts`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO movies_fts (rowid, title, description)
+		SELECT id, title, COALESCE(description, '') FROM movies`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.fts = true
+	return nil
+}
+
+// FullTextSearch reports whether movie search uses the full-text index.
+// Without it searches are degraded, see MoviePage.SearchDegraded.
+func (s *Store) FullTextSearch() bool {
+	return s.fts
+}
+
+// indexMovie brings the search index entry of movie up to date
+func (s *Store) indexMovie(tx *txn, movie *models.Movie) error {
+	if !s.fts {
+		return nil
+	}
+	if _, err := tx.Exec(`DELETE FROM movies_fts WHERE rowid = ?`, movie.ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO movies_fts (rowid, title, description) VALUES (?, ?, ?)`,
+		movie.ID, movie.Title, movie.Description)
+	return err
+}
+
+// movieSorts are the ORDER BY keys of each sort order, before the ID that
+// breaks ties
+var movieSorts = map[string]struct {
+	key  string
+	desc bool
+}{
+	models.SortRelevance:    {"movies_fts.rank", false},
+	models.SortTitle:        {"LOWER(m.title)", false},
+	models.SortTitleDesc:    {"LOWER(m.title)", true},
+	models.SortDuration:     {"m.duration", false},
+	models.SortDurationDesc: {"m.duration", true},
+	models.SortNewest:       {"", true},
+}
+
+// GetMovies returns a page of the movies that match query. It returns
+// ErrInvalidCursor if the query's cursor can't be used.
+func (s *Store) GetMovies(query *models.MovieQuery) (*models.MoviePage, error) {
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	order := query.SortOrder()
+	terms := query.SearchTerms()
+	if order == models.SortRelevance && (!s.fts || len(terms) == 0) {
+		order = models.SortTitle
+	}
+	sort := movieSorts[order]
+
+	from := "movies m"
+	var where []string
+	var args []interface{}
+	switch {
+	case len(terms) == 0:
+	case s.fts:
+		// Every word is a prefix, quoted so it can't be read as FTS syntax
+		match := make([]string, len(terms))
+		for i, term := range terms {
+			match[i] = `"` + term + `"*`
+		}
+		from = "movies_fts JOIN movies m ON m.id = movies_fts.rowid"
+		where = append(where, "movies_fts MATCH ?")
+		args = append(args, strings.Join(match, " "))
+	default:
+		for _, term := range terms {
+			where = append(where, "(LOWER(m.title) LIKE LOWER(?) OR LOWER(COALESCE(m.description, '')) LIKE LOWER(?))")
+			args = append(args, "%"+term+"%", "%"+term+"%")
+		}
+	}
+	if query.Genre != "" {
+		where = append(where, "LOWER(m.genre) = LOWER(?)")
+		args = append(args, query.Genre)
+	}
+	if query.MinDuration > 0 {
+		where = append(where, "m.duration >= ?")
+		args = append(args, query.MinDuration)
+	}
+	if query.MaxDuration > 0 {
+		where = append(where, "m.duration <= ?")
+		args = append(args, query.MaxDuration)
+	}
+	if dayStart, dayEnd, err := query.ShowDay(); err != nil {
+		return nil, NewError(KindInvalid, "invalid_request", err.Error())
+	} else if !dayStart.IsZero() {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM shows sh
+			WHERE sh.movie_id = m.id AND sh.start_time >= ? AND sh.start_time < ?)`)
+		args = append(args, s.db.dialect.timestamp(dayStart), s.db.dialect.timestamp(dayEnd))
+	}
+
+	// Keyset pagination: continue after the cursor's key and ID
+	cmp := ">"
+	if sort.desc {
+		cmp = "<"
+	}
+	if cursor != nil {
+		if sort.key == "" {
+			where = append(where, "m.id "+cmp+" ?")
+			args = append(args, cursor.ID)
+		} else {
+			key := cursorKey(cursor, order)
+			where = append(where, "("+sort.key+" "+cmp+" ? OR ("+sort.key+" = ? AND m.id "+cmp+" ?))")
+			args = append(args, key, key, cursor.ID)
+		}
+	}
+
+	direction := " ASC"
+	if sort.desc {
+		direction = " DESC"
+	}
+	orderBy := "m.id" + direction
+	if sort.key != "" {
+		orderBy = sort.key + direction + ", " + orderBy
+	}
+	// The cursor keeps the keys as the database computes them
+	rank := "0"
+	if order == models.SortRelevance {
+		rank = "movies_fts.rank"
+	}
+
+	q := `SELECT m.id, m.title, m.description, m.duration, m.genre, m.poster_url, m.created_at, m.updated_at, LOWER(m.title), ` + rank + `
+		FROM ` + from
+	if len(where) > 0 {
+		q += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	q += "\n\t\tORDER BY " + orderBy + "\n\t\tLIMIT ?"
+	limit := query.PageSize()
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.MoviePage{Movies: []models.Movie{}, SearchDegraded: len(terms) > 0 && !s.fts}
+	var last models.MovieCursor
+	for rows.Next() {
+		var m models.Movie
+		var title string
+		var rank float64
+		err := rows.Scan(&m.ID, &m.Title, &m.Description, &m.Duration, &m.Genre, &m.PosterURL, &m.CreatedAt, &m.UpdatedAt, &title, &rank)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Movies) == limit {
+			page.NextCursor = last.Encode()
+			break
+		}
+		page.Movies = append(page.Movies, m)
+
+		last = models.MovieCursor{Sort: query.SortOrder(), ID: m.ID}
+		switch order {
+		case models.SortRelevance:
+			last.Rank = rank
+		case models.SortTitle, models.SortTitleDesc:
+			last.Title = title
+		case models.SortDuration, models.SortDurationDesc:
+			last.Duration = m.Duration
+		}
+	}
+	return page, rows.Err()
+}
+
+// cursorKey returns the sort key value a cursor of order holds
+func cursorKey(cursor *models.MovieCursor, order string) interface{} {
+	switch order {
+	case models.SortRelevance:
+		return cursor.Rank
+	case models.SortTitle, models.SortTitleDesc:
+		return cursor.Title
+	}
+	return cursor.Duration
+}
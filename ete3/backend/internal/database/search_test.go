@@ -0,0 +1,136 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// movieTitles returns the titles of movies in order
+func movieTitles(movies []models.Movie) []string {
+	titles := make([]string, len(movies))
+	for i, m := range movies {
+		titles[i] = m.Title
+	}
+	return titles
+}
+
+func TestSearchMovies(t *testing.T) {
+	theater := &models.Theater{Name: "Search Test"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 2, SeatsPerRow: 2}))
+	day := time.Date(time.Now().Year()+3, time.March, 14, 0, 0, 0, 0, time.Local)
+
+	// Every movie mentions "quasarfish", which no other test uses
+	for _, movie := range []struct {
+		models.Movie
+		showAt time.Duration
+	}{
+		{Movie: models.Movie{Title: "Quasarfish Rising", Description: "A nebula heist", Duration: 95, Genre: "Sci-Fi"}},
+		{Movie: models.Movie{Title: "alpha Quasarfish", Description: "Quasarfish quasarfish in the deep", Duration: 140, Genre: "Drama"}, showAt: 23 * time.Hour},
+		{Movie: models.Movie{Title: "Nebula Nights", Description: "The quasarfish returns", Duration: 120, Genre: "sci-fi"}, showAt: 10 * time.Hour},
+		{Movie: models.Movie{Title: "Zeta Quasarfishes", Description: "Sequel", Duration: 120, Genre: "Drama"}, showAt: 26 * time.Hour},
+	} {
+		var shows []models.Show
+		if movie.showAt > 0 {
+			shows = append(shows, models.Show{TheaterID: theater.ID, StartTime: day.Add(movie.showAt), Price: 10})
+		}
+		movie := movie.Movie
+		require.NoError(t, store.CreateMovie(&movie, shows))
+	}
+
+	search := func(query models.MovieQuery) []string {
+		t.Helper()
+		page, err := store.GetMovies(&query)
+		require.NoError(t, err)
+		return movieTitles(page.Movies)
+	}
+
+	all := []string{"alpha Quasarfish", "Nebula Nights", "Quasarfish Rising", "Zeta Quasarfishes"}
+	assert.Equal(t, all, search(models.MovieQuery{Search: "quasarfish", Sort: models.SortTitle}))
+	assert.ElementsMatch(t, all, search(models.MovieQuery{Search: "QUASARF"}))
+	assert.ElementsMatch(t, []string{"Quasarfish Rising", "Nebula Nights"}, search(models.MovieQuery{Search: "quasarfish, nebula!"}))
+	assert.Equal(t, []string{"Nebula Nights", "Quasarfish Rising"}, search(models.MovieQuery{Search: "quasarfish", Genre: "SCI-FI", Sort: models.SortTitle}))
+	assert.Equal(t, []string{"Nebula Nights", "Zeta Quasarfishes"}, search(models.MovieQuery{Search: "quasarfish", MinDuration: 100, MaxDuration: 120, Sort: models.SortTitle}))
+	assert.Equal(t, []string{"Zeta Quasarfishes", "Quasarfish Rising", "Nebula Nights", "alpha Quasarfish"}, search(models.MovieQuery{Search: "quasarfish", Sort: models.SortTitleDesc}))
+	assert.Equal(t, []string{"Quasarfish Rising", "Nebula Nights", "Zeta Quasarfishes", "alpha Quasarfish"}, search(models.MovieQuery{Search: "quasarfish", Sort: models.SortDuration}))
+	assert.Equal(t, []string{"Zeta Quasarfishes", "Nebula Nights", "alpha Quasarfish", "Quasarfish Rising"}, search(models.MovieQuery{Search: "quasarfish", Sort: models.SortNewest}))
+
+	// Only shows starting on the day count, not the one early the next morning
+	assert.Equal(t, []string{"alpha Quasarfish", "Nebula Nights"}, search(models.MovieQuery{Search: "quasarfish", Date: day.Format("2006-01-02"), Sort: models.SortTitle}))
+
+	// Relevance puts the movie mentioning the word most first when the
+	// index supports ranking
+	ranked := search(models.MovieQuery{Search: "quasarfish"})
+	assert.ElementsMatch(t, all, ranked)
+	if store.fts {
+		assert.Equal(t, "alpha Quasarfish", ranked[0])
+	}
+
+	// Searches without the index say so; browsing isn't affected
+	page, err := store.GetMovies(&models.MovieQuery{Search: "quasarfish"})
+	require.NoError(t, err)
+	assert.Equal(t, !store.FullTextSearch(), page.SearchDegraded)
+	page, err = store.GetMovies(&models.MovieQuery{Genre: "drama"})
+	require.NoError(t, err)
+	assert.False(t, page.SearchDegraded)
+
+	// Updates are searchable
+	page, err = store.GetMovies(&models.MovieQuery{Search: "zeta quasarfishes"})
+	require.NoError(t, err)
+	require.Len(t, page.Movies, 1)
+	zeta := page.Movies[0]
+	zeta.Title = "Omega Quasarfishes"
+	require.NoError(t, store.UpdateMovie(&zeta))
+	assert.Empty(t, search(models.MovieQuery{Search: "zeta quasarfishes"}))
+	assert.Equal(t, []string{"Omega Quasarfishes"}, search(models.MovieQuery{Search: "omega quasarfishes"}))
+}
+
+func TestPaginateMovies(t *testing.T) {
+	for _, title := range []string{"Pagewyrm B", "pagewyrm a", "Pagewyrm D", "Pagewyrm C", "Pagewyrm E"} {
+		require.NoError(t, store.CreateMovie(&models.Movie{Title: title, Duration: 100}, nil))
+	}
+
+	for _, tt := range []struct {
+		sort string
+		want []string
+	}{
+		{models.SortTitle, []string{"pagewyrm a", "Pagewyrm B", "Pagewyrm C", "Pagewyrm D", "Pagewyrm E"}},
+		{models.SortTitleDesc, []string{"Pagewyrm E", "Pagewyrm D", "Pagewyrm C", "Pagewyrm B", "pagewyrm a"}},
+		{models.SortDuration, []string{"Pagewyrm B", "pagewyrm a", "Pagewyrm D", "Pagewyrm C", "Pagewyrm E"}},
+		{models.SortNewest, []string{"Pagewyrm E", "Pagewyrm C", "Pagewyrm D", "pagewyrm a", "Pagewyrm B"}},
+		{models.SortRelevance, nil},
+	} {
+		t.Run(tt.sort, func(t *testing.T) {
+			query := models.MovieQuery{Search: "pagewyrm", Sort: tt.sort, Limit: 2}
+			var titles []string
+			for pages := 1; ; pages++ {
+				page, err := store.GetMovies(&query)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Movies), 2)
+				titles = append(titles, movieTitles(page.Movies)...)
+				if page.NextCursor == "" {
+					assert.Equal(t, 3, pages)
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			if tt.want != nil {
+				assert.Equal(t, tt.want, titles)
+			} else {
+				assert.Len(t, titles, 5)
+				assert.ElementsMatch(t, []string{"pagewyrm a", "Pagewyrm B", "Pagewyrm C", "Pagewyrm D", "Pagewyrm E"}, titles)
+			}
+
+			// A cursor only continues the order it came from
+			query.Sort = models.SortDurationDesc
+			_, err := store.GetMovies(&query)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+
+	_, err := store.GetMovies(&models.MovieQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,442 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"ete3/internal/models"
+	"sort"
+	"time"
+)
+
+// CleaningBuffer is the time a theater needs between the end of one show
+// and the start of the next
+const CleaningBuffer = 15 * time.Minute
+
+// CreateShow schedules a show. The end time is set from the movie's
+// duration. It returns ErrMovieNotFound or ErrTheaterNotFound for unknown
+// references, ErrShowInPast for a start time that has passed and
+// ErrShowOverlap if another show in the theater is too close.
+func (s *Store) CreateShow(show *models.Show) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveShow(tx, show); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateShow reschedules a show with the same checks as CreateShow. A show
+// that has started can't be changed, and one with active bookings keeps its
+// movie, theater and start time: the tickets sold are for those. Its prices
+// can still change, which only affects later bookings.
+func (s *Store) UpdateShow(show *models.Show) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var movieID, theaterID int64
+	var startTime time.Time
+	var started bool
+	err = tx.QueryRow(`
+		SELECT movie_id, theater_id, start_time, start_time <= `+tx.dialect.now()+`
+		FROM shows
+		WHERE id = ?`, show.ID).Scan(&movieID, &theaterID, &startTime, &started)
+	if err == sql.ErrNoRows {
+		return ErrShowNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if started {
+		return ErrShowStarted
+	}
+	if show.MovieID != movieID || show.TheaterID != theaterID || !show.StartTime.Equal(startTime) {
+		if err := checkNoActiveBookings(tx, show.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := saveShow(tx, show); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteShow removes a show that hasn't started and has no active
+// bookings, together with its cancelled and expired orders. Payments and
+// refunds are never deleted, so a show with paid or refunded orders returns
+// ErrShowHasPayments.
+func (s *Store) DeleteShow(showID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var started bool
+	err = tx.QueryRow(`
+		SELECT start_time <= `+tx.dialect.now()+`
+		FROM shows
+		WHERE id = ?`, showID).Scan(&started)
+	if err == sql.ErrNoRows {
+		return ErrShowNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if started {
+		return ErrShowStarted
+	}
+	if err := checkNoActiveBookings(tx, showID); err != nil {
+		return err
+	}
+	if err := checkNoPayments(tx, showID); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM promo_redemptions WHERE order_id IN (SELECT id FROM orders WHERE show_id = ?)`,
+		`DELETE FROM bookings WHERE show_id = ?`,
+		`DELETE FROM orders WHERE show_id = ?`,
+		`DELETE FROM show_prices WHERE show_id = ?`,
+		`DELETE FROM shows WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, showID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PreviewShows checks each of shows as CreateShow would, counting the
+// earlier ones as scheduled, and reports the conflicts without storing
+// anything. Unknown movies and theaters fail the whole preview.
+func (s *Store) PreviewShows(shows []models.Show) ([]models.ScheduledShow, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Never committed
+	defer tx.Rollback()
+
+	scheduled, _, err := scheduleShows(tx, shows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scheduled {
+		scheduled[i].ID = 0
+	}
+	return scheduled, nil
+}
+
+// CreateShows schedules all of shows in one transaction, or none of them.
+// If any conflict, it returns ErrScheduleConflict with the conflicting
+// shows as details.
+func (s *Store) CreateShows(shows []models.Show) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createShows(tx, shows); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createShows saves shows in tx as CreateShows describes, setting their
+// IDs and end times. tx must be rolled back if it fails.
+func createShows(tx *txn, shows []models.Show) error {
+	scheduled, conflicts, err := scheduleShows(tx, shows)
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return ErrScheduleConflict.WithDetails(ConflictingShows(scheduled))
+	}
+
+	for i := range shows {
+		shows[i] = scheduled[i].Show
+	}
+	return nil
+}
+
+// scheduleShows saves shows one by one in tx, recording validation
+// failures as conflicts instead of stopping at them
+func scheduleShows(tx *txn, shows []models.Show) ([]models.ScheduledShow, int, error) {
+	scheduled := make([]models.ScheduledShow, len(shows))
+	indexByID := make(map[int64]int)
+	conflicts := 0
+	for i, show := range shows {
+		show.ID = 0
+		err := saveShow(tx, &show)
+		scheduled[i].Show = show
+		if conflict := ScheduleConflict(err, indexByID); conflict != nil {
+			scheduled[i].ID = 0
+			scheduled[i].Conflict = conflict
+			conflicts++
+		} else if err != nil {
+			return nil, 0, err
+		} else {
+			indexByID[show.ID] = i
+		}
+	}
+	return scheduled, conflicts, nil
+}
+
+// ScheduleConflict describes err if it rules out a single show of a
+// schedule rather than the whole schedule. indexByID maps the IDs of the
+// schedule's shows saved so far to their positions.
+func ScheduleConflict(err error, indexByID map[int64]int) *models.ScheduleConflict {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || (!errors.Is(err, ErrShowOverlap) && !errors.Is(err, ErrShowInPast)) {
+		return nil
+	}
+
+	conflict := &models.ScheduleConflict{Code: apiErr.Code, Message: apiErr.Message}
+	if details, ok := apiErr.Details.(map[string]int64); ok {
+		if index, ok := indexByID[details["show_id"]]; ok {
+			conflict.WithIndex = &index
+		} else {
+			conflict.ShowID = details["show_id"]
+		}
+	}
+	return conflict
+}
+
+// ConflictingShows returns the scheduled shows that have a conflict
+func ConflictingShows(scheduled []models.ScheduledShow) []models.ScheduledShow {
+	var conflicting []models.ScheduledShow
+	for _, show := range scheduled {
+		if show.Conflict != nil {
+			conflicting = append(conflicting, show)
+		}
+	}
+	return conflicting
+}
+
+// saveShow validates show and inserts it, or updates it if it has an ID
+func saveShow(tx *txn, show *models.Show) error {
+	if !show.StartTime.After(time.Now()) {
+		return ErrShowInPast
+	}
+
+	var duration int
+	err := tx.QueryRow(`SELECT duration FROM movies WHERE id = ?`, show.MovieID).Scan(&duration)
+	if err == sql.ErrNoRows {
+		return ErrMovieNotFound
+	}
+	if err != nil {
+		return err
+	}
+	show.EndTime = show.StartTime.Add(time.Duration(duration) * time.Minute)
+
+	// Touching the theater row checks that it exists and, on PostgreSQL,
+	// locks it so concurrent scheduling in the theater can't overlap
+	result, err := tx.Exec(`UPDATE theaters SET updated_at = updated_at WHERE id = ?`, show.TheaterID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrTheaterNotFound
+	}
+
+	if err := checkShowCategories(tx, show); err != nil {
+		return err
+	}
+	if err := checkShowOverlap(tx, show); err != nil {
+		return err
+	}
+
+	d := tx.dialect
+	if show.ID == 0 {
+		show.ID, err = tx.Insert(`
+			INSERT INTO shows (movie_id, theater_id, start_time, end_time, price)
+			VALUES (?, ?, ?, ?, ?)`,
+			show.MovieID, show.TheaterID, d.timestamp(show.StartTime), d.timestamp(show.EndTime), show.Price)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE shows
+			SET movie_id = ?, theater_id = ?, start_time = ?, end_time = ?, price = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`,
+			show.MovieID, show.TheaterID, d.timestamp(show.StartTime), d.timestamp(show.EndTime), show.Price, show.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM show_prices WHERE show_id = ?`, show.ID); err != nil {
+		return err
+	}
+	for category, price := range show.Prices {
+		_, err := tx.Exec(`
+			INSERT INTO show_prices (show_id, category, price)
+			VALUES (?, ?, ?)`,
+			show.ID, category, price)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkShowCategories returns ErrUnknownSeatCategory, naming the category,
+// if show has a price for a category its theater doesn't have
+func checkShowCategories(tx *txn, show *models.Show) error {
+	categories := make([]string, 0, len(show.Prices))
+	for category := range show.Prices {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		if category == models.StandardCategory.Code {
+			continue
+		}
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM seat_categories
+			WHERE theater_id = ? AND code = ?`, show.TheaterID, category).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrUnknownSeatCategory.WithDetails(map[string]string{"category": category})
+		}
+	}
+	return nil
+}
+
+// checkShowOverlap returns ErrShowOverlap, naming the conflicting show, if
+// another show in the theater runs within CleaningBuffer of show
+func checkShowOverlap(tx *txn, show *models.Show) error {
+	var conflictID int64
+	err := tx.QueryRow(`
+		SELECT id FROM shows
+		WHERE theater_id = ? AND id <> ? AND start_time < ? AND end_time > ?
+		ORDER BY start_time
+		LIMIT 1`,
+		show.TheaterID, show.ID,
+		tx.dialect.timestamp(show.EndTime.Add(CleaningBuffer)),
+		tx.dialect.timestamp(show.StartTime.Add(-CleaningBuffer))).Scan(&conflictID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrShowOverlap.WithDetails(map[string]int64{"show_id": conflictID})
+}
+
+// checkNoActiveBookings returns ErrShowHasBookings if seats of the show are
+// held or booked
+func checkNoActiveBookings(tx *txn, showID int64) error {
+	var active int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM bookings
+		WHERE show_id = ? AND `+activeBooking(tx.dialect), showID).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrShowHasBookings
+	}
+	return nil
+}
+
+// GetShows returns the shows in the query's window that haven't started,
+// in start time order, with their movie, theater and free seat count
+func (s *Store) GetShows(query *models.ShowQuery) ([]models.ShowListing, error) {
+	from, until, err := query.Window(time.Now())
+	if err != nil {
+		return nil, NewError(KindInvalid, "invalid_request", err.Error())
+	}
+
+	q := `
+		SELECT s.id, s.movie_id, s.theater_id, s.start_time, s.end_time, s.price, s.created_at, s.updated_at,
+			m.id, m.title, m.description, m.duration, m.genre, m.poster_url, m.created_at, m.updated_at,
+			t.id, t.name, t.capacity,
+			(SELECT COUNT(*) FROM seats WHERE theater_id = s.theater_id)
+				- (SELECT COUNT(*) FROM bookings b WHERE b.show_id = s.id AND ` + activeBooking(s.db.dialect) + `)
+		FROM shows s
+		JOIN movies m ON m.id = s.movie_id
+		JOIN theaters t ON t.id = s.theater_id
+		WHERE s.start_time >= ? AND s.start_time < ?`
+	args := []interface{}{s.db.dialect.timestamp(from), s.db.dialect.timestamp(until)}
+	if query.TheaterID != 0 {
+		q += " AND s.theater_id = ?"
+		args = append(args, query.TheaterID)
+	}
+	if query.MovieID != 0 {
+		q += " AND s.movie_id = ?"
+		args = append(args, query.MovieID)
+	}
+	q += "\n\t\tORDER BY s.start_time, t.name, s.id"
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	listings := []models.ShowListing{}
+	for rows.Next() {
+		var l models.ShowListing
+		err := rows.Scan(
+			&l.ID, &l.MovieID, &l.TheaterID, &l.StartTime, &l.EndTime, &l.Price, &l.CreatedAt, &l.UpdatedAt,
+			&l.Movie.ID, &l.Movie.Title, &l.Movie.Description, &l.Movie.Duration, &l.Movie.Genre, &l.Movie.PosterURL, &l.Movie.CreatedAt, &l.Movie.UpdatedAt,
+			&l.Theater.ID, &l.Theater.Name, &l.Theater.Capacity,
+			&l.SeatsLeft)
+		if err != nil {
+			return nil, err
+		}
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ids := make([]int64, len(listings))
+	for i := range listings {
+		ids[i] = listings[i].ID
+	}
+	prices, err := s.pricesByShow(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range listings {
+		listings[i].Prices = prices[listings[i].ID]
+	}
+	return listings, nil
+}
+
+// checkNoPayments returns ErrShowHasPayments if any order of the show has
+// a payment or a refund
+func checkNoPayments(tx *txn, showID int64) error {
+	var paid int
+	err := tx.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM payments WHERE order_id IN (SELECT id FROM orders WHERE show_id = ?)) +
+			(SELECT COUNT(*) FROM refunds WHERE order_id IN (SELECT id FROM orders WHERE show_id = ?))`,
+		showID, showID).Scan(&paid)
+	if err != nil {
+		return err
+	}
+	if paid > 0 {
+		return ErrShowHasPayments
+	}
+	return nil
+}
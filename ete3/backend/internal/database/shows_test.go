@@ -0,0 +1,231 @@
+package database
+
+import (
+	"ete3/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowScheduling(t *testing.T) {
+	theater := &models.Theater{Name: "Scheduling"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 2, SeatsPerRow: 5}))
+	movie := &models.Movie{Title: "Scheduling", Duration: 100}
+	require.NoError(t, store.CreateMovie(movie, nil))
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+	first := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: start, Price: 10}
+	require.NoError(t, store.CreateShow(first))
+	assert.True(t, first.EndTime.Equal(start.Add(100*time.Minute)))
+
+	stored, err := store.GetShowByID(first.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.StartTime.Equal(start), "stored %v, want %v", stored.StartTime, start)
+
+	// The next show may start once the cleaning buffer has passed
+	tooSoon := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: first.EndTime.Add(CleaningBuffer - time.Minute), Price: 10}
+	err = store.CreateShow(tooSoon)
+	assert.ErrorIs(t, err, ErrShowOverlap)
+	assert.Equal(t, map[string]int64{"show_id": first.ID}, err.(*Error).Details)
+
+	second := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: first.EndTime.Add(CleaningBuffer), Price: 12}
+	require.NoError(t, store.CreateShow(second))
+
+	// A show ending too close to the next one overlaps it as well
+	before := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: start.Add(-100 * time.Minute), Price: 10}
+	assert.ErrorIs(t, store.CreateShow(before), ErrShowOverlap)
+
+	tests := []struct {
+		name    string
+		show    models.Show
+		wantErr error
+	}{
+		{"Past Start", models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: time.Now().Add(-time.Hour)}, ErrShowInPast},
+		{"Unknown Movie", models.Show{MovieID: 99999, TheaterID: theater.ID, StartTime: start.AddDate(0, 1, 0)}, ErrMovieNotFound},
+		{"Unknown Theater", models.Show{MovieID: movie.ID, TheaterID: 99999, StartTime: start.AddDate(0, 1, 0)}, ErrTheaterNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, store.CreateShow(&tt.show), tt.wantErr)
+		})
+	}
+
+	// Moving the second show onto the first is refused, later is fine
+	second.StartTime = start.Add(time.Hour)
+	assert.ErrorIs(t, store.UpdateShow(second), ErrShowOverlap)
+	second.StartTime = start.Add(24 * time.Hour)
+	require.NoError(t, store.UpdateShow(second))
+	stored, err = store.GetShowByID(second.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EndTime.Equal(second.StartTime.Add(100*time.Minute)))
+
+	// Shows with active bookings stay until the bookings are cancelled
+	seats, err := store.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	booking, err := bookSeats(t, 1, first.ID, []int64{seats[0].ID}, "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.DeleteShow(first.ID), ErrShowHasBookings)
+
+	// and keep their time and theater, though prices may change
+	moved := *first
+	moved.StartTime = start.AddDate(0, 0, 7)
+	assert.ErrorIs(t, store.UpdateShow(&moved), ErrShowHasBookings)
+	repriced := *first
+	repriced.Price = 15
+	require.NoError(t, store.UpdateShow(&repriced))
+
+	// and paid ones, cancelled or not, keep their payments on record
+	_, err = store.CancelBooking(booking.BookingID, 1, nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.DeleteShow(first.ID), ErrShowHasPayments)
+
+	// Unpaid orders go with the show
+	hold, err := store.CreateHold(1, second.ID, []int64{seats[0].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.ReleaseHold(hold.BookingID, 1))
+	require.NoError(t, store.DeleteShow(second.ID))
+	_, err = store.GetShowByID(second.ID)
+	assert.ErrorIs(t, err, ErrShowNotFound)
+	assert.ErrorIs(t, store.DeleteShow(second.ID), ErrShowNotFound)
+}
+
+func TestCreateMovieWithShowsIsAtomic(t *testing.T) {
+	theater := &models.Theater{Name: "Atomic"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 1, SeatsPerRow: 5}))
+
+	// The second show starts during the first
+	start := time.Now().Add(72 * time.Hour)
+	shows := []models.Show{
+		{TheaterID: theater.ID, StartTime: start, Price: 10},
+		{TheaterID: theater.ID, StartTime: start.Add(time.Hour), Price: 10},
+	}
+	movie := &models.Movie{Title: "Atomic", Duration: 90}
+	assert.ErrorIs(t, store.CreateMovie(movie, shows), ErrScheduleConflict)
+
+	_, err := store.GetMovieByID(movie.ID)
+	assert.ErrorIs(t, err, ErrMovieNotFound)
+}
+
+func TestScheduleShows(t *testing.T) {
+	theater := &models.Theater{Name: "Bulk"}
+	require.NoError(t, store.CreateTheater(theater, &models.SeatMap{Rows: 1, SeatsPerRow: 5}))
+	movie := &models.Movie{Title: "Bulk", Duration: 90}
+	require.NoError(t, store.CreateMovie(movie, nil))
+
+	day := time.Now().AddDate(0, 0, 10).Truncate(24 * time.Hour)
+	existing := &models.Show{MovieID: movie.ID, TheaterID: theater.ID, StartTime: day.Add(19 * time.Hour), Price: 10}
+	require.NoError(t, store.CreateShow(existing))
+
+	// The first show collides with the existing one, the third with the second
+	shows := []models.Show{
+		{MovieID: movie.ID, TheaterID: theater.ID, StartTime: day.Add(20 * time.Hour), Price: 10},
+		{MovieID: movie.ID, TheaterID: theater.ID, StartTime: day.Add(34 * time.Hour), Price: 10},
+		{MovieID: movie.ID, TheaterID: theater.ID, StartTime: day.Add(35 * time.Hour), Price: 10},
+		{MovieID: movie.ID, TheaterID: theater.ID, StartTime: day.Add(58 * time.Hour), Price: 10},
+	}
+
+	preview, err := store.PreviewShows(shows)
+	require.NoError(t, err)
+	require.Len(t, preview, 4)
+	require.NotNil(t, preview[0].Conflict)
+	assert.Equal(t, "show_overlap", preview[0].Conflict.Code)
+	assert.Equal(t, existing.ID, preview[0].Conflict.ShowID)
+	assert.Nil(t, preview[1].Conflict)
+	require.NotNil(t, preview[2].Conflict)
+	require.NotNil(t, preview[2].Conflict.WithIndex)
+	assert.Equal(t, 1, *preview[2].Conflict.WithIndex)
+	assert.Nil(t, preview[3].Conflict)
+	for _, show := range preview {
+		assert.Zero(t, show.ID)
+		assert.True(t, show.EndTime.Equal(show.StartTime.Add(90*time.Minute)))
+	}
+
+	// Nothing was stored by the preview, and nothing is by a failed commit
+	err = store.CreateShows(shows)
+	assert.ErrorIs(t, err, ErrScheduleConflict)
+	assert.Len(t, err.(*Error).Details, 2)
+	stored, err := store.GetShowsByMovie(movie.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+
+	shows = append(shows[1:2], shows[3])
+	require.NoError(t, store.CreateShows(shows))
+	for _, show := range shows {
+		assert.NotZero(t, show.ID)
+	}
+	stored, err = store.GetShowsByMovie(movie.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+
+	_, err = store.PreviewShows([]models.Show{{MovieID: 99999, TheaterID: theater.ID, StartTime: day.Add(80 * time.Hour)}})
+	assert.ErrorIs(t, err, ErrMovieNotFound)
+}
+
+func TestGetShows(t *testing.T) {
+	theaterA := &models.Theater{Name: "Listing A"}
+	require.NoError(t, store.CreateTheater(theaterA, &models.SeatMap{Rows: 2, SeatsPerRow: 2}))
+	theaterB := &models.Theater{Name: "Listing B"}
+	require.NoError(t, store.CreateTheater(theaterB, &models.SeatMap{Rows: 2, SeatsPerRow: 2}))
+	movieX := &models.Movie{Title: "Listing X", Duration: 90, Genre: "Drama"}
+	require.NoError(t, store.CreateMovie(movieX, nil))
+	movieY := &models.Movie{Title: "Listing Y", Duration: 100}
+	require.NoError(t, store.CreateMovie(movieY, nil))
+
+	day := time.Date(time.Now().Year()+5, time.July, 2, 0, 0, 0, 0, time.Local)
+	early := &models.Show{MovieID: movieX.ID, TheaterID: theaterA.ID, StartTime: day.Add(18 * time.Hour), Price: 10, Prices: map[string]float64{models.StandardCategory.Code: 11}}
+	other := &models.Show{MovieID: movieY.ID, TheaterID: theaterB.ID, StartTime: day.Add(18 * time.Hour), Price: 9}
+	late := &models.Show{MovieID: movieY.ID, TheaterID: theaterA.ID, StartTime: day.Add(21 * time.Hour), Price: 12, Prices: map[string]float64{models.StandardCategory.Code: 13}}
+	for _, show := range []*models.Show{late, other, early} {
+		require.NoError(t, store.CreateShow(show))
+	}
+
+	seats, err := store.GetAllSeatsForTheater(theaterA.ID)
+	require.NoError(t, err)
+	_, err = bookSeats(t, 50, early.ID, []int64{seats[0].ID}, "")
+	require.NoError(t, err)
+	_, err = store.CreateHold(51, early.ID, []int64{seats[1].ID}, "", 10*time.Minute)
+	require.NoError(t, err)
+
+	date := day.Format("2006-01-02")
+	listings, err := store.GetShows(&models.ShowQuery{Date: date})
+	require.NoError(t, err)
+	require.Len(t, listings, 3)
+	assert.Equal(t, []int64{early.ID, other.ID, late.ID}, []int64{listings[0].ID, listings[1].ID, listings[2].ID})
+
+	first := listings[0]
+	assert.Equal(t, movieX.Title, first.Movie.Title)
+	assert.Equal(t, "Drama", first.Movie.Genre)
+	assert.Equal(t, models.TheaterSummary{ID: theaterA.ID, Name: "Listing A", Capacity: 4}, first.Theater)
+	assert.Equal(t, 2, first.SeatsLeft)
+	assert.Equal(t, map[string]float64{models.StandardCategory.Code: 11}, first.Prices)
+	assert.Nil(t, listings[1].Prices)
+	assert.Equal(t, map[string]float64{models.StandardCategory.Code: 13}, listings[2].Prices)
+	assert.Equal(t, 4, listings[2].SeatsLeft)
+
+	tests := []struct {
+		name  string
+		query models.ShowQuery
+		want  []int64
+	}{
+		{"From", models.ShowQuery{Date: date, From: "20:00"}, []int64{late.ID}},
+		{"Theater", models.ShowQuery{Date: date, TheaterID: theaterB.ID}, []int64{other.ID}},
+		{"Movie Before", models.ShowQuery{Date: date, MovieID: movieY.ID, To: "20:00"}, []int64{other.ID}},
+		{"Another Day", models.ShowQuery{Date: day.AddDate(0, 0, 1).Format("2006-01-02"), TheaterID: theaterA.ID}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listings, err := store.GetShows(&tt.query)
+			require.NoError(t, err)
+			var ids []int64
+			for _, l := range listings {
+				ids = append(ids, l.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	_, err = store.GetShows(&models.ShowQuery{From: "late"})
+	assert.Equal(t, KindInvalid, err.(*Error).Kind)
+}
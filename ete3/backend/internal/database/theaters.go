@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"ete3/internal/models"
+)
+
+// GetTheaters returns every theater ordered by name
+func (s *Store) GetTheaters() ([]models.Theater, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, capacity, created_at, updated_at
+		FROM theaters
+		ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var theaters []models.Theater
+	for rows.Next() {
+		var t models.Theater
+		if err := rows.Scan(&t.ID, &t.Name, &t.Capacity, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		theaters = append(theaters, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range theaters {
+		if theaters[i].Categories, err = s.theaterCategories(theaters[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return theaters, nil
+}
+
+// theaterCategories returns the seat categories of a theater, starting with
+// the standard one
+func (s *Store) theaterCategories(theaterID int64) ([]models.SeatCategory, error) {
+	rows, err := s.db.Query(`
+		SELECT code, name FROM seat_categories
+		WHERE theater_id = ?
+		ORDER BY code`, theaterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.SeatCategory{models.StandardCategory}
+	for rows.Next() {
+		var c models.SeatCategory
+		if err := rows.Scan(&c.Code, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// CreateTheater adds a theater and, if seatMap is not nil, its seats and
+// seat categories. The theater's ID, Capacity and Categories are set from
+// what was stored.
+func (s *Store) CreateTheater(theater *models.Theater, seatMap *models.SeatMap) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	theater.ID, err = tx.Insert(`
+		INSERT INTO theaters (name, capacity)
+		VALUES (?, 0)`,
+		theater.Name)
+	if err != nil {
+		return err
+	}
+
+	theater.Capacity = 0
+	theater.Categories = []models.SeatCategory{models.StandardCategory}
+	if seatMap != nil {
+		if theater.Capacity, theater.Categories, err = addSeats(tx, theater.ID, seatMap); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTheater renames a theater. It returns ErrTheaterNotFound if there
+// is no such theater.
+func (s *Store) UpdateTheater(theater *models.Theater) error {
+	result, err := s.db.Exec(`
+		UPDATE theaters
+		SET name = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		theater.Name, theater.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrTheaterNotFound
+	}
+	return nil
+}
+
+// DeleteTheater removes a theater with its seats and seat categories. It returns
+// ErrTheaterNotFound if there is no such theater and ErrTheaterInUse if
+// any show, past or future, was scheduled in it.
+func (s *Store) DeleteTheater(theaterID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTheaterUnused(tx, theaterID); err != nil {
+		return err
+	}
+
+	if err := removeSeats(tx, theaterID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM theaters WHERE id = ?`, theaterID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetSeatMap replaces a theater's seats and seat categories with the ones
+// described by seatMap and returns the updated theater. Seats can only be replaced
+// before any show is scheduled, since bookings refer to them.
+func (s *Store) SetSeatMap(theaterID int64, seatMap *models.SeatMap) (*models.Theater, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTheaterUnused(tx, theaterID); err != nil {
+		return nil, err
+	}
+
+	if err := removeSeats(tx, theaterID); err != nil {
+		return nil, err
+	}
+	if _, _, err := addSeats(tx, theaterID, seatMap); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetTheaterByID(theaterID)
+}
+
+// checkTheaterUnused returns ErrTheaterNotFound if the theater doesn't
+// exist and ErrTheaterInUse if any show refers to it
+func checkTheaterUnused(tx *txn, theaterID int64) error {
+	var shows int
+	err := tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM shows WHERE theater_id = t.id)
+		FROM theaters t
+		WHERE t.id = ?`, theaterID).Scan(&shows)
+	if err == sql.ErrNoRows {
+		return ErrTheaterNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if shows > 0 {
+		return ErrTheaterInUse
+	}
+	return nil
+}
+
+// addSeats adds the seats and seat categories of seatMap to a theater that
+// has none and sets its capacity to the number of seats. It returns the
+// capacity and the categories.
+func addSeats(tx *txn, theaterID int64, seatMap *models.SeatMap) (int, []models.SeatCategory, error) {
+	positions, err := seatMap.Positions()
+	if err != nil {
+		return 0, nil, ErrInvalidSeatMap.WithDetails(err.Error())
+	}
+	categories, codes, err := seatMap.SeatCategories()
+	if err != nil {
+		return 0, nil, ErrInvalidSeatMap.WithDetails(err.Error())
+	}
+
+	// The standard category is implicit
+	for _, c := range categories[1:] {
+		_, err := tx.Exec(`
+			INSERT INTO seat_categories (theater_id, code, name)
+			VALUES (?, ?, ?)`,
+			theaterID, c.Code, c.Name)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for _, p := range positions {
+		category, ok := codes[p]
+		if !ok {
+			category = models.StandardCategory.Code
+		}
+		_, err := tx.Exec(`
+			INSERT INTO seats (theater_id, row_number, seat_number, category)
+			VALUES (?, ?, ?, ?)`,
+			theaterID, p.Row, p.Seat, category)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE theaters
+		SET capacity = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		len(positions), theaterID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(positions), categories, nil
+}
+
+// removeSeats deletes the seats and seat categories of a theater
+func removeSeats(tx *txn, theaterID int64) error {
+	if _, err := tx.Exec(`DELETE FROM seats WHERE theater_id = ?`, theaterID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM seat_categories WHERE theater_id = ?`, theaterID)
+	return err
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"encoding/json"
+	"ete3/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTheaterLifecycle(t *testing.T) {
+	theater := &models.Theater{Name: "Studio 2"}
+	seatMap := &models.SeatMap{
+		Rows:        4,
+		SeatsPerRow: 6,
+		Aisles:      []int{3},
+		Gaps:        []models.SeatPosition{{Row: 4, Seat: 6}},
+	}
+	require.NoError(t, store.CreateTheater(theater, seatMap))
+	assert.NotZero(t, theater.ID)
+	// 4 rows of 5 seats beside the aisle, less one gap
+	assert.Equal(t, 19, theater.Capacity)
+
+	seats, err := store.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	assert.Len(t, seats, 19)
+	for _, seat := range seats {
+		assert.NotEqual(t, 3, seat.SeatNumber, "aisle position should stay empty")
+	}
+
+	// Replacing the seat map recomputes the capacity
+	updated, err := store.SetSeatMap(theater.ID, &models.SeatMap{Rows: 2, SeatsPerRow: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 20, updated.Capacity)
+	seats, err = store.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	assert.Len(t, seats, 20)
+
+	_, err = store.SetSeatMap(theater.ID, &models.SeatMap{Rows: 1, SeatsPerRow: 2, Aisles: []int{1, 2}})
+	assert.ErrorIs(t, err, ErrInvalidSeatMap)
+
+	theater.Name = "Studio Two"
+	require.NoError(t, store.UpdateTheater(theater))
+	stored, err := store.GetTheaterByID(theater.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Studio Two", stored.Name)
+
+	require.NoError(t, store.DeleteTheater(theater.ID))
+	_, err = store.GetTheaterByID(theater.ID)
+	assert.ErrorIs(t, err, ErrTheaterNotFound)
+	assert.ErrorIs(t, store.DeleteTheater(theater.ID), ErrTheaterNotFound)
+}
+
+func TestTheaterWithShowsIsInUse(t *testing.T) {
+	show, _ := createTestShow(t)
+
+	_, err := store.SetSeatMap(show.TheaterID, &models.SeatMap{Rows: 1, SeatsPerRow: 1})
+	assert.ErrorIs(t, err, ErrTheaterInUse)
+	assert.ErrorIs(t, store.DeleteTheater(show.TheaterID), ErrTheaterInUse)
+
+	// The theater's capacity matches its seats
+	theater, err := store.GetTheaterByID(show.TheaterID)
+	require.NoError(t, err)
+	seats, err := store.GetAllSeatsForTheater(show.TheaterID)
+	require.NoError(t, err)
+	assert.Equal(t, len(seats), theater.Capacity)
+}
+
+func TestSeatCategoryPricing(t *testing.T) {
+	theater := &models.Theater{Name: "Premium"}
+	seatMap := &models.SeatMap{
+		Rows:        2,
+		SeatsPerRow: 3,
+		Categories: []models.CategorySection{
+			{SeatCategory: models.SeatCategory{Code: "R", Name: "Recliner"}, Rows: []int{2}},
+			{SeatCategory: models.SeatCategory{Code: "W", Name: "Wheelchair"}, Seats: []models.SeatPosition{{Row: 1, Seat: 3}}},
+		},
+	}
+	require.NoError(t, store.CreateTheater(theater, seatMap))
+	assert.Equal(t, []models.SeatCategory{models.StandardCategory, {Code: "R", Name: "Recliner"}, {Code: "W", Name: "Wheelchair"}}, theater.Categories)
+
+	stored, err := store.GetTheaterByID(theater.ID)
+	require.NoError(t, err)
+	assert.Equal(t, theater.Categories, stored.Categories)
+
+	seats, err := store.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	require.Len(t, seats, 6)
+	assert.Equal(t, []string{"A", "A", "W", "R", "R", "R"}, []string{
+		seats[0].Category, seats[1].Category, seats[2].Category, seats[3].Category, seats[4].Category, seats[5].Category,
+	})
+
+	movie := &models.Movie{Title: "Premium", Duration: 90}
+	require.NoError(t, store.CreateMovie(movie, nil))
+	show := &models.Show{
+		MovieID: movie.ID, TheaterID: theater.ID, StartTime: time.Now().Add(24 * time.Hour), Price: 10,
+		Prices: map[string]float64{"P": 20},
+	}
+	err = store.CreateShow(show)
+	assert.ErrorIs(t, err, ErrUnknownSeatCategory)
+	assert.Equal(t, map[string]string{"category": "P"}, err.(*Error).Details)
+
+	// Wheelchair spaces have no price of their own and cost the show price
+	show.Prices = map[string]float64{"R": 18.5}
+	require.NoError(t, store.CreateShow(show))
+	storedShow, err := store.GetShowByID(show.ID)
+	require.NoError(t, err)
+	assert.Equal(t, show.Prices, storedShow.Prices)
+
+	booking, err := bookSeats(t, 1, show.ID, []int64{seats[0].ID, seats[2].ID, seats[3].ID}, "")
+	require.NoError(t, err)
+	require.Len(t, booking.Seats, 3)
+	assert.Equal(t, 10.0, booking.Seats[0].Price)
+	assert.Equal(t, 10.0, booking.Seats[1].Price)
+	assert.Equal(t, 18.5, booking.Seats[2].Price)
+	assert.Equal(t, 38.5, booking.Total)
+
+	layout, err := store.GetTheaterLayout(show.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"A": 10, "R": 18.5, "W": 10}, layout.Prices)
+	data, err := json.Marshal(layout)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"layout":"BAB|BRR|"`)
+}
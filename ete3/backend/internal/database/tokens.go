@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CreateRefreshToken stores the hash of a new refresh token. familyID groups
+// every token rotated from the same login.
+func (s *Store) CreateRefreshToken(userID int64, familyID, tokenHash string, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		userID, familyID, tokenHash, expiresAt.UTC())
+	return err
+}
+
+// RotateRefreshToken revokes the token identified by oldHash and stores
+// newHash in the same family, returning the token's user ID. Presenting a
+// token that was already revoked revokes the entire family.
+func (s *Store) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id, userID int64
+	var familyID string
+	var tokenExpiry time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = ?`, oldHash).Scan(&id, &userID, &familyID, &tokenExpiry, &revokedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if revokedAt.Valid {
+		return 0, reusedRefreshToken(tx, familyID)
+	}
+	if time.Now().After(tokenExpiry) {
+		return 0, ErrRefreshTokenInvalid
+	}
+
+	// A concurrent rotation of the same token may have revoked it since it
+	// was read; only the request whose update lands gets the successor
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, reusedRefreshToken(tx, familyID)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		userID, familyID, newHash, expiresAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// RevokeRefreshToken revokes every token in the family of tokenHash, ending
+// that login session
+func (s *Store) RevokeRefreshToken(tokenHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familyID string
+	err = tx.QueryRow(`
+		SELECT family_id FROM refresh_tokens WHERE token_hash = ?`, tokenHash).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := revokeFamily(tx, familyID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reusedRefreshToken revokes the family of a token presented after it was
+// rotated and returns ErrRefreshTokenReused
+func reusedRefreshToken(tx *txn, familyID string) error {
+	if err := revokeFamily(tx, familyID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func revokeFamily(tx *txn, familyID string) error {
+	_, err := tx.Exec(`
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = ? AND revoked_at IS NULL`, familyID)
+	return err
+}
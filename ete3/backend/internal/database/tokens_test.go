@@ -0,0 +1,107 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	assert.NoError(t, store.CreateRefreshToken(1, "family-rotate", "hash-1", expiry))
+
+	userID, err := store.RotateRefreshToken("hash-1", "hash-2", expiry)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+
+	userID, err = store.RotateRefreshToken("hash-2", "hash-3", expiry)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+
+	_, err = store.RotateRefreshToken("unknown", "hash-4", expiry)
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	assert.NoError(t, store.CreateRefreshToken(1, "family-reuse", "reuse-1", expiry))
+
+	_, err := store.RotateRefreshToken("reuse-1", "reuse-2", expiry)
+	assert.NoError(t, err)
+
+	// Replaying the rotated token kills the whole family...
+	_, err = store.RotateRefreshToken("reuse-1", "reuse-3", expiry)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// ...including the legitimate successor
+	_, err = store.RotateRefreshToken("reuse-2", "reuse-4", expiry)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+}
+
+func TestConcurrentRefreshTokenRotation(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, store.CreateRefreshToken(1, "family-race", "race-0", expiry))
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var winners []string
+	var errs []error
+
+	for i := 1; i <= attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			newHash := fmt.Sprintf("race-%d", i)
+			_, err := store.RotateRefreshToken("race-0", newHash, expiry)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				winners = append(winners, newHash)
+			case !errors.Is(err, ErrRefreshTokenReused):
+				errs = append(errs, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Empty(t, errs)
+	// The token was rotated exactly once, and the losers' replays revoked
+	// the family, the winner's successor included
+	require.Len(t, winners, 1)
+	_, err := store.RotateRefreshToken(winners[0], "race-next", expiry)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	var active int
+	err = store.db.QueryRow(`
+		SELECT COUNT(*) FROM refresh_tokens
+		WHERE family_id = ? AND revoked_at IS NULL`, "family-race").Scan(&active)
+	require.NoError(t, err)
+	assert.Equal(t, 0, active)
+}
+
+func TestExpiredRefreshToken(t *testing.T) {
+	assert.NoError(t, store.CreateRefreshToken(1, "family-expired", "expired-1", time.Now().Add(-time.Minute)))
+
+	_, err := store.RotateRefreshToken("expired-1", "expired-2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	assert.NoError(t, store.CreateRefreshToken(1, "family-logout", "logout-1", expiry))
+
+	assert.NoError(t, store.RevokeRefreshToken("logout-1"))
+
+	_, err := store.RotateRefreshToken("logout-1", "logout-2", expiry)
+	assert.Error(t, err)
+
+	assert.ErrorIs(t, store.RevokeRefreshToken("unknown"), ErrRefreshTokenInvalid)
+}
@@ -1,70 +1,173 @@
 package handlers
 
 import (
+	"errors"
 	"ete3/internal/database"
 	"ete3/internal/models"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func Register(c *gin.Context) {
+// dummyPasswordHash is compared against when the username is unknown so that
+// a failed login takes as long whether or not the user exists
+var dummyPasswordHash = []byte("$2a$10$FHMe4Topt4lzBhGqYbKx2e8efCcGvlD2Ng5MuRpSKcvfAo62LkGMy")
+
+func (h *Handler) Register(c *gin.Context) {
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	err := database.CreateUser(&req)
+	err := h.users.CreateUser(&req, models.RoleCustomer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	user, err := database.GetUserByUsername(req.Username)
+	user, err := h.users.GetUserByUsername(req.Username)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		c.Error(errInvalidCredentials)
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		c.Error(errInvalidCredentials)
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// Start a new token family for this login
+	familyID, err := newTokenFamily()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.issueTokenPair(user, familyID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response.User = user
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+// The presented token is revoked; presenting it again revokes the session.
+func (h *Handler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte("your-secret-key")) // In production, use environment variable
+	refreshToken, refreshHash, err := newRefreshToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-		},
+	userID, err := h.users.RotateRefreshToken(hashRefreshToken(req.RefreshToken), refreshHash,
+		time.Now().Add(h.jwt.RefreshTTL))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.users.GetUserByID(userID)
+	if err != nil {
+		c.Error(database.ErrRefreshTokenInvalid)
+		return
+	}
+
+	accessToken, err := h.jwt.issueToken(Claims{UserID: user.ID, Username: user.Username, Role: user.Role})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.jwt.TokenTTL.Seconds()),
 	})
 }
+
+// Logout revokes the session that a refresh token belongs to
+func (h *Handler) Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := h.users.RevokeRefreshToken(hashRefreshToken(req.RefreshToken))
+	if err != nil && !errors.Is(err, database.ErrRefreshTokenInvalid) {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// SetUserRole changes a user's role, granting or taking away staff and
+// admin rights. Admins can't change their own role, so there is always an
+// admin left to undo a mistake. The user's current access token keeps its
+// role until it's refreshed.
+func (h *Handler) SetUserRole(c *gin.Context) {
+	userID, ok := idParam(c, "id", "user")
+	if !ok {
+		return
+	}
+	var req models.RoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if userID == currentUserID(c) {
+		c.Error(invalidRequest("You can't change your own role"))
+		return
+	}
+
+	user, err := h.users.SetUserRole(userID, req.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// issueTokenPair signs an access token for user and stores a new refresh
+// token in familyID
+func (h *Handler) issueTokenPair(user *models.User, familyID string) (*models.TokenResponse, error) {
+	accessToken, err := h.jwt.issueToken(Claims{UserID: user.ID, Username: user.Username, Role: user.Role})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = h.users.CreateRefreshToken(user.ID, familyID, refreshHash, time.Now().Add(h.jwt.RefreshTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.jwt.TokenTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"ete3/internal/models"
+	"ete3/internal/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// postJSON sends body as JSON to path and returns the recorded response
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthFlow(t *testing.T) {
+	h := New(repository.NewMemory().Repositories(), testJWT)
+	router := setupRouter()
+	router.POST("/register", h.Register)
+	router.POST("/login", h.Login)
+	router.POST("/refresh", h.Refresh)
+	router.POST("/logout", h.Logout)
+
+	register := models.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1"}
+	assert.Equal(t, http.StatusCreated, postJSON(router, "/register", register).Code)
+	assert.Equal(t, http.StatusConflict, postJSON(router, "/register", register).Code)
+
+	wrong := models.LoginRequest{Username: "alice", Password: "wrong-password"}
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/login", wrong).Code)
+	unknown := models.LoginRequest{Username: "mallory", Password: "secret1"}
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/login", unknown).Code)
+
+	// Unknown usernames pay for a full bcrypt comparison too
+	cost, err := bcrypt.Cost(dummyPasswordHash)
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+
+	w := postJSON(router, "/login", models.LoginRequest{Username: "alice", Password: "secret1"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var login models.TokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.Equal(t, models.RoleCustomer, login.User.Role)
+
+	claims, err := testJWT.parseToken(login.Token)
+	require.NoError(t, err)
+	assert.Equal(t, login.User.ID, claims.UserID)
+
+	// Rotating issues a new pair; replaying the old token revokes the session
+	w = postJSON(router, "/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	require.Equal(t, http.StatusOK, w.Code)
+	var refreshed models.TokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	w = postJSON(router, "/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postJSON(router, "/refresh", models.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Equal(t, http.StatusOK, postJSON(router, "/logout", models.RefreshRequest{RefreshToken: "unknown"}).Code)
+}
+
+func TestSetUserRole(t *testing.T) {
+	store := repository.NewMemory()
+	register := models.RegisterRequest{Username: "bob", Email: "bob@example.com", Password: "secret1"}
+	require.NoError(t, store.CreateUser(&register, models.RoleCustomer))
+	bob, err := store.GetUserByUsername("bob")
+	require.NoError(t, err)
+
+	h := New(store.Repositories(), testJWT)
+	router := setupRouter()
+	router.POST("/login", h.Login)
+	router.PUT("/users/:id/role", h.AuthMiddleware(), RequireRole(models.RoleAdmin), h.SetUserRole)
+	setRole := func(claims Claims, path, role string) *httptest.ResponseRecorder {
+		t.Helper()
+		token, err := testJWT.issueToken(claims)
+		require.NoError(t, err)
+		body, _ := json.Marshal(models.RoleRequest{Role: role})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	admin := Claims{UserID: 100, Role: models.RoleAdmin}
+	path := fmt.Sprintf("/users/%d/role", bob.ID)
+
+	// Only admins assign roles
+	assert.Equal(t, http.StatusForbidden, setRole(Claims{UserID: 7, Role: models.RoleStaff}, path, models.RoleStaff).Code)
+
+	w := setRole(admin, path, models.RoleStaff)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var user models.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, models.RoleStaff, user.Role)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	// The next login carries the new role
+	w = postJSON(router, "/login", models.LoginRequest{Username: "bob", Password: "secret1"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var login models.TokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	claims, err := testJWT.parseToken(login.Token)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleStaff, claims.Role)
+
+	assert.Equal(t, http.StatusBadRequest, setRole(admin, path, "manager").Code)
+	assert.Equal(t, http.StatusBadRequest, setRole(admin, "/users/100/role", models.RoleCustomer).Code)
+	assert.Equal(t, http.StatusNotFound, setRole(admin, "/users/999/role", models.RoleStaff).Code)
+}
@@ -1,299 +1,345 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"ete3/internal/database"
 	"ete3/internal/models"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetMovies returns all available movies
-func GetMovies(c *gin.Context) {
-	movies, err := database.GetMovies()
+// GetMovies returns a page of the movie catalogue, searched, filtered and
+// sorted as the query string asks
+func (h *Handler) GetMovies(c *gin.Context) {
+	var query models.MovieQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	if err := query.Validate(); err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return
+	}
+
+	page, err := h.movies.GetMovies(&query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, movies)
+	c.JSON(http.StatusOK, page)
 }
 
 // GetShowsByMovie returns all shows for a specific movie
-func GetShowsByMovie(c *gin.Context) {
-	movieIDStr := c.Param("id")
-	if movieIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+func (h *Handler) GetShowsByMovie(c *gin.Context) {
+	movieID, ok := idParam(c, "id", "movie")
+	if !ok {
 		return
 	}
 
-	movieID, err := strconv.ParseInt(movieIDStr, 10, 64)
+	shows, err := h.shows.GetShowsByMovie(movieID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+		c.Error(err)
 		return
 	}
+	c.JSON(http.StatusOK, shows)
+}
 
-	shows, err := database.GetShowsByMovie(movieID)
+// GetShows returns the shows of a day across all movies, filtered by the
+// query string, with their movie, theater and free seat count
+func (h *Handler) GetShows(c *gin.Context) {
+	var query models.ShowQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	if _, _, err := query.Window(time.Now()); err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return
+	}
+
+	shows, err := h.shows.GetShows(&query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shows"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, shows)
 }
 
 // GetAvailableSeats returns all available seats for a specific show
-func GetAvailableSeats(c *gin.Context) {
-	showIDStr := c.Param("id")
-	if showIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Show ID is required"})
+func (h *Handler) GetAvailableSeats(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
 		return
 	}
 
-	showID, err := strconv.ParseInt(showIDStr, 10, 64)
+	seats, err := h.seats.GetAvailableSeats(showID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID"})
-		return
-	}
-
-	seats, err := database.GetAvailableSeats(showID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch available seats"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, seats)
 }
 
-// CreateBooking creates a new booking
-func CreateBooking(c *gin.Context) {
+// CreateBooking books seats and pays for them in one step: the seats are
+// held, the total is charged to the request's payment token and the hold
+// is confirmed. If the payment doesn't go through the seats are released.
+func (h *Handler) CreateBooking(c *gin.Context) {
 	var req models.BookingRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID := currentUserID(c)
+	hold, err := h.bookings.CreateHold(userID, req.ShowID, req.SeatIDs, req.PromoCode, holdTTL)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	response, err := database.CreateBooking(req.ShowID, req.SeatIDs)
+	response, err := h.payAndConfirm(c, hold.BookingID, hold.Total, req.PaymentToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		if releaseErr := h.bookings.ReleaseHold(hold.BookingID, userID); releaseErr != nil {
+			log.Printf("Releasing hold %d after a failed booking failed: %v", hold.BookingID, releaseErr)
+		}
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// GetBookings returns all bookings
-func GetBookings(c *gin.Context) {
-	bookings, err := database.GetBookings()
+// holdTTL is how long held seats stay reserved before they are released
+const holdTTL = 10 * time.Minute
+
+// CreateHold temporarily reserves seats until the hold is confirmed or expires
+func (h *Handler) CreateHold(c *gin.Context) {
+	var req models.BookingRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.bookings.CreateHold(currentUserID(c), req.ShowID, req.SeatIDs, req.PromoCode, holdTTL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookings"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, bookings)
+
+	c.JSON(http.StatusCreated, response)
 }
 
-// CancelBooking cancels a specific booking
-func CancelBooking(c *gin.Context) {
-	bookingIDStr := c.Param("id")
-	if bookingIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking ID is required"})
+// ConfirmHold pays for a pending hold with the payment token in the
+// optional body and turns it into confirmed bookings. The hold stays in
+// place if the payment doesn't go through, so it can be retried.
+func (h *Handler) ConfirmHold(c *gin.Context) {
+	holdID, ok := idParam(c, "id", "hold")
+	if !ok {
 		return
 	}
 
-	bookingID, err := strconv.ParseInt(bookingIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+	var req models.PaymentRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
 		return
 	}
 
-	err = database.CancelBooking(bookingID)
+	hold, err := h.bookings.GetOrder(holdID, currentUserID(c))
+	if errors.Is(err, database.ErrBookingNotFound) {
+		err = database.ErrHoldNotFound
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found or already cancelled"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		c.Error(err)
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled successfully"})
-}
-
-// CreateMovie creates a new movie
-func CreateMovie(c *gin.Context) {
-	var movie models.Movie
-	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if hold.Status == "expired" || (hold.Status == "pending" && hold.ExpiresAt != nil && !hold.ExpiresAt.After(time.Now())) {
+		c.Error(database.ErrHoldExpired)
+		return
+	}
+	if hold.Status != "pending" {
+		c.Error(database.ErrHoldNotFound)
 		return
 	}
 
-	if err := database.CreateMovie(&movie); err != nil {
-		log.Printf("Error creating movie: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create movie: " + err.Error()})
+	response, err := h.payAndConfirm(c, holdID, hold.Total(), req.PaymentToken)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, movie)
+	c.JSON(http.StatusOK, response)
 }
 
-// UpdateMovie updates an existing movie
-func UpdateMovie(c *gin.Context) {
-	movieIDStr := c.Param("id")
-	if movieIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+// GetBookings returns the current user's bookings
+func (h *Handler) GetBookings(c *gin.Context) {
+	bookings, err := h.bookings.GetBookings(currentUserID(c))
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	c.JSON(http.StatusOK, bookings)
+}
 
-	movieID, err := strconv.ParseInt(movieIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+// CancelBooking cancels a booking and all of its seats and refunds as much
+// of its payment as the refund policy allows. Staff may override the
+// policy with a refund percentage and a reason, which are kept on the
+// refund for the audit trail.
+func (h *Handler) CancelBooking(c *gin.Context) {
+	bookingID, ok := idParam(c, "id", "booking")
+	if !ok {
 		return
 	}
 
-	var movie models.Movie
-	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	override, ok := refundOverride(c)
+	if !ok {
 		return
 	}
 
-	movie.ID = movieID
-	if err := database.UpdateMovie(&movie); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update movie"})
+	refund, err := h.bookings.CancelBooking(bookingID, currentUserID(c), override)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	if refund.Status == models.RefundPending {
+		h.issueRefund(c.Request.Context(), refund)
+	}
 
-	c.JSON(http.StatusOK, movie)
+	c.JSON(http.StatusOK, models.CancelResponse{
+		BookingID: bookingID,
+		Status:    "cancelled",
+		Message:   "Booking cancelled successfully",
+		Refund:    refund,
+	})
 }
 
-// GetMovie retrieves a movie by ID
-func GetMovie(c *gin.Context) {
-	movieIDStr := c.Param("id")
-	if movieIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+// CancelBookingSeat cancels a single seat of a booking and refunds its
+// share of the payment like CancelBooking
+func (h *Handler) CancelBookingSeat(c *gin.Context) {
+	bookingID, ok := idParam(c, "id", "booking")
+	if !ok {
 		return
 	}
-
-	movieID, err := strconv.ParseInt(movieIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+	seatID, ok := idParam(c, "seatId", "seat")
+	if !ok {
+		return
+	}
+	override, ok := refundOverride(c)
+	if !ok {
 		return
 	}
 
-	movie, err := database.GetMovieByID(movieID)
+	refund, err := h.bookings.CancelBookingSeat(bookingID, seatID, currentUserID(c), override)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movie"})
+		c.Error(err)
 		return
 	}
+	if refund.Status == models.RefundPending {
+		h.issueRefund(c.Request.Context(), refund)
+	}
 
-	c.JSON(http.StatusOK, movie)
+	c.JSON(http.StatusOK, gin.H{"message": "Seat cancelled successfully", "refund": refund})
 }
 
-// GetTheaterLayout returns the layout of a theater with seat status for a specific show
-func GetTheaterLayout(c *gin.Context) {
-	showIDStr := c.Param("id")
-	if showIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Show ID is required"})
-		return
+// refundOverride reads the optional CancelRequest body of a cancellation.
+// Only staff may override the refund policy, and only with a reason. It
+// records an error and returns false if the request is refused.
+func refundOverride(c *gin.Context) (*models.RefundOverride, bool) {
+	var req models.CancelRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return nil, false
+	}
+	if req.RefundPercent == nil {
+		return nil, true
 	}
 
-	showID, err := strconv.ParseInt(showIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID"})
+	if role := currentUserRole(c); role != models.RoleStaff && role != models.RoleAdmin {
+		c.Error(errForbidden)
+		return nil, false
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.Error(invalidRequest("A reason is required to override the refund policy"))
+		return nil, false
+	}
+	return &models.RefundOverride{Percent: *req.RefundPercent, Reason: reason, IssuedBy: currentUserID(c)}, true
+}
+
+// CreateMovie creates a new movie and the shows of its optional schedule
+func (h *Handler) CreateMovie(c *gin.Context) {
+	var req models.CreateMovieRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Get show information to get theater ID
-	show, err := database.GetShowByID(showID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+	var shows []models.Show
+	if req.Schedule != nil {
+		if req.Schedule.MovieID != 0 {
+			c.Error(invalidRequest("schedule.movie_id must be left out, the shows are for the new movie"))
+			return
+		}
+		var err error
+		if shows, err = req.Schedule.Shows(time.Local); err != nil {
+			c.Error(database.ErrInvalidSchedule.WithDetails(err.Error()))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch show"})
-		return
 	}
 
-	// Get theater information
-	theater, err := database.GetTheaterByID(show.TheaterID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch theater"})
+	movie := req.Movie
+	if err := h.movies.CreateMovie(&movie, shows); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Get all seats for the theater
-	seats, err := database.GetAllSeatsForTheater(show.TheaterID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seats"})
+	c.JSON(http.StatusCreated, models.MovieWithShows{Movie: movie, Shows: shows})
+}
+
+// UpdateMovie updates an existing movie
+func (h *Handler) UpdateMovie(c *gin.Context) {
+	movieID, ok := idParam(c, "id", "movie")
+	if !ok {
 		return
 	}
 
-	// Get booked seats for the show
-	bookedSeats, err := database.GetBookedSeatsForShow(showID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booked seats"})
+	var movie models.Movie
+	if !bindJSON(c, &movie) {
 		return
 	}
 
-	// Create a map of booked seat IDs for quick lookup
-	bookedSeatMap := make(map[int64]bool)
-	for _, seat := range bookedSeats {
-		bookedSeatMap[seat.ID] = true
+	movie.ID = movieID
+	if err := h.movies.UpdateMovie(&movie); err != nil {
+		c.Error(err)
+		return
 	}
 
-	// Find max row and column to determine theater dimensions
-	maxRow, maxCol := 0, 0
-	for _, seat := range seats {
-		if seat.RowNumber > maxRow {
-			maxRow = seat.RowNumber
-		}
-		if seat.SeatNumber > maxCol {
-			maxCol = seat.SeatNumber
-		}
+	c.JSON(http.StatusOK, movie)
+}
+
+// GetMovie retrieves a movie by ID
+func (h *Handler) GetMovie(c *gin.Context) {
+	movieID, ok := idParam(c, "id", "movie")
+	if !ok {
+		return
 	}
 
-	// Create theater layout
-	layout := models.TheaterLayout{
-		TheaterID: theater.ID,
-		Name:      theater.Name,
-		Rows:      maxRow,
-		Columns:   maxCol,
-		Layout:    make([][]models.SeatStatus, maxRow),
-	}
-
-	// Initialize the layout with all seats marked as unavailable
-	for i := range layout.Layout {
-		layout.Layout[i] = make([]models.SeatStatus, maxCol)
-		for j := range layout.Layout[i] {
-			layout.Layout[i][j] = models.SeatStatus{
-				Row:    i + 1,
-				Column: j + 1,
-				Status: "unavailable",
-			}
-		}
+	movie, err := h.movies.GetMovieByID(movieID)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	// Update the layout with actual seats and their status
-	for _, seat := range seats {
-		row := seat.RowNumber - 1
-		col := seat.SeatNumber - 1
+	c.JSON(http.StatusOK, movie)
+}
 
-		if row >= 0 && row < maxRow && col >= 0 && col < maxCol {
-			status := "available"
-			if bookedSeatMap[seat.ID] {
-				status = "booked"
-			}
+// GetTheaterLayout returns the layout of a theater with seat status for a specific show
+func (h *Handler) GetTheaterLayout(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
+		return
+	}
 
-			layout.Layout[row][col] = models.SeatStatus{
-				ID:     seat.ID,
-				Row:    seat.RowNumber,
-				Column: seat.SeatNumber,
-				Status: status,
-			}
-		}
+	layout, err := h.seats.GetTheaterLayout(showID)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, layout)
@@ -4,34 +4,159 @@ import (
 	"bytes"
 	"encoding/json"
 	"ete3/internal/models"
+	"ete3/internal/repository"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
+	r.Use(RequestID(), ErrorHandler())
 	return r
 }
 
+// newTestHandler returns a Handler backed by an in-memory store holding
+// movie 1, show 1 tomorrow and booking 1 for seat 20, owned by the
+// anonymous user 0 that unauthenticated test routes act as
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	store := repository.NewMemory()
+
+	movie := &models.Movie{Title: "Seeded Movie", Duration: 120}
+	require.NoError(t, store.CreateMovie(movie, nil))
+	theater := store.AddTheater("Main Theater", 5, 10)
+	show := store.AddShow(movie.ID, theater.ID, time.Now().Add(24*time.Hour), 10.0)
+	bookSeats(t, store, 0, show.ID, 20)
+
+	return New(store.Repositories(), testJWT)
+}
+
+// bookSeats books seats in store the way customers do: a hold, a captured
+// payment of its total and the confirmation
+func bookSeats(t *testing.T, store *repository.Memory, userID, showID int64, seatIDs ...int64) *models.BookingResponse {
+	t.Helper()
+	hold, err := store.CreateHold(userID, showID, seatIDs, "", 10*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.CreatePayment(&models.Payment{
+		OrderID:     hold.BookingID,
+		Provider:    "test",
+		ProviderRef: fmt.Sprintf("test_%d", hold.BookingID),
+		Amount:      hold.Total,
+		Status:      models.PaymentCaptured,
+	}))
+	booking, err := store.ConfirmHold(hold.BookingID, userID)
+	require.NoError(t, err)
+	return booking
+}
+
 func TestGetMovies(t *testing.T) {
 	router := setupRouter()
-	router.GET("/api/cinema/movies", GetMovies)
+	h := newTestHandler(t)
+	router.GET("/api/cinema/movies", h.GetMovies)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/cinema/movies", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	var page models.MoviePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Movies, 1)
+	assert.Equal(t, "Seeded Movie", page.Movies[0].Title)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestSearchMovies(t *testing.T) {
+	store := repository.NewMemory()
+	for _, movie := range []models.Movie{
+		{Title: "Moon Child", Description: "A lunar drama", Duration: 100, Genre: "Drama"},
+		{Title: "Sun Child", Description: "Bright and loud", Duration: 130, Genre: "Action"},
+		{Title: "Mooncake", Description: "A child's feast", Duration: 90, Genre: "Drama"},
+	} {
+		movie := movie
+		require.NoError(t, store.CreateMovie(&movie, nil))
+	}
+	theater := store.AddTheater("Search Theater", 2, 2)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	store.AddShow(2, theater.ID, tomorrow, 10)
+
+	router := setupRouter()
+	router.GET("/movies", New(store.Repositories(), testJWT).GetMovies)
+	get := func(query string) (*httptest.ResponseRecorder, models.MoviePage) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/movies?"+query, nil)
+		router.ServeHTTP(w, req)
+		var page models.MoviePage
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		}
+		return w, page
+	}
+	titles := func(page models.MoviePage) []string {
+		var titles []string
+		for _, movie := range page.Movies {
+			titles = append(titles, movie.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"q=child", []string{"Moon Child", "Mooncake", "Sun Child"}},
+		{"q=moon+child&sort=-title", []string{"Mooncake", "Moon Child"}},
+		{"genre=drama&max_duration=95", []string{"Mooncake"}},
+		{"min_duration=100&sort=-duration", []string{"Sun Child", "Moon Child"}},
+		{"date=" + tomorrow.Format("2006-01-02"), []string{"Sun Child"}},
+		{"sort=newest&limit=1", []string{"Mooncake"}},
+	}
+	for _, tt := range tests {
+		w, page := get(tt.query)
+		require.Equal(t, http.StatusOK, w.Code, tt.query)
+		assert.Equal(t, tt.want, titles(page), tt.query)
+	}
+
+	// Following next_cursor walks the whole catalogue
+	_, page := get("limit=2")
+	require.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, []string{"Moon Child", "Mooncake"}, titles(page))
+	_, page = get("limit=2&cursor=" + page.NextCursor)
+	assert.Equal(t, []string{"Sun Child"}, titles(page))
+	assert.Empty(t, page.NextCursor)
+
+	_, page = get("limit=1")
+	w, _ := get("sort=duration&cursor=" + page.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_cursor"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	for _, query := range []string{
+		"sort=rating",
+		"sort=relevance",
+		"limit=101",
+		"min_duration=-1",
+		"min_duration=120&max_duration=90",
+		"date=tomorrow",
+	} {
+		w, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, `"invalid_request"`, string(mustField(t, w.Body.Bytes(), "code")), query)
+	}
 }
 
 func TestGetMovie(t *testing.T) {
 	router := setupRouter()
-	router.GET("/api/cinema/movies/:id", GetMovie)
+	h := newTestHandler(t)
+	router.GET("/api/cinema/movies/:id", h.GetMovie)
 
 	tests := []struct {
 		name       string
@@ -67,7 +192,8 @@ func TestGetMovie(t *testing.T) {
 
 func TestCreateMovie(t *testing.T) {
 	router := setupRouter()
-	router.POST("/api/cinema/movies", CreateMovie)
+	h := newTestHandler(t)
+	router.POST("/api/cinema/movies", h.CreateMovie)
 
 	tests := []struct {
 		name       string
@@ -107,7 +233,8 @@ func TestCreateMovie(t *testing.T) {
 
 func TestCreateBooking(t *testing.T) {
 	router := setupRouter()
-	router.POST("/api/cinema/bookings", CreateBooking)
+	h := newTestHandler(t)
+	router.POST("/api/cinema/bookings", h.CreateBooking)
 
 	tests := []struct {
 		name       string
@@ -146,7 +273,8 @@ func TestCreateBooking(t *testing.T) {
 
 func TestCancelBooking(t *testing.T) {
 	router := setupRouter()
-	router.DELETE("/api/cinema/bookings/:id", CancelBooking)
+	h := newTestHandler(t)
+	router.DELETE("/api/cinema/bookings/:id", h.CancelBooking)
 
 	tests := []struct {
 		name       string
@@ -182,7 +310,8 @@ func TestCancelBooking(t *testing.T) {
 
 func TestGetTheaterLayout(t *testing.T) {
 	router := setupRouter()
-	router.GET("/api/cinema/shows/:id/layout", GetTheaterLayout)
+	h := newTestHandler(t)
+	router.GET("/api/cinema/shows/:id/layout", h.GetTheaterLayout)
 
 	tests := []struct {
 		name       string
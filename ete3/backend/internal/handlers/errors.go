@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDKey is the gin context key holding the request ID
+const requestIDKey = "request_id"
+
+// Errors raised by the handlers themselves
+var (
+	errUnauthorized       = database.NewError(database.KindUnauthorized, "unauthorized", "Authorization token required")
+	errInvalidToken       = database.NewError(database.KindUnauthorized, "invalid_token", "Invalid or expired token")
+	errInvalidCredentials = database.NewError(database.KindUnauthorized, "invalid_credentials", "Invalid credentials")
+	errForbidden          = database.NewError(database.KindForbidden, "forbidden", "Insufficient permissions")
+	errInternal           = database.NewError(database.KindInternal, "internal_error", "Internal server error")
+)
+
+// statusByKind maps error kinds to HTTP status codes
+var statusByKind = map[database.Kind]int{
+	database.KindInternal:     http.StatusInternalServerError,
+	database.KindInvalid:      http.StatusBadRequest,
+	database.KindUnauthorized: http.StatusUnauthorized,
+	database.KindForbidden:    http.StatusForbidden,
+	database.KindNotFound:     http.StatusNotFound,
+	database.KindConflict:     http.StatusConflict,
+	database.KindGone:         http.StatusGone,
+
+	database.KindPaymentRequired: http.StatusPaymentRequired,
+	database.KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+// RequestID tags every request with an ID, reusing the caller's
+// X-Request-ID header when present
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, id)
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// ErrorHandler renders the last error attached with c.Error as a
+// models.ErrorResponse. Errors that aren't a *database.Error are logged and
+// reported as a generic internal error so details never leak to clients.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var apiErr *database.Error
+		if !errors.As(err, &apiErr) || apiErr.Kind == database.KindInternal {
+			log.Printf("Error handling %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			apiErr = errInternal
+		}
+
+		c.JSON(statusByKind[apiErr.Kind], models.ErrorResponse{
+			Code:      apiErr.Code,
+			Message:   apiErr.Message,
+			Details:   apiErr.Details,
+			RequestID: c.GetString(requestIDKey),
+		})
+	}
+}
+
+// invalidRequest returns a KindInvalid error with the given message
+func invalidRequest(message string) error {
+	return database.NewError(database.KindInvalid, "invalid_request", message)
+}
+
+// bindJSON binds the request body into obj, recording a validation error
+// and returning false if it doesn't fit
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return false
+	}
+	return true
+}
+
+// bindQuery binds the query string into obj, recording a validation error
+// and returning false if it doesn't fit
+func bindQuery(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return false
+	}
+	return true
+}
+
+// idParam parses the named path parameter as an ID, recording an error and
+// returning false if it isn't one. label names the entity in the message.
+func idParam(c *gin.Context, name, label string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.Error(invalidRequest("Invalid " + label + " ID"))
+		return 0, false
+	}
+	return id, true
+}
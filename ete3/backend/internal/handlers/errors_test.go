@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	router := setupRouter()
+	router.GET("/conflict", func(c *gin.Context) {
+		c.Error(database.ErrSeatUnavailable.WithDetails(map[string]int64{"seat_id": 3}))
+	})
+	router.GET("/not-found", func(c *gin.Context) {
+		c.Error(database.ErrMovieNotFound)
+	})
+	router.GET("/raw", func(c *gin.Context) {
+		c.Error(errors.New("no such table: movies"))
+	})
+	router.GET("/ids/:id", func(c *gin.Context) {
+		if _, ok := idParam(c, "id", "movie"); ok {
+			c.Status(http.StatusNoContent)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"Domain Error With Details", "/conflict", http.StatusConflict, "seat_unavailable"},
+		{"Not Found", "/not-found", http.StatusNotFound, "movie_not_found"},
+		{"Unexpected Error", "/raw", http.StatusInternalServerError, "internal_error"},
+		{"Invalid ID", "/ids/abc", http.StatusBadRequest, "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			var body models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.NotEmpty(t, body.Message)
+			assert.NotEmpty(t, body.RequestID)
+			assert.Equal(t, w.Header().Get("X-Request-ID"), body.RequestID)
+		})
+	}
+
+	t.Run("Details Are Rendered", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/conflict", nil)
+		router.ServeHTTP(w, req)
+		assert.JSONEq(t, `{"seat_id": 3}`, string(mustField(t, w.Body.Bytes(), "details")))
+	})
+
+	t.Run("Internal Details Are Hidden", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/raw", nil)
+		router.ServeHTTP(w, req)
+		assert.False(t, strings.Contains(w.Body.String(), "no such table"))
+	})
+
+	t.Run("Caller Request ID Is Kept", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/not-found", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+		assert.JSONEq(t, `"abc-123"`, string(mustField(t, w.Body.Bytes(), "request_id")))
+	})
+}
+
+// mustField returns the raw JSON of a top-level field of body
+func mustField(t *testing.T, body []byte, field string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("Invalid JSON body %q: %v", body, err)
+	}
+	return fields[field]
+}
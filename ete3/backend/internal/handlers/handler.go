@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"ete3/internal/live"
+	"ete3/internal/payments"
+	"ete3/internal/repository"
+)
+
+// Handler serves the cinema and auth endpoints on top of the storage
+// repositories
+type Handler struct {
+	movies   repository.MovieRepository
+	theaters repository.TheaterRepository
+	shows    repository.ShowRepository
+	seats    repository.SeatRepository
+	bookings repository.BookingRepository
+	promos   repository.PromoRepository
+	payments repository.PaymentRepository
+	users    repository.UserRepository
+
+	// jwt signs and verifies access tokens
+	jwt JWTConfig
+	// gateway charges bookings
+	gateway payments.Provider
+	// hub delivers seat changes to live seat maps
+	hub *live.Hub
+}
+
+// New returns a Handler backed by repos that issues and accepts access
+// tokens under jwt. Bookings are paid through the fake gateway until
+// SetPaymentProvider installs another, and live seat maps only see the
+// changes published to the hub SetSeatEvents installs.
+func New(repos repository.Repositories, jwt JWTConfig) *Handler {
+	return &Handler{
+		movies:   repos.Movies,
+		theaters: repos.Theaters,
+		shows:    repos.Shows,
+		seats:    repos.Seats,
+		bookings: repos.Bookings,
+		promos:   repos.Promos,
+		payments: repos.Payments,
+		users:    repos.Users,
+		jwt:      jwt,
+		gateway:  payments.NewFake(""),
+		hub:      live.NewHub(0),
+	}
+}
+
+// SetPaymentProvider makes bookings pay through provider
+func (h *Handler) SetPaymentProvider(provider payments.Provider) {
+	h.gateway = provider
+}
+
+// SetSeatEvents makes live seat maps follow the seat changes published to
+// hub
+func (h *Handler) SetSeatEvents(hub *live.Hub) {
+	h.hub = hub
+}
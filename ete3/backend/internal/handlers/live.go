@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveHeartbeat is how often an idle seat stream sends a comment, so
+// proxies don't time it out
+const liveHeartbeat = 15 * time.Second
+
+// LiveSeats streams a show's seat map as Server-Sent Events: a "snapshot"
+// event with the current layout, then a "seats" event for every change.
+// A client that falls behind gets a "reset" event and the stream ends; it
+// reconnects to start over from a fresh snapshot.
+func (h *Handler) LiveSeats(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
+		return
+	}
+
+	// Subscribe before reading the layout so no change can fall between
+	// the two; changes already in the snapshot are just applied again
+	sub := h.hub.Subscribe(showID)
+	defer sub.Close()
+
+	layout, err := h.seats.GetTheaterLayout(showID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("snapshot", layout)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				c.SSEvent("reset", gin.H{"message": "Missed seat changes, reconnect for a fresh snapshot"})
+				c.Writer.Flush()
+				return
+			}
+			c.SSEvent("seats", event)
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
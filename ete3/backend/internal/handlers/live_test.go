@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"ete3/internal/live"
+	"ete3/internal/models"
+	"ete3/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is one event read off a Server-Sent Events stream
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvent reads the next event from stream, skipping comments
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := stream.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event.name != "":
+			return event
+		case strings.HasPrefix(line, "event:"):
+			event.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			event.data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+func TestLiveSeats(t *testing.T) {
+	store := repository.NewMemory()
+	movie := &models.Movie{Title: "Live Movie", Duration: 100}
+	require.NoError(t, store.CreateMovie(movie, nil))
+	theater := store.AddTheater("Live Theater", 2, 4)
+	show := store.AddShow(movie.ID, theater.ID, time.Now().Add(24*time.Hour), 8.0)
+	bookSeats(t, store, 1, show.ID, 1)
+
+	hub := live.NewHub(8)
+	store.SetSeatEvents(hub)
+	h := New(store.Repositories(), testJWT)
+	h.SetSeatEvents(hub)
+	router := setupRouter()
+	router.GET("/shows/:id/live", h.LiveSeats)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/shows/999/live")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Zero(t, hub.Subscribers(999))
+
+	resp, err = http.Get(server.URL + "/shows/1/live")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	stream := bufio.NewReader(resp.Body)
+
+	// The snapshot has the seat that was booked before connecting
+	snapshot := readEvent(t, stream)
+	require.Equal(t, "snapshot", snapshot.name)
+	var layout struct {
+		Layout string `json:"layout"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(snapshot.data), &layout))
+	assert.Equal(t, "BAAA|AAAA|", layout.Layout)
+
+	hold, err := store.CreateHold(2, show.ID, []int64{2, 3}, "", 10*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.ReleaseHold(hold.BookingID, 2))
+	// A lapsed hold is released by the next booking for the show
+	_, err = store.CreateHold(3, show.ID, []int64{4}, "", 0)
+	require.NoError(t, err)
+	_, err = store.CreateHold(3, show.ID, []int64{5}, "", 10*time.Minute)
+	require.NoError(t, err)
+
+	for _, want := range []models.SeatEvent{
+		{ShowID: show.ID, SeatIDs: []int64{2, 3}, State: models.SeatHeld},
+		{ShowID: show.ID, SeatIDs: []int64{2, 3}, State: models.SeatReleased},
+		{ShowID: show.ID, SeatIDs: []int64{4}, State: models.SeatHeld},
+		{ShowID: show.ID, SeatIDs: []int64{4}, State: models.SeatReleased},
+		{ShowID: show.ID, SeatIDs: []int64{5}, State: models.SeatHeld},
+	} {
+		event := readEvent(t, stream)
+		require.Equal(t, "seats", event.name)
+		var got models.SeatEvent
+		require.NoError(t, json.Unmarshal([]byte(event.data), &got))
+		assert.Equal(t, want, got)
+	}
+
+	// Disconnecting ends the subscription
+	resp.Body.Close()
+	assert.Eventually(t, func() bool { return hub.Subscribers(show.ID) == 0 }, time.Second, 10*time.Millisecond)
+}
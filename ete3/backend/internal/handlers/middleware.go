@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys set by AuthMiddleware
+const (
+	userIDKey   = "user_id"
+	userRoleKey = "user_role"
+)
+
+// AuthMiddleware rejects requests without a bearer token valid under the
+// handler's JWT configuration and stores the token's user_id and role
+// claims in the context
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.Error(errUnauthorized)
+			c.Abort()
+			return
+		}
+
+		claims, err := h.jwt.parseToken(tokenString)
+		if err != nil {
+			c.Error(errInvalidToken)
+			c.Abort()
+			return
+		}
+
+		c.Set(userIDKey, claims.UserID)
+		c.Set(userRoleKey, claims.Role)
+		c.Next()
+	}
+}
+
+// RequireRole rejects authenticated users whose role is not one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, c.GetString(userRoleKey)) {
+			c.Error(errForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUserID returns the ID stored by AuthMiddleware
+func currentUserID(c *gin.Context) int64 {
+	return c.GetInt64(userIDKey)
+}
+
+// currentUserRole returns the role stored by AuthMiddleware
+func currentUserRole(c *gin.Context) string {
+	return c.GetString(userRoleKey)
+}
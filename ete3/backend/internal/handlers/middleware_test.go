@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"ete3/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// signTestToken signs claims with the current test key and kid, leaving
+// iss/aud/exp as given so tests can produce invalid tokens
+func signTestToken(t *testing.T, key SigningKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Secret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return tokenString
+}
+
+// validTestClaims returns claims that pass every check in parseToken
+func validTestClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": 42,
+		"iss":     testJWT.Issuer,
+		"aud":     testJWT.Audience,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.GET("/protected", h.AuthMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt64(userIDKey)})
+	})
+
+	valid, err := testJWT.issueToken(Claims{UserID: 42})
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	expiredClaims := validTestClaims()
+	expiredClaims["exp"] = time.Now().Add(-time.Hour).Unix()
+	noUserClaims := validTestClaims()
+	delete(noUserClaims, "user_id")
+
+	expired := signTestToken(t, testJWT.Current, expiredClaims)
+	wrongKey := signTestToken(t, SigningKey{ID: testJWT.Current.ID, Secret: []byte("other-key")}, validTestClaims())
+	noUser := signTestToken(t, testJWT.Current, noUserClaims)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{
+			name:       "Valid Token",
+			header:     "Bearer " + valid,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Missing Header",
+			header:     "",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Wrong Scheme",
+			header:     "Basic " + valid,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Expired Token",
+			header:     "Bearer " + expired,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Wrong Signing Key",
+			header:     "Bearer " + wrongKey,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Missing user_id Claim",
+			header:     "Bearer " + noUser,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/protected", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/admin", h.AuthMiddleware(), RequireRole(models.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		role       string
+		wantStatus int
+	}{
+		{
+			name:       "Admin",
+			role:       models.RoleAdmin,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Staff",
+			role:       models.RoleStaff,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Customer",
+			role:       models.RoleCustomer,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "No Role Claim",
+			role:       "",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := testJWT.issueToken(Claims{UserID: 1, Role: tt.role})
+			if err != nil {
+				t.Fatalf("Failed to issue token: %v", err)
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
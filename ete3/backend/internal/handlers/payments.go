@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"ete3/internal/payments"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// paymentTimeout bounds each call to the payment provider
+const paymentTimeout = 20 * time.Second
+
+// signatureHeader carries the provider's signature of a webhook payload
+const signatureHeader = "X-Payment-Signature"
+
+var errInvalidSignature = database.NewError(database.KindUnauthorized, "invalid_signature", "Webhook signature is invalid")
+
+// PaymentWebhook applies a change the payment provider reports, such as a
+// refund made in its dashboard. Events may arrive more than once.
+func (h *Handler) PaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(invalidRequest("Unreadable webhook body"))
+		return
+	}
+	event, err := h.gateway.VerifyWebhook(payload, c.GetHeader(signatureHeader))
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.Error(errInvalidSignature)
+		return
+	}
+	if err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return
+	}
+
+	payment, err := h.payments.GetPaymentByRef(h.gateway.Name(), event.AuthorizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch event.Type {
+	case payments.EventCaptured:
+		if payment.Status == models.PaymentDeclined || payment.Status == models.PaymentFailed {
+			// The booking was already treated as unpaid, so a capture that
+			// went through after all is handed back
+			payment.Status = models.PaymentCaptured
+			payment.Amount = event.Amount
+			err = h.refund(c.Request.Context(), payment, payment.Amount)
+		} else if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentRefunded {
+			payment.Status = models.PaymentCaptured
+			err = h.payments.UpdatePayment(payment)
+		}
+	case payments.EventRefunded:
+		if event.Amount > payment.Refunded {
+			payment.Refunded = math.Min(event.Amount, payment.Amount)
+			if payment.Refunded == payment.Amount {
+				payment.Status = models.PaymentRefunded
+			}
+			err = h.payments.UpdatePayment(payment)
+		}
+	case payments.EventFailed:
+		if payment.Status == models.PaymentPending || payment.Status == models.PaymentAuthorized {
+			payment.Status = models.PaymentFailed
+			err = h.payments.UpdatePayment(payment)
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// payAndConfirm charges total for a pending order, unless it is free, and
+// confirms the order. If confirming fails after the charge, the charge is
+// refunded.
+func (h *Handler) payAndConfirm(c *gin.Context, orderID int64, total float64, token string) (*models.BookingResponse, error) {
+	var payment *models.Payment
+	if total > 0 {
+		var err error
+		if payment, err = h.charge(c.Request.Context(), orderID, total, token); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := h.bookings.ConfirmHold(orderID, currentUserID(c))
+	if err != nil && payment != nil {
+		if refundErr := h.refund(c.Request.Context(), payment, payment.Amount); refundErr != nil {
+			log.Printf("Refunding payment %d of unconfirmed order %d failed: %v", payment.ID, orderID, refundErr)
+		}
+	}
+	return response, err
+}
+
+// charge authorizes and captures amount for an order and records the
+// attempt. It returns database.ErrPaymentDeclined or
+// database.ErrPaymentUnavailable if the payment doesn't go through.
+func (h *Handler) charge(ctx context.Context, orderID int64, amount float64, token string) (*models.Payment, error) {
+	payment := &models.Payment{
+		OrderID:  orderID,
+		Provider: h.gateway.Name(),
+		Amount:   amount,
+		Status:   models.PaymentPending,
+	}
+	if err := h.payments.CreatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, paymentTimeout)
+	defer cancel()
+
+	auth, err := h.gateway.Authorize(ctx, payments.AuthorizeRequest{OrderID: orderID, Amount: amount, Token: token})
+	if err == nil {
+		payment.ProviderRef = auth.ID
+		payment.Status = models.PaymentAuthorized
+		if err = h.gateway.Capture(ctx, auth.ID, amount); err == nil {
+			payment.Status = models.PaymentCaptured
+		}
+	}
+	if err != nil {
+		payment.Status = models.PaymentFailed
+		if errors.Is(err, payments.ErrDeclined) {
+			payment.Status = models.PaymentDeclined
+		}
+		payment.Error = err.Error()
+	}
+	if updateErr := h.payments.UpdatePayment(payment); updateErr != nil {
+		return nil, updateErr
+	}
+
+	switch {
+	case err == nil:
+		return payment, nil
+	case errors.Is(err, payments.ErrDeclined):
+		return nil, database.ErrPaymentDeclined
+	case errors.Is(err, payments.ErrTimeout):
+		return nil, database.ErrPaymentUnavailable
+	}
+	return nil, err
+}
+
+// refund returns amount of a captured payment through the provider and
+// records it
+func (h *Handler) refund(ctx context.Context, payment *models.Payment, amount float64) error {
+	ctx, cancel := context.WithTimeout(ctx, paymentTimeout)
+	defer cancel()
+
+	if err := h.gateway.Refund(ctx, payment.ProviderRef, amount); err != nil {
+		if errors.Is(err, payments.ErrTimeout) {
+			return database.ErrPaymentUnavailable
+		}
+		return err
+	}
+	payment.Refunded = math.Round((payment.Refunded+amount)*100) / 100
+	if payment.Refunded >= payment.Amount {
+		payment.Status = models.PaymentRefunded
+	}
+	return h.payments.UpdatePayment(payment)
+}
+
+// issueRefund returns a pending refund through the payment provider and
+// records whether it went through. The booking stays cancelled if it
+// didn't; the refund keeps the error for staff to follow up on.
+func (h *Handler) issueRefund(ctx context.Context, refund *models.Refund) {
+	payment, err := h.payments.GetPayment(*refund.PaymentID)
+	if err == nil {
+		err = h.refund(ctx, payment, refund.Amount)
+	}
+	refund.Status = models.RefundCompleted
+	if err != nil {
+		log.Printf("Refunding cancelled order %d failed: %v", refund.OrderID, err)
+		refund.Status = models.RefundFailed
+		refund.Error = err.Error()
+	}
+	if err := h.payments.UpdateRefund(refund); err != nil {
+		log.Printf("Recording refund %d failed: %v", refund.ID, err)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"ete3/internal/models"
+	"ete3/internal/payments"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookingPayments(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/bookings", h.CreateBooking)
+	router.GET("/shows/:id/seats", h.GetAvailableSeats)
+
+	available := func() int {
+		w := sendJSON(router, "GET", "/shows/1/seats", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var seats []models.Seat
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &seats))
+		return len(seats)
+	}
+	before := available()
+
+	// Failed payments release the seats
+	w := postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{5, 6}, PaymentToken: payments.TokenDecline})
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Equal(t, `"payment_declined"`, string(mustField(t, w.Body.Bytes(), "code")))
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{5, 6}, PaymentToken: payments.TokenCaptureTimeout})
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, before, available())
+
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{5, 6}, PaymentToken: "tok_visa"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.BookingResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "success", response.Status)
+	require.NotNil(t, response.Payment)
+	assert.Equal(t, models.PaymentCaptured, response.Payment.Status)
+	assert.Equal(t, 20.0, response.Payment.Amount)
+	assert.Equal(t, before-2, available())
+}
+
+func TestConfirmHoldPayment(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/holds", h.CreateHold)
+	router.POST("/holds/:id/confirm", h.ConfirmHold)
+
+	w := postJSON(router, "/holds", models.BookingRequest{ShowID: 1, SeatIDs: []int64{7}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var hold models.BookingResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hold))
+	path := fmt.Sprintf("/holds/%d/confirm", hold.BookingID)
+
+	// A declined payment keeps the hold so it can be paid again
+	w = postJSON(router, path, models.PaymentRequest{PaymentToken: payments.TokenDecline})
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+
+	w = postJSON(router, path, models.PaymentRequest{PaymentToken: "tok_visa"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"success"`, string(mustField(t, w.Body.Bytes(), "status")))
+
+	w = postJSON(router, path, models.PaymentRequest{PaymentToken: "tok_visa"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	gateway := payments.NewFake("secret")
+	h.SetPaymentProvider(gateway)
+	router.POST("/bookings", h.CreateBooking)
+	router.POST("/webhook", h.PaymentWebhook)
+
+	w := postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{8}})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.BookingResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Payment)
+
+	webhook := func(event payments.Event, signature string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(event)
+		if signature == "" {
+			signature = gateway.SignWebhook(payload)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhook", bytes.NewBuffer(payload))
+		req.Header.Set(signatureHeader, signature)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	refund := payments.Event{ID: "evt_1", Type: payments.EventRefunded, AuthorizationID: response.Payment.ProviderRef, Amount: 4}
+
+	assert.Equal(t, http.StatusUnauthorized, webhook(refund, "00").Code)
+	unknown := refund
+	unknown.AuthorizationID = "fake_auth_99"
+	assert.Equal(t, http.StatusNotFound, webhook(unknown, "").Code)
+
+	// Redelivered events change nothing
+	for i := 0; i < 2; i++ {
+		w = webhook(refund, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var payment models.Payment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+		assert.Equal(t, 4.0, payment.Refunded)
+		assert.Equal(t, models.PaymentCaptured, payment.Status)
+	}
+
+	refund.Amount = 10
+	w = webhook(refund, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"refunded"`, string(mustField(t, w.Body.Bytes(), "status")))
+}
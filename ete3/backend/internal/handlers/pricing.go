@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteSeats prices seats of a show with the pricing rules that would
+// apply to booking them now. Seats are given as seat_ids=1,2,3 or as
+// repeated seat_ids parameters.
+func (h *Handler) QuoteSeats(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
+		return
+	}
+
+	var seatIDs []int64
+	for _, param := range c.QueryArray("seat_ids") {
+		for _, value := range strings.Split(param, ",") {
+			seatID, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				c.Error(invalidRequest("Invalid seat ID " + strconv.Quote(value)))
+				return
+			}
+			seatIDs = append(seatIDs, seatID)
+		}
+	}
+	if len(seatIDs) == 0 {
+		c.Error(invalidRequest("seat_ids must list at least one seat"))
+		return
+	}
+
+	quote, err := h.bookings.QuoteSeats(showID, seatIDs)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, quote)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ete3/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteSeats(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.GET("/shows/:id/quote", h.QuoteSeats)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/shows/1/quote?seat_ids=1,2&seat_ids=3", "/shows/1/quote?seat_ids=1&seat_ids=2,3"} {
+		w := get(path)
+		require.Equal(t, http.StatusOK, w.Code, path)
+		var quote models.Quote
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quote))
+		require.Len(t, quote.Seats, 3)
+		assert.Equal(t, int64(3), quote.Seats[2].SeatID)
+		assert.Equal(t, 10.0, quote.Seats[2].Price)
+		assert.Empty(t, quote.Seats[2].Adjustments)
+		assert.Equal(t, 30.0, quote.Total)
+	}
+
+	w := get("/shows/1/quote?seat_ids=1,two")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/shows/1/quote")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/shows/999/quote?seat_ids=1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
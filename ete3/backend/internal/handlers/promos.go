@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"ete3/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePromoCode creates a promo code customers can redeem when booking
+func (h *Handler) CreatePromoCode(c *gin.Context) {
+	var req models.PromoCodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	promo, err := req.PromoCode()
+	if err != nil {
+		c.Error(invalidRequest(err.Error()))
+		return
+	}
+	if err := h.promos.CreatePromoCode(promo); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, promo)
+}
+
+// GetPromoCodes returns every promo code with how often it was redeemed
+func (h *Handler) GetPromoCodes(c *gin.Context) {
+	usages, err := h.promos.GetPromoCodes()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, usages)
+}
+
+// GetPromoCodeUsage reports on one promo code and the orders it was
+// redeemed on
+func (h *Handler) GetPromoCodeUsage(c *gin.Context) {
+	promoID, ok := idParam(c, "id", "promo code")
+	if !ok {
+		return
+	}
+
+	usage, err := h.promos.GetPromoCodeUsage(promoID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
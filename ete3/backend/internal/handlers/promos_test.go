@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ete3/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoCodeEndpoints(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/promo-codes", h.CreatePromoCode)
+	router.GET("/promo-codes", h.GetPromoCodes)
+	router.GET("/promo-codes/:id", h.GetPromoCodeUsage)
+	router.POST("/bookings", h.CreateBooking)
+
+	w := postJSON(router, "/promo-codes", models.PromoCodeRequest{Code: "half", Type: models.PromoPercentage, Value: 150})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postJSON(router, "/promo-codes", models.PromoCodeRequest{Code: "half", Type: "bogo", Value: 50})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(router, "/promo-codes", models.PromoCodeRequest{Code: "half", Type: models.PromoPercentage, Value: 50, MaxRedemptions: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var promo models.PromoCode
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &promo))
+	assert.Equal(t, "HALF", promo.Code)
+
+	w = postJSON(router, "/promo-codes", models.PromoCodeRequest{Code: "HALF", Type: models.PromoFixed, Value: 2})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{1, 2}, PromoCode: "half"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", string(mustField(t, w.Body.Bytes(), "discount")))
+	assert.Equal(t, "10", string(mustField(t, w.Body.Bytes(), "total")))
+
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{3}, PromoCode: "half"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"promo_code_exhausted"`, string(mustField(t, w.Body.Bytes(), "code")))
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: 1, SeatIDs: []int64{3}, PromoCode: "nope"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_promo_code"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	w = sendJSON(router, "GET", "/promo-codes", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var usages []models.PromoCodeUsage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usages))
+	require.Len(t, usages, 1)
+	assert.Equal(t, 1, usages[0].Redemptions)
+	assert.Empty(t, usages[0].Orders)
+
+	w = sendJSON(router, "GET", "/promo-codes/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var usage models.PromoCodeUsage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Equal(t, 10.0, usage.TotalDiscount)
+	require.Len(t, usage.Orders, 1)
+	assert.Equal(t, "confirmed", usage.Orders[0].Status)
+
+	w = sendJSON(router, "GET", "/promo-codes/2", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
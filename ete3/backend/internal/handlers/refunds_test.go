@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"ete3/internal/refunds"
+	"ete3/internal/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelBookingRefunds(t *testing.T) {
+	store := repository.NewMemory()
+	movie := &models.Movie{Title: "Late Show", Duration: 90}
+	require.NoError(t, store.CreateMovie(movie, nil))
+	theater := store.AddTheater("Small Theater", 2, 5)
+	// Within a day of the show the default policy refunds half
+	show := store.AddShow(movie.ID, theater.ID, time.Now().Add(2*time.Hour), 10.0)
+	policy, err := refunds.New(refunds.DefaultTiers())
+	require.NoError(t, err)
+	store.SetRefundPolicy(policy)
+
+	h := New(store.Repositories(), testJWT)
+	router := setupRouter()
+	router.POST("/bookings", h.AuthMiddleware(), h.CreateBooking)
+	router.DELETE("/bookings/:id", h.AuthMiddleware(), h.CancelBooking)
+	router.DELETE("/bookings/:id/seats/:seatId", h.AuthMiddleware(), h.CancelBookingSeat)
+
+	send := func(method, path string, claims Claims, body interface{}) *httptest.ResponseRecorder {
+		token, err := testJWT.issueToken(claims)
+		require.NoError(t, err)
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	customer := Claims{UserID: 5, Role: models.RoleCustomer}
+	staff := Claims{UserID: 9, Role: models.RoleStaff}
+	book := func(seatIDs ...int64) string {
+		w := send("POST", "/bookings", customer, models.BookingRequest{ShowID: show.ID, SeatIDs: seatIDs})
+		require.Equal(t, http.StatusOK, w.Code)
+		var booking models.BookingResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booking))
+		return fmt.Sprintf("/bookings/%d", booking.BookingID)
+	}
+	cancel := func(path string, claims Claims, body interface{}) *models.Refund {
+		w := send("DELETE", path, claims, body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response models.CancelResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "cancelled", response.Status)
+		require.NotNil(t, response.Refund)
+		return response.Refund
+	}
+
+	refund := cancel(book(1, 2), customer, nil)
+	assert.Equal(t, 10.0, refund.Amount)
+	assert.Equal(t, 50.0, refund.Percent)
+	assert.Equal(t, "late", refund.Tier)
+	assert.Equal(t, models.RefundCompleted, refund.Status)
+	require.NotNil(t, refund.PaymentID)
+	payment, err := store.GetPayment(*refund.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, payment.Refunded)
+
+	// Only staff override the policy, and only with a reason
+	path := book(3)
+	full := 100.0
+	w := send("DELETE", path, customer, models.CancelRequest{RefundPercent: &full, Reason: "Please"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = send("DELETE", path, staff, models.CancelRequest{RefundPercent: &full, Reason: "  "})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	over := 150.0
+	w = send("DELETE", path, staff, models.CancelRequest{RefundPercent: &over, Reason: "Projector broke"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Staff cancel on the customer's behalf
+	refund = cancel(path, staff, models.CancelRequest{RefundPercent: &full, Reason: "Projector broke"})
+	assert.Equal(t, 10.0, refund.Amount)
+	assert.Equal(t, models.OverrideTier, refund.Tier)
+	assert.Equal(t, "Projector broke", refund.Reason)
+	require.NotNil(t, refund.IssuedBy)
+	assert.Equal(t, int64(9), *refund.IssuedBy)
+	assert.Equal(t, models.RefundCompleted, refund.Status)
+
+	w = send("DELETE", path, customer, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Cancelling seat by seat refunds each seat's share
+	path = book(4, 5)
+	w = send("DELETE", path+"/seats/4", customer, models.CancelRequest{RefundPercent: &full, Reason: "Please"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var total float64
+	for _, seat := range []string{"/seats/4", "/seats/5"} {
+		w = send("DELETE", path+seat, customer, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Refund *models.Refund `json:"refund"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.Refund)
+		assert.Equal(t, models.RefundCompleted, response.Refund.Status)
+		total += response.Refund.Amount
+	}
+	assert.Equal(t, 10.0, total)
+	w = send("DELETE", path, customer, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The cancelled orders were paid, so the show can't be deleted
+	assert.ErrorIs(t, store.DeleteShow(show.ID), database.ErrShowHasPayments)
+}
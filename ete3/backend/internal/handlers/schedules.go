@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreviewSchedule returns the shows a schedule template would create and
+// which of them conflict, without creating any
+func (h *Handler) PreviewSchedule(c *gin.Context) {
+	shows, ok := templateShows(c)
+	if !ok {
+		return
+	}
+
+	scheduled, err := h.shows.PreviewShows(shows)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	preview := models.SchedulePreview{Shows: scheduled}
+	for _, show := range scheduled {
+		if show.Conflict != nil {
+			preview.Conflicts++
+		}
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// CommitSchedule creates every show of a schedule template, or none if any
+// of them conflict
+func (h *Handler) CommitSchedule(c *gin.Context) {
+	shows, ok := templateShows(c)
+	if !ok {
+		return
+	}
+
+	if err := h.shows.CreateShows(shows); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, shows)
+}
+
+// templateShows binds a schedule template and expands it into shows. It
+// writes the error and returns false if the template is invalid.
+func templateShows(c *gin.Context) ([]models.Show, bool) {
+	var template models.ScheduleTemplate
+	if !bindJSON(c, &template) {
+		return nil, false
+	}
+	if template.MovieID == 0 {
+		c.Error(invalidRequest("movie_id is required"))
+		return nil, false
+	}
+
+	shows, err := template.Shows(time.Local)
+	if err != nil {
+		c.Error(database.ErrInvalidSchedule.WithDetails(err.Error()))
+		return nil, false
+	}
+	return shows, true
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ete3/internal/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleEndpoints(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/schedules/preview", h.PreviewSchedule)
+	router.POST("/schedules", h.CommitSchedule)
+
+	theater := &models.Theater{Name: "Screen 2"}
+	require.NoError(t, h.theaters.CreateTheater(theater, &models.SeatMap{Rows: 2, SeatsPerRow: 5}))
+
+	// The 120 minute movie 1 can't run at 19:00 and 20:30
+	template := models.ScheduleTemplate{
+		MovieID:   1,
+		TheaterID: theater.ID,
+		StartDate: time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		RRule:     "FREQ=DAILY;COUNT=3",
+		Times:     []models.ShowTime{{Time: "19:00", Price: 10}, {Time: "20:30", Price: 12}},
+	}
+
+	w := postJSON(router, "/schedules/preview", template)
+	require.Equal(t, http.StatusOK, w.Code)
+	var preview models.SchedulePreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	require.Len(t, preview.Shows, 6)
+	assert.Equal(t, 3, preview.Conflicts)
+	require.NotNil(t, preview.Shows[1].Conflict)
+	assert.Equal(t, "show_overlap", preview.Shows[1].Conflict.Code)
+
+	w = postJSON(router, "/schedules", template)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"schedule_conflict"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	template.Times[1].Time = "21:30"
+	w = postJSON(router, "/schedules", template)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var shows []models.Show
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shows))
+	require.Len(t, shows, 6)
+	for _, show := range shows {
+		assert.NotZero(t, show.ID)
+		assert.Equal(t, int64(1), show.MovieID)
+	}
+
+	template.RRule = "FREQ=HOURLY;COUNT=3"
+	w = postJSON(router, "/schedules/preview", template)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_schedule"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	// Only a new movie's schedule leaves the movie out
+	template.MovieID = 0
+	w = postJSON(router, "/schedules/preview", template)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_request"`, string(mustField(t, w.Body.Bytes(), "code")))
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"ete3/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShow schedules a show
+func (h *Handler) CreateShow(c *gin.Context) {
+	var req models.ShowRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	show := models.Show{
+		MovieID:   req.MovieID,
+		TheaterID: req.TheaterID,
+		StartTime: req.StartTime,
+		Price:     req.Price,
+		Prices:    req.Prices,
+	}
+	if err := h.shows.CreateShow(&show); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondWithShow(c, http.StatusCreated, show.ID)
+}
+
+// UpdateShow reschedules a show
+func (h *Handler) UpdateShow(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
+		return
+	}
+
+	var req models.ShowRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	show := models.Show{
+		ID:        showID,
+		MovieID:   req.MovieID,
+		TheaterID: req.TheaterID,
+		StartTime: req.StartTime,
+		Price:     req.Price,
+		Prices:    req.Prices,
+	}
+	if err := h.shows.UpdateShow(&show); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondWithShow(c, http.StatusOK, showID)
+}
+
+// DeleteShow deletes a show that has no active bookings
+func (h *Handler) DeleteShow(c *gin.Context) {
+	showID, ok := idParam(c, "id", "show")
+	if !ok {
+		return
+	}
+
+	if err := h.shows.DeleteShow(showID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Show deleted successfully"})
+}
+
+// respondWithShow writes the stored show with the given status
+func (h *Handler) respondWithShow(c *gin.Context, status int, showID int64) {
+	show, err := h.shows.GetShowByID(showID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(status, show)
+}
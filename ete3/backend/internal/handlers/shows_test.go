@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ete3/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowEndpoints(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/shows", h.CreateShow)
+	router.PUT("/shows/:id", h.UpdateShow)
+	router.DELETE("/shows/:id", h.DeleteShow)
+
+	// The seeded 120 minute show 1 runs tomorrow in theater 1
+	seeded, err := h.shows.GetShowByID(1)
+	require.NoError(t, err)
+
+	w := postJSON(router, "/shows", models.ShowRequest{
+		MovieID: 1, TheaterID: 1, StartTime: seeded.StartTime.Add(2 * time.Hour), Price: 9,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"show_overlap"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	w = postJSON(router, "/shows", models.ShowRequest{
+		MovieID: 1, TheaterID: 1, StartTime: seeded.EndTime.Add(30 * time.Minute), Price: 9,
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var show models.Show
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &show))
+	assert.Equal(t, 120*time.Minute, show.EndTime.Sub(show.StartTime))
+	path := "/shows/" + strconv.FormatInt(show.ID, 10)
+
+	w = postJSON(router, "/shows", models.ShowRequest{MovieID: 1, TheaterID: 1, Price: 9})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(router, "PUT", path, models.ShowRequest{
+		MovieID: 1, TheaterID: 1, StartTime: seeded.StartTime.Add(48 * time.Hour), Price: 11,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &show))
+	assert.Equal(t, 11.0, show.Price)
+
+	// Show 1 has a booking, so it can neither move nor go
+	w = sendJSON(router, "PUT", "/shows/1", models.ShowRequest{
+		MovieID: 1, TheaterID: 1, StartTime: seeded.StartTime.Add(72 * time.Hour), Price: 10,
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"show_has_bookings"`, string(mustField(t, w.Body.Bytes(), "code")))
+	assert.Equal(t, http.StatusConflict, sendJSON(router, "DELETE", "/shows/1", nil).Code)
+	assert.Equal(t, http.StatusOK, sendJSON(router, "DELETE", path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "DELETE", path, nil).Code)
+}
+
+func TestCreateMovieWithSchedule(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/movies", h.CreateMovie)
+
+	// An empty theater, so the seeded show can't get in the way
+	theater := &models.Theater{Name: "Screen 2"}
+	require.NoError(t, h.theaters.CreateTheater(theater, &models.SeatMap{Rows: 2, SeatsPerRow: 5}))
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	body := map[string]interface{}{
+		"title":    "Scheduled",
+		"duration": 90,
+		"schedule": models.ScheduleTemplate{
+			TheaterID: theater.ID,
+			StartDate: tomorrow,
+			RRule:     "FREQ=DAILY;COUNT=3",
+			Times:     []models.ShowTime{{Time: "09:00", Price: 8}, {Time: "11:00", Price: 9}},
+		},
+	}
+	w := postJSON(router, "/movies", body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.MovieWithShows
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Scheduled", created.Title)
+	require.Len(t, created.Shows, 6)
+	for _, show := range created.Shows {
+		assert.Equal(t, created.ID, show.MovieID)
+		assert.NotZero(t, show.ID)
+	}
+
+	// Without a schedule the movie has no shows
+	w = postJSON(router, "/movies", models.Movie{Title: "Unscheduled", Duration: 90})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var unscheduled models.MovieWithShows
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &unscheduled))
+	assert.Empty(t, unscheduled.Shows)
+
+	schedule := models.ScheduleTemplate{TheaterID: theater.ID, StartDate: "tomorrow", RRule: "FREQ=DAILY;COUNT=1", Times: []models.ShowTime{{Time: "09:00", Price: 8}}}
+	body["schedule"] = schedule
+	w = postJSON(router, "/movies", body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_schedule"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	// The shows are for the new movie, so the schedule names none
+	schedule.StartDate = tomorrow
+	schedule.MovieID = 1
+	body["schedule"] = schedule
+	w = postJSON(router, "/movies", body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_request"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	// Conflicts are reported like those of a schedule template
+	schedule.MovieID = 0
+	body["schedule"] = schedule
+	w = postJSON(router, "/movies", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, `"schedule_conflict"`, string(mustField(t, w.Body.Bytes(), "code")))
+}
+
+func TestCategoryPricedShow(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.POST("/theaters", h.CreateTheater)
+	router.POST("/shows", h.CreateShow)
+	router.POST("/bookings", h.CreateBooking)
+
+	w := postJSON(router, "/theaters", models.TheaterRequest{
+		Name: "Screen 2",
+		SeatMap: &models.SeatMap{Rows: 2, SeatsPerRow: 2, Categories: []models.CategorySection{
+			{SeatCategory: models.SeatCategory{Code: "R", Name: "Recliner"}, Rows: []int{2}},
+		}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var theater models.Theater
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &theater))
+	require.Len(t, theater.Categories, 2)
+
+	start := time.Now().Add(48 * time.Hour)
+	w = postJSON(router, "/shows", models.ShowRequest{
+		MovieID: 1, TheaterID: theater.ID, StartTime: start, Price: 9, Prices: map[string]float64{"X": 12},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"unknown_seat_category"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	w = postJSON(router, "/shows", models.ShowRequest{
+		MovieID: 1, TheaterID: theater.ID, StartTime: start, Price: 9, Prices: map[string]float64{"R": 14},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var show models.Show
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &show))
+	assert.Equal(t, map[string]float64{"R": 14}, show.Prices)
+
+	seats, err := h.theaters.GetAllSeatsForTheater(theater.ID)
+	require.NoError(t, err)
+	w = postJSON(router, "/bookings", models.BookingRequest{ShowID: show.ID, SeatIDs: []int64{seats[0].ID, seats[3].ID}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "23", string(mustField(t, w.Body.Bytes(), "total")))
+}
+
+func TestGetShows(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.GET("/shows", h.GetShows)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/shows?"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The seeded show runs tomorrow with one of its 50 seats booked
+	tomorrow := time.Now().Add(24 * time.Hour).Format("2006-01-02")
+	w := get("date=" + tomorrow + "&theater_id=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listings []models.ShowListing
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listings))
+	require.Len(t, listings, 1)
+	assert.Equal(t, int64(1), listings[0].ID)
+	assert.Equal(t, "Seeded Movie", listings[0].Movie.Title)
+	assert.Equal(t, "Main Theater", listings[0].Theater.Name)
+	assert.Equal(t, 49, listings[0].SeatsLeft)
+
+	w = get("date=" + tomorrow + "&movie_id=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	for _, query := range []string{"date=soon", "from=9am", "from=20:00&to=19:00", "theater_id=x"} {
+		w := get(query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
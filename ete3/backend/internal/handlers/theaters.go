@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"ete3/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTheaters returns all theaters
+func (h *Handler) GetTheaters(c *gin.Context) {
+	theaters, err := h.theaters.GetTheaters()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, theaters)
+}
+
+// GetTheater retrieves a theater by ID
+func (h *Handler) GetTheater(c *gin.Context) {
+	theaterID, ok := idParam(c, "id", "theater")
+	if !ok {
+		return
+	}
+
+	theater, err := h.theaters.GetTheaterByID(theaterID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, theater)
+}
+
+// GetTheaterSeats returns the seats of a theater
+func (h *Handler) GetTheaterSeats(c *gin.Context) {
+	theaterID, ok := idParam(c, "id", "theater")
+	if !ok {
+		return
+	}
+
+	if _, err := h.theaters.GetTheaterByID(theaterID); err != nil {
+		c.Error(err)
+		return
+	}
+	seats, err := h.theaters.GetAllSeatsForTheater(theaterID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, seats)
+}
+
+// CreateTheater creates a theater, with seats if a seat map is given
+func (h *Handler) CreateTheater(c *gin.Context) {
+	var req models.TheaterRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	theater := models.Theater{Name: req.Name}
+	if err := h.theaters.CreateTheater(&theater, req.SeatMap); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, theater)
+}
+
+// UpdateTheater renames a theater
+func (h *Handler) UpdateTheater(c *gin.Context) {
+	theaterID, ok := idParam(c, "id", "theater")
+	if !ok {
+		return
+	}
+
+	var req models.TheaterRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	theater := models.Theater{ID: theaterID, Name: req.Name}
+	if err := h.theaters.UpdateTheater(&theater); err != nil {
+		c.Error(err)
+		return
+	}
+
+	updated, err := h.theaters.GetTheaterByID(theaterID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTheater deletes a theater that has no shows
+func (h *Handler) DeleteTheater(c *gin.Context) {
+	theaterID, ok := idParam(c, "id", "theater")
+	if !ok {
+		return
+	}
+
+	if err := h.theaters.DeleteTheater(theaterID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Theater deleted successfully"})
+}
+
+// SetSeatMap replaces a theater's seats with the ones of an uploaded seat map
+func (h *Handler) SetSeatMap(c *gin.Context) {
+	theaterID, ok := idParam(c, "id", "theater")
+	if !ok {
+		return
+	}
+
+	var seatMap models.SeatMap
+	if !bindJSON(c, &seatMap) {
+		return
+	}
+
+	theater, err := h.theaters.SetSeatMap(theaterID, &seatMap)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, theater)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"ete3/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendJSON sends body as JSON to path with the given method and returns
+// the recorded response
+func sendJSON(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTheaterEndpoints(t *testing.T) {
+	router := setupRouter()
+	h := newTestHandler(t)
+	router.GET("/theaters", h.GetTheaters)
+	router.GET("/theaters/:id", h.GetTheater)
+	router.GET("/theaters/:id/seats", h.GetTheaterSeats)
+	router.POST("/theaters", h.CreateTheater)
+	router.PUT("/theaters/:id", h.UpdateTheater)
+	router.DELETE("/theaters/:id", h.DeleteTheater)
+	router.PUT("/theaters/:id/seats", h.SetSeatMap)
+
+	// Create with a seat map: 3 rows of 8 with an aisle after seat 4
+	w := postJSON(router, "/theaters", models.TheaterRequest{
+		Name:    "Studio 2",
+		SeatMap: &models.SeatMap{Rows: 3, SeatsPerRow: 9, Aisles: []int{5}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var theater models.Theater
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &theater))
+	assert.Equal(t, 24, theater.Capacity)
+	path := "/theaters/" + strconv.FormatInt(theater.ID, 10)
+
+	w = sendJSON(router, "GET", path+"/seats", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var seats []models.Seat
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &seats))
+	assert.Len(t, seats, 24)
+
+	w = sendJSON(router, "PUT", path+"/seats", models.SeatMap{Rows: 2, SeatsPerRow: 5})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &theater))
+	assert.Equal(t, 10, theater.Capacity)
+
+	w = sendJSON(router, "PUT", path+"/seats", models.SeatMap{Rows: 2, SeatsPerRow: 5, Aisles: []int{9}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `"invalid_seat_map"`, string(mustField(t, w.Body.Bytes(), "code")))
+
+	w = sendJSON(router, "PUT", path, models.TheaterRequest{Name: "Studio Two"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &theater))
+	assert.Equal(t, "Studio Two", theater.Name)
+	assert.Equal(t, 10, theater.Capacity)
+
+	// The seeded theater has a show and can't be changed
+	w = sendJSON(router, "PUT", "/theaters/1/seats", models.SeatMap{Rows: 1, SeatsPerRow: 1})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, http.StatusConflict, sendJSON(router, "DELETE", "/theaters/1", nil).Code)
+
+	w = sendJSON(router, "GET", "/theaters", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var theaters []models.Theater
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &theaters))
+	assert.Len(t, theaters, 2)
+
+	assert.Equal(t, http.StatusOK, sendJSON(router, "DELETE", path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", path+"/seats", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "GET", "/theaters/x", nil).Code)
+}
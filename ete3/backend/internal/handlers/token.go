@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"ete3/internal/config"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is an HMAC secret identified by the kid token header
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// JWTConfig controls how access tokens are signed and validated
+type JWTConfig struct {
+	// Current signs new tokens
+	Current SigningKey
+	// Previous, if set, still verifies tokens signed before a key rotation
+	Previous *SigningKey
+	Issuer   string
+	Audience string
+	// TokenTTL is the lifetime of access tokens
+	TokenTTL time.Duration
+	// RefreshTTL is the lifetime of refresh tokens
+	RefreshTTL time.Duration
+}
+
+// NewJWTConfig builds a JWTConfig from validated token settings
+func NewJWTConfig(c config.JWT) JWTConfig {
+	cfg := JWTConfig{
+		Current:    SigningKey{ID: c.KeyID, Secret: []byte(c.Secret)},
+		Issuer:     c.Issuer,
+		Audience:   c.Audience,
+		TokenTTL:   time.Duration(c.TokenTTL),
+		RefreshTTL: time.Duration(c.RefreshTTL),
+	}
+	if c.PreviousSecret != "" {
+		cfg.Previous = &SigningKey{ID: c.PreviousKeyID, Secret: []byte(c.PreviousSecret)}
+	}
+	return cfg
+}
+
+// Claims is the payload of the access tokens issued by Login
+type Claims struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// issueToken signs an access token for claims with the current key
+func (c JWTConfig) issueToken(claims Claims) (string, error) {
+	now := time.Now()
+	claims.Issuer = c.Issuer
+	claims.Audience = jwt.ClaimStrings{c.Audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(c.TokenTTL))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = c.Current.ID
+	return token.SignedString(c.Current.Secret)
+}
+
+// parseToken validates an HS256 token's signature, iss, aud, exp and nbf
+// and returns its claims
+func (c JWTConfig) parseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, c.lookupKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(c.Issuer),
+		jwt.WithAudience(c.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.UserID == 0 {
+		return nil, errors.New("token has no user_id claim")
+	}
+	return claims, nil
+}
+
+// lookupKey picks the verification key named by the token's kid header.
+// Tokens without a kid are checked against the current key.
+func (c JWTConfig) lookupKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	switch {
+	case kid == "" || kid == c.Current.ID:
+		return c.Current.Secret, nil
+	case c.Previous != nil && kid == c.Previous.ID:
+		return c.Previous.Secret, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// newRefreshToken returns a random opaque refresh token and the hash under
+// which it is stored
+func newRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+// hashRefreshToken returns the SHA-256 hex digest stored for a refresh token
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenFamily returns a random ID grouping the refresh tokens of one login
+func newTokenFamily() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"ete3/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWT signs and verifies the access tokens of the handler tests
+var testJWT = JWTConfig{
+	Current:    SigningKey{ID: "test", Secret: []byte("test-secret")},
+	Issuer:     "cinema-booking",
+	Audience:   "cinema-api",
+	TokenTTL:   15 * time.Minute,
+	RefreshTTL: 30 * 24 * time.Hour,
+}
+
+func TestParseTokenValidatesClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(claims map[string]interface{})
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			mutate:  func(claims map[string]interface{}) {},
+			wantErr: false,
+		},
+		{
+			name:    "Wrong Issuer",
+			mutate:  func(claims map[string]interface{}) { claims["iss"] = "someone-else" },
+			wantErr: true,
+		},
+		{
+			name:    "Wrong Audience",
+			mutate:  func(claims map[string]interface{}) { claims["aud"] = "other-api" },
+			wantErr: true,
+		},
+		{
+			name:    "Missing Expiry",
+			mutate:  func(claims map[string]interface{}) { delete(claims, "exp") },
+			wantErr: true,
+		},
+		{
+			name:    "Not Yet Valid",
+			mutate:  func(claims map[string]interface{}) { claims["nbf"] = time.Now().Add(time.Hour).Unix() },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validTestClaims()
+			tt.mutate(claims)
+			_, err := testJWT.parseToken(signTestToken(t, testJWT.Current, claims))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseTokenKeyRotation(t *testing.T) {
+	oldKey := SigningKey{ID: "2025-01", Secret: []byte("old-secret")}
+	newKey := SigningKey{ID: "2025-02", Secret: []byte("new-secret")}
+
+	// Issue a token before the rotation
+	before := JWTConfig{Current: oldKey, Issuer: "test", Audience: "test", TokenTTL: time.Hour}
+	oldToken, err := before.issueToken(Claims{UserID: 7})
+	assert.NoError(t, err)
+
+	// Rotate: the old key is kept as the previous key
+	rotated := JWTConfig{Current: newKey, Previous: &oldKey, Issuer: "test", Audience: "test", TokenTTL: time.Hour}
+	claims, err := rotated.parseToken(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserID)
+
+	newToken, err := rotated.issueToken(Claims{UserID: 8})
+	assert.NoError(t, err)
+	_, err = rotated.parseToken(newToken)
+	assert.NoError(t, err)
+
+	// Once the previous key is dropped, old sessions are rejected
+	after := JWTConfig{Current: newKey, Issuer: "test", Audience: "test", TokenTTL: time.Hour}
+	_, err = after.parseToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestNewJWTConfig(t *testing.T) {
+	cfg := NewJWTConfig(config.JWT{
+		Secret:         "s3cret",
+		KeyID:          "k2",
+		PreviousSecret: "old",
+		PreviousKeyID:  "k1",
+		Issuer:         "issuer",
+		Audience:       "audience",
+		TokenTTL:       config.Duration(15 * time.Minute),
+		RefreshTTL:     config.Duration(time.Hour),
+	})
+	assert.Equal(t, SigningKey{ID: "k2", Secret: []byte("s3cret")}, cfg.Current)
+	assert.Equal(t, &SigningKey{ID: "k1", Secret: []byte("old")}, cfg.Previous)
+	assert.Equal(t, "issuer", cfg.Issuer)
+	assert.Equal(t, "audience", cfg.Audience)
+	assert.Equal(t, 15*time.Minute, cfg.TokenTTL)
+	assert.Equal(t, time.Hour, cfg.RefreshTTL)
+
+	cfg = NewJWTConfig(config.Default().JWT)
+	assert.Nil(t, cfg.Previous)
+}
@@ -0,0 +1,106 @@
+// Package live fans seat state changes out to the clients watching a
+// show's seat map.
+package live
+
+import (
+	"ete3/internal/models"
+	"sync"
+)
+
+// DefaultBuffer is how many events a subscriber may fall behind by before
+// it is dropped
+const DefaultBuffer = 64
+
+// Hub delivers the seat events published for a show to its subscribers.
+// Publishing never blocks: a subscriber whose buffer is full is dropped,
+// so one slow client can't hold up bookings, and has to resubscribe and
+// start over from a fresh snapshot. A nil Hub discards every event.
+type Hub struct {
+	buffer int
+
+	mu   sync.Mutex
+	subs map[int64]map[*Subscription]struct{}
+}
+
+// Subscription receives the events of one show until it is closed, either
+// by its owner or by the hub when it falls behind
+type Subscription struct {
+	hub    *Hub
+	showID int64
+	events chan models.SeatEvent
+}
+
+// NewHub returns a Hub buffering up to buffer events per subscriber; zero
+// or less means DefaultBuffer
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{buffer: buffer, subs: make(map[int64]map[*Subscription]struct{})}
+}
+
+// Subscribe starts delivering the events of showID
+func (h *Hub) Subscribe(showID int64) *Subscription {
+	sub := &Subscription{hub: h, showID: showID, events: make(chan models.SeatEvent, h.buffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[showID] == nil {
+		h.subs[showID] = make(map[*Subscription]struct{})
+	}
+	h.subs[showID][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers events to the subscribers of their shows
+func (h *Hub) Publish(events ...models.SeatEvent) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range events {
+		for sub := range h.subs[event.ShowID] {
+			select {
+			case sub.events <- event:
+			default:
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribers returns how many subscribers showID has
+func (h *Hub) Subscribers(showID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[showID])
+}
+
+// remove drops sub and closes its channel; h.mu must be held
+func (h *Hub) remove(sub *Subscription) {
+	subs := h.subs[sub.showID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.showID)
+	}
+	close(sub.events)
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription ends; if that wasn't Close, the subscriber fell behind
+// and missed events.
+func (s *Subscription) Events() <-chan models.SeatEvent {
+	return s.events
+}
+
+// Close stops delivering events. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
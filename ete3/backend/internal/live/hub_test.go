@@ -0,0 +1,66 @@
+package live
+
+import (
+	"ete3/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubDelivers(t *testing.T) {
+	hub := NewHub(4)
+	first := hub.Subscribe(1)
+	second := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	assert.Equal(t, 2, hub.Subscribers(1))
+
+	held := models.SeatEvent{ShowID: 1, SeatIDs: []int64{3, 4}, State: models.SeatHeld}
+	hub.Publish(held, models.SeatEvent{ShowID: 3, SeatIDs: []int64{1}, State: models.SeatBooked})
+
+	assert.Equal(t, held, <-first.Events())
+	assert.Equal(t, held, <-second.Events())
+	assert.Empty(t, other.Events())
+
+	first.Close()
+	first.Close()
+	_, open := <-first.Events()
+	assert.False(t, open)
+	assert.Equal(t, 1, hub.Subscribers(1))
+
+	second.Close()
+	other.Close()
+	assert.Zero(t, hub.Subscribers(1))
+	assert.Zero(t, hub.Subscribers(2))
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(2)
+	slow := hub.Subscribe(1)
+	fast := hub.Subscribe(1)
+
+	for seat := int64(1); seat <= 3; seat++ {
+		hub.Publish(models.SeatEvent{ShowID: 1, SeatIDs: []int64{seat}, State: models.SeatBooked})
+		if seat < 3 {
+			<-fast.Events()
+		}
+	}
+
+	// The slow subscriber got what fitted in its buffer, then was dropped
+	var seats []int64
+	for event := range slow.Events() {
+		seats = append(seats, event.SeatIDs...)
+	}
+	assert.Equal(t, []int64{1, 2}, seats)
+
+	event, open := <-fast.Events()
+	require.True(t, open)
+	assert.Equal(t, []int64{3}, event.SeatIDs)
+	assert.Equal(t, 1, hub.Subscribers(1))
+	slow.Close()
+}
+
+func TestNilHub(t *testing.T) {
+	var hub *Hub
+	hub.Publish(models.SeatEvent{ShowID: 1, State: models.SeatReleased})
+}
@@ -0,0 +1,15 @@
+package models
+
+// Seat states pushed to live seat maps
+const (
+	SeatHeld     = "held"     // held by a pending booking
+	SeatBooked   = "booked"   // part of a confirmed booking
+	SeatReleased = "released" // available again after a cancellation or an expired hold
+)
+
+// SeatEvent reports seats of a show changing state
+type SeatEvent struct {
+	ShowID  int64   `json:"show_id"`
+	SeatIDs []int64 `json:"seat_ids"`
+	State   string  `json:"state"`
+}
@@ -2,15 +2,16 @@ package models
 
 import (
 	"encoding/json"
+	"math"
 	"strings"
 	"time"
 )
 
 type Movie struct {
 	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
+	Title       string    `json:"title" binding:"required"`
 	Description string    `json:"description"`
-	Duration    int       `json:"duration"` // in minutes
+	Duration    int       `json:"duration" binding:"required,min=1"` // in minutes
 	Genre       string    `json:"genre"`
 	PosterURL   string    `json:"poster_url"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -18,11 +19,12 @@ type Movie struct {
 }
 
 type Theater struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Capacity  int       `json:"capacity"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int64          `json:"id"`
+	Name       string         `json:"name"`
+	Capacity   int            `json:"capacity"`
+	Categories []SeatCategory `json:"categories"` // always starts with StandardCategory
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
 }
 
 type Show struct {
@@ -31,9 +33,19 @@ type Show struct {
 	TheaterID int64     `json:"theater_id"`
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Price     float64   `json:"price"` // price of seats in categories missing from Prices
+	// Prices maps seat category codes to the price of their seats
+	Prices    map[string]float64 `json:"prices,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// PriceFor returns the price of a seat of the given category
+func (s *Show) PriceFor(category string) float64 {
+	if price, ok := s.Prices[category]; ok {
+		return price
+	}
+	return s.Price
 }
 
 type Seat struct {
@@ -41,46 +53,169 @@ type Seat struct {
 	TheaterID  int64     `json:"theater_id"`
 	RowNumber  int       `json:"row_number"`
 	SeatNumber int       `json:"seat_number"`
+	Category   string    `json:"category"` // code of one of the theater's categories
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// Order groups the seats bought together in one booking request
+type Order struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	ShowID    int64      `json:"show_id"`
+	Status    string     `json:"status"`               // "pending", "confirmed", "cancelled", "expired"
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // set while a hold is pending
+	PromoCode string     `json:"promo_code,omitempty"` // redeemed on the order, if any
+	Discount  float64    `json:"discount,omitempty"`   // taken off by the promo code
+	Items     []Booking  `json:"items"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Booking is a single seat line item of an Order
 type Booking struct {
-	ID        int64     `json:"id"`
-	ShowID    int64     `json:"show_id"`
-	SeatID    int64     `json:"seat_id"`
-	Status    string    `json:"status"` // "pending", "confirmed", "cancelled"
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64      `json:"id"`
+	OrderID   int64      `json:"order_id"`
+	ShowID    int64      `json:"show_id"`
+	SeatID    int64      `json:"seat_id"`
+	UserID    int64      `json:"user_id"`
+	Status    string     `json:"status"`               // "pending", "confirmed", "cancelled", "expired"
+	Price     float64    `json:"price"`                // price of the seat when booked
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // set while a hold is pending
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Total returns the sum of the prices of the order's items that aren't
+// cancelled or expired, less the order's discount and never negative
+func (o *Order) Total() float64 {
+	total := 0.0
+	for _, item := range o.Items {
+		if item.Status == "pending" || item.Status == "confirmed" {
+			total += item.Price
+		}
+	}
+	return math.Max(math.Round((total-o.Discount)*100)/100, 0)
 }
 
 type BookingRequest struct {
-	ShowID  int64   `json:"show_id" binding:"required"`
-	SeatIDs []int64 `json:"seat_ids" binding:"required"`
+	ShowID    int64   `json:"show_id" binding:"required"`
+	SeatIDs   []int64 `json:"seat_ids" binding:"required,min=1"`
+	PromoCode string  `json:"promo_code"` // optional
+	// PaymentToken is the payment method to charge, as issued by the
+	// payment provider's client-side library
+	PaymentToken string `json:"payment_token"`
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
 }
 
 type BookingResponse struct {
-	BookingID int64  `json:"booking_id"`
-	Status    string `json:"status"`
-	Message   string `json:"message"`
+	BookingID int64      `json:"booking_id"` // order ID
+	Status    string     `json:"status"`
+	Message   string     `json:"message"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // set for holds
+	Seats     []Booking  `json:"seats,omitempty"`      // line items of the order
+	PromoCode string     `json:"promo_code,omitempty"` // redeemed on the order, if any
+	Discount  float64    `json:"discount,omitempty"`   // taken off by the promo code
+	Total     float64    `json:"total"`                // sum of the seat prices less the discount
+	Payment   *Payment   `json:"payment,omitempty"`    // the charge that paid for a confirmed booking
 }
 
-// TheaterLayout represents a visual layout of seats in a theater
+// TheaterLayout represents a visual layout of seats in a theater. In the
+// layout string available seats appear as the code of their category, "A"
+// for standard seats.
 type TheaterLayout struct {
-	TheaterID int64          `json:"theater_id"`
-	Name      string         `json:"name"`
-	Rows      int            `json:"rows"`
-	Columns   int            `json:"columns"`
-	Layout    [][]SeatStatus `json:"-"`      // Won't be directly marshalled
-	LayoutMap string         `json:"layout"` // Custom marshalled field
+	TheaterID  int64              `json:"theater_id"`
+	Name       string             `json:"name"`
+	Rows       int                `json:"rows"`
+	Columns    int                `json:"columns"`
+	Categories []SeatCategory     `json:"categories"`
+	Prices     map[string]float64 `json:"prices,omitempty"` // show price per category code
+	Layout     [][]SeatStatus     `json:"-"`                // Won't be directly marshalled
+	LayoutMap  string             `json:"layout"`           // Custom marshalled field
+}
+
+// NewTheaterLayout lays out seats on a grid sized to the highest row and
+// seat number. occupied maps seat IDs to a status other than "available";
+// grid positions without a seat are "unavailable".
+func NewTheaterLayout(theater *Theater, seats []Seat, occupied map[int64]string) *TheaterLayout {
+	// Find max row and column to determine theater dimensions
+	maxRow, maxCol := 0, 0
+	for _, seat := range seats {
+		if seat.RowNumber > maxRow {
+			maxRow = seat.RowNumber
+		}
+		if seat.SeatNumber > maxCol {
+			maxCol = seat.SeatNumber
+		}
+	}
+
+	layout := &TheaterLayout{
+		TheaterID:  theater.ID,
+		Name:       theater.Name,
+		Rows:       maxRow,
+		Columns:    maxCol,
+		Categories: theater.Categories,
+		Layout:     make([][]SeatStatus, maxRow),
+	}
+
+	// Initialize the layout with all seats marked as unavailable
+	for i := range layout.Layout {
+		layout.Layout[i] = make([]SeatStatus, maxCol)
+		for j := range layout.Layout[i] {
+			layout.Layout[i][j] = SeatStatus{
+				Row:    i + 1,
+				Column: j + 1,
+				Status: "unavailable",
+			}
+		}
+	}
+
+	// Update the layout with actual seats and their status
+	for _, seat := range seats {
+		row := seat.RowNumber - 1
+		col := seat.SeatNumber - 1
+
+		if row >= 0 && row < maxRow && col >= 0 && col < maxCol {
+			status := "available"
+			if s, ok := occupied[seat.ID]; ok {
+				status = s
+			}
+
+			layout.Layout[row][col] = SeatStatus{
+				ID:       seat.ID,
+				Row:      seat.RowNumber,
+				Column:   seat.SeatNumber,
+				Status:   status,
+				Category: seat.Category,
+			}
+		}
+	}
+
+	return layout
+}
+
+// SetPrices lists the price of each of the layout's categories at show
+func (t *TheaterLayout) SetPrices(show *Show) {
+	t.Prices = make(map[string]float64, len(t.Categories))
+	for _, category := range t.Categories {
+		t.Prices[category.Code] = show.PriceFor(category.Code)
+	}
 }
 
 // SeatStatus represents the status of a seat
 type SeatStatus struct {
-	ID     int64  `json:"id"`
-	Row    int    `json:"row"`
-	Column int    `json:"column"`
-	Status string `json:"status"` // "available", "booked", "selected"
+	ID       int64  `json:"id"`
+	Row      int    `json:"row"`
+	Column   int    `json:"column"`
+	Status   string `json:"status"`   // "available", "booked", "selected"
+	Category string `json:"category"` // category code, empty for unavailable positions
 }
 
 // Custom marshalling for TheaterLayout
@@ -93,7 +228,11 @@ func (t TheaterLayout) MarshalJSON() ([]byte, error) {
 		for _, seat := range row {
 			switch seat.Status {
 			case "available":
-				layoutMap += "A"
+				if seat.Category == "" {
+					layoutMap += StandardCategory.Code
+				} else {
+					layoutMap += seat.Category
+				}
 			case "booked":
 				layoutMap += "B"
 			case "selected":
@@ -153,20 +292,21 @@ func (t *TheaterLayout) UnmarshalJSON(data []byte) error {
 				continue
 			}
 
-			status := "unavailable"
+			status, category := "available", string(char)
 			switch char {
-			case 'A':
-				status = "available"
 			case 'B':
-				status = "booked"
+				status, category = "booked", ""
 			case 'S':
-				status = "selected"
+				status, category = "selected", ""
+			case 'X':
+				status, category = "unavailable", ""
 			}
 
 			t.Layout[i][j] = SeatStatus{
-				Row:    i + 1,
-				Column: j + 1,
-				Status: status,
+				Row:      i + 1,
+				Column:   j + 1,
+				Status:   status,
+				Category: category,
 			}
 		}
 	}
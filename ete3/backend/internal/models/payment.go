@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Payment statuses
+const (
+	PaymentPending    = "pending"    // authorization requested
+	PaymentAuthorized = "authorized" // amount reserved, not yet collected
+	PaymentCaptured   = "captured"   // amount collected; refunds may follow
+	PaymentDeclined   = "declined"   // the gateway refused the charge
+	PaymentFailed     = "failed"     // the gateway didn't answer or capture failed
+	PaymentRefunded   = "refunded"   // the whole captured amount was returned
+)
+
+// Payment is a charge for an order at a payment provider
+type Payment struct {
+	ID      int64 `json:"id"`
+	OrderID int64 `json:"order_id"`
+	// Provider and ProviderRef identify the charge at the gateway
+	Provider    string  `json:"provider"`
+	ProviderRef string  `json:"provider_ref,omitempty"`
+	Amount      float64 `json:"amount"`
+	Refunded    float64 `json:"refunded,omitempty"`
+	Status      string  `json:"status"`
+	// Error says why a declined or failed payment didn't go through
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PaymentRequest carries the customer's payment method when confirming a
+// hold
+type PaymentRequest struct {
+	PaymentToken string `json:"payment_token"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// Quote prices a set of seats of a show as a booking of them would be
+// priced now
+type Quote struct {
+	ShowID int64       `json:"show_id"`
+	Seats  []SeatQuote `json:"seats"`
+	Total  float64     `json:"total"`
+}
+
+// SeatQuote is the price of one seat and how it follows from the show's
+// price for the seat's category
+type SeatQuote struct {
+	SeatID      int64             `json:"seat_id"`
+	Category    string            `json:"category"`
+	BasePrice   float64           `json:"base_price"`
+	Adjustments []PriceAdjustment `json:"adjustments"`
+	Price       float64           `json:"price"`
+}
+
+// PriceAdjustment is the change a pricing rule made to a seat price
+type PriceAdjustment struct {
+	Rule    string  `json:"rule"`
+	Type    string  `json:"type"`
+	Percent float64 `json:"percent"`
+	Amount  float64 `json:"amount"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Promo code discount types
+const (
+	PromoPercentage = "percentage" // Value percent off the order
+	PromoFixed      = "fixed"      // Value off the order, at most its subtotal
+)
+
+// PromoCode discounts the orders it is redeemed on. Zero limits and nil
+// restrictions don't restrict anything.
+type PromoCode struct {
+	ID    int64   `json:"id"`
+	Code  string  `json:"code"`
+	Type  string  `json:"type"` // PromoPercentage or PromoFixed
+	Value float64 `json:"value"`
+
+	MinSeats   int        `json:"min_seats,omitempty"`
+	MovieID    *int64     `json:"movie_id,omitempty"`
+	ShowID     *int64     `json:"show_id,omitempty"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// MaxRedemptions caps redemptions across all users and MaxPerUser
+	// those by a single user. Only pending and confirmed orders count.
+	MaxRedemptions int `json:"max_redemptions,omitempty"`
+	MaxPerUser     int `json:"max_per_user,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromoCodeRequest creates a promo code. Codes are case-insensitive and
+// stored in upper case.
+type PromoCodeRequest struct {
+	Code           string     `json:"code" binding:"required,max=32"`
+	Type           string     `json:"type" binding:"required,oneof=percentage fixed"`
+	Value          float64    `json:"value" binding:"required,gt=0"`
+	MinSeats       int        `json:"min_seats" binding:"min=0"`
+	MovieID        *int64     `json:"movie_id"`
+	ShowID         *int64     `json:"show_id"`
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	MaxRedemptions int        `json:"max_redemptions" binding:"min=0"`
+	MaxPerUser     int        `json:"max_per_user" binding:"min=0"`
+}
+
+// PromoCode returns the promo code the request describes, or an error
+// saying what is wrong with it
+func (r *PromoCodeRequest) PromoCode() (*PromoCode, error) {
+	code := NormalizePromoCode(r.Code)
+	if code == "" || strings.ContainsAny(code, " \t\r\n") {
+		return nil, fmt.Errorf("code %q must be non-empty and contain no spaces", r.Code)
+	}
+	if r.Type == PromoPercentage && r.Value > 100 {
+		return nil, fmt.Errorf("percentage %v is more than 100", r.Value)
+	}
+	if r.ValidFrom != nil && r.ValidUntil != nil && !r.ValidUntil.After(*r.ValidFrom) {
+		return nil, fmt.Errorf("valid_until must be after valid_from")
+	}
+
+	return &PromoCode{
+		Code:           code,
+		Type:           r.Type,
+		Value:          r.Value,
+		MinSeats:       r.MinSeats,
+		MovieID:        r.MovieID,
+		ShowID:         r.ShowID,
+		ValidFrom:      r.ValidFrom,
+		ValidUntil:     r.ValidUntil,
+		MaxRedemptions: r.MaxRedemptions,
+		MaxPerUser:     r.MaxPerUser,
+	}, nil
+}
+
+// NormalizePromoCode returns the stored form of a code as a customer may
+// type it
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidAt reports whether t falls in the code's validity window
+func (p *PromoCode) ValidAt(t time.Time) bool {
+	return (p.ValidFrom == nil || !t.Before(*p.ValidFrom)) &&
+		(p.ValidUntil == nil || t.Before(*p.ValidUntil))
+}
+
+// Discount returns the amount the code takes off an order costing
+// subtotal, rounded to cents and never more than subtotal
+func (p *PromoCode) Discount(subtotal float64) float64 {
+	discount := p.Value
+	if p.Type == PromoPercentage {
+		discount = subtotal * p.Value / 100
+	}
+	return math.Round(math.Min(discount, subtotal)*100) / 100
+}
+
+// PromoCodeUsage reports how often a promo code has been redeemed on
+// pending and confirmed orders
+type PromoCodeUsage struct {
+	PromoCode
+	Redemptions   int     `json:"redemptions"`
+	Users         int     `json:"users"`
+	TotalDiscount float64 `json:"total_discount"`
+	// Orders lists every order the code was redeemed on, including
+	// cancelled and expired ones; only set when reporting on one code
+	Orders []PromoRedemption `json:"orders,omitempty"`
+}
+
+// PromoRedemption is one order a promo code was redeemed on
+type PromoRedemption struct {
+	OrderID   int64     `json:"order_id"`
+	UserID    int64     `json:"user_id"`
+	ShowID    int64     `json:"show_id"`
+	Status    string    `json:"status"`
+	Discount  float64   `json:"discount"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPromoCodeDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		subtotal float64
+		want     float64
+	}{
+		{"Percentage", PromoCode{Type: PromoPercentage, Value: 15}, 25, 3.75},
+		{"Percentage Rounded", PromoCode{Type: PromoPercentage, Value: 33}, 10.5, 3.47},
+		{"Fixed", PromoCode{Type: PromoFixed, Value: 4}, 25, 4},
+		{"Fixed Above Subtotal", PromoCode{Type: PromoFixed, Value: 40}, 25, 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.Discount(tt.subtotal); got != tt.want {
+				t.Errorf("Expected discount %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPromoCodeValidAt(t *testing.T) {
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, 0)
+	promo := PromoCode{ValidFrom: &from, ValidUntil: &until}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{from.Add(-time.Second), false},
+		{from, true},
+		{until.Add(-time.Second), true},
+		{until, false},
+	} {
+		if got := promo.ValidAt(tt.at); got != tt.want {
+			t.Errorf("ValidAt(%s): expected %v, got %v", tt.at, tt.want, got)
+		}
+	}
+	if !(&PromoCode{}).ValidAt(from) {
+		t.Error("A code without a window should always be valid")
+	}
+}
+
+func TestPromoCodeRequest(t *testing.T) {
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	promo, err := (&PromoCodeRequest{Code: " spring30 ", Type: PromoPercentage, Value: 30, MaxPerUser: 1}).PromoCode()
+	if err != nil {
+		t.Fatalf("PromoCode failed: %v", err)
+	}
+	if promo.Code != "SPRING30" || promo.Value != 30 || promo.MaxPerUser != 1 {
+		t.Errorf("Unexpected promo code %+v", promo)
+	}
+
+	for name, req := range map[string]PromoCodeRequest{
+		"Space In Code":       {Code: "TWO WORDS", Type: PromoFixed, Value: 1},
+		"Over 100 Percent":    {Code: "ALL", Type: PromoPercentage, Value: 120},
+		"Window Ends Earlier": {Code: "BACKWARDS", Type: PromoFixed, Value: 1, ValidFrom: &from, ValidUntil: &from},
+	} {
+		if _, err := req.PromoCode(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
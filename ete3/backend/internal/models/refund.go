@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Refund statuses
+const (
+	RefundPending   = "pending"   // recorded, not yet returned through the gateway
+	RefundCompleted = "completed" // returned, or nothing was due
+	RefundFailed    = "failed"    // the gateway didn't return it
+)
+
+// OverrideTier names the tier of refunds staff set instead of the refund
+// policy
+const OverrideTier = "override"
+
+// Refund is the money handed back for a cancelled booking
+type Refund struct {
+	ID      int64 `json:"id"`
+	OrderID int64 `json:"order_id"`
+	// PaymentID is the captured payment refunded, if the order was paid
+	PaymentID *int64  `json:"payment_id,omitempty"`
+	Amount    float64 `json:"amount"`
+	// Percent of the payment is refunded, as decided by the refund policy
+	// tier named by Tier or by staff
+	Percent float64 `json:"percent"`
+	Tier    string  `json:"tier,omitempty"`
+	// Reason and IssuedBy audit refunds staff override the policy for
+	Reason   string `json:"reason,omitempty"`
+	IssuedBy *int64 `json:"issued_by,omitempty"`
+	Status   string `json:"status"`
+	// Error says why a failed refund wasn't returned
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CancelRequest is the optional body of a cancellation. Staff may set
+// RefundPercent to refund that share of the payment instead of what the
+// refund policy allows, giving a Reason for the audit trail.
+type CancelRequest struct {
+	RefundPercent *float64 `json:"refund_percent" binding:"omitempty,min=0,max=100"`
+	Reason        string   `json:"reason" binding:"max=500"`
+}
+
+// RefundOverride replaces the refund policy when staff cancel a booking
+type RefundOverride struct {
+	Percent  float64
+	Reason   string
+	IssuedBy int64 // the staff member's user ID
+}
+
+// CancelResponse reports a cancelled booking and its refund
+type CancelResponse struct {
+	BookingID int64   `json:"booking_id"`
+	Status    string  `json:"status"`
+	Message   string  `json:"message"`
+	Refund    *Refund `json:"refund"`
+}
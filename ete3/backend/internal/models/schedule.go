@@ -0,0 +1,230 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxScheduledShows caps how many shows one schedule template may produce
+const MaxScheduledShows = 500
+
+// maxScheduleDays is how far past its start a recurrence is followed
+const maxScheduleDays = 2 * 366
+
+// ScheduleTemplate schedules a movie in one theater at each of Times on
+// every date of a recurrence rule, starting at StartDate. Dates and times
+// are in the cinema's local time zone. MovieID is left out in the schedule
+// of a CreateMovieRequest and required everywhere else.
+type ScheduleTemplate struct {
+	MovieID   int64      `json:"movie_id"`
+	TheaterID int64      `json:"theater_id" binding:"required"`
+	StartDate string     `json:"start_date" binding:"required"` // YYYY-MM-DD
+	RRule     string     `json:"rrule" binding:"required"`      // e.g. FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20251130
+	Times     []ShowTime `json:"times" binding:"required,min=1,dive"`
+}
+
+// Shows returns the shows the template describes, in loc and in date
+// order. Their EndTime is left for the repository to fill in.
+func (t *ScheduleTemplate) Shows(loc *time.Location) ([]Show, error) {
+	start, err := time.ParseInLocation("2006-01-02", t.StartDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("start_date %q is not a YYYY-MM-DD date", t.StartDate)
+	}
+	rule, err := ParseRRule(t.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Times) == 0 {
+		return nil, fmt.Errorf("times must list at least one showtime")
+	}
+
+	dates, err := rule.Dates(start, MaxScheduledShows/len(t.Times))
+	if err != nil {
+		return nil, err
+	}
+	shows, err := showsOn(dates, t.Times, t.TheaterID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shows {
+		shows[i].MovieID = t.MovieID
+	}
+	return shows, nil
+}
+
+// ScheduledShow is a show a schedule template would create, with the
+// reason it can't be created if there is one
+type ScheduledShow struct {
+	Show
+	Conflict *ScheduleConflict `json:"conflict,omitempty"`
+}
+
+// ScheduleConflict explains why a scheduled show can't be created
+type ScheduleConflict struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// ShowID is the existing show it overlaps, if any
+	ShowID int64 `json:"show_id,omitempty"`
+	// WithIndex is the position of the earlier show of the same schedule
+	// it overlaps, if any
+	WithIndex *int `json:"with_index,omitempty"`
+}
+
+// SchedulePreview lists the shows a schedule template would create
+type SchedulePreview struct {
+	Shows     []ScheduledShow `json:"shows"`
+	Conflicts int             `json:"conflicts"`
+}
+
+// Recurrence is the subset of an iCalendar RRULE that schedules use:
+// DAILY or WEEKLY frequency with INTERVAL, BYDAY and either COUNT or UNTIL
+type Recurrence struct {
+	Freq     string // "DAILY" or "WEEKLY"
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    time.Time // last date included; zero when COUNT is used
+}
+
+// weekdays maps RRULE day codes to weekdays
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=SA,SU;COUNT=8",
+// with or without an "RRULE:" prefix. UNTIL dates are read in loc.
+func ParseRRule(rule string, loc *time.Location) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("rrule part %q is not KEY=VALUE", part)
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != "DAILY" && r.Freq != "WEEKLY" {
+				return nil, fmt.Errorf("rrule FREQ %q is not supported, use DAILY or WEEKLY", value)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("rrule INTERVAL %q is not a positive number", value)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("rrule COUNT %q is not a positive number", value)
+			}
+		case "UNTIL":
+			// Only the date matters; a time part such as T235959Z is ignored
+			date, _, _ := strings.Cut(value, "T")
+			if r.Until, err = time.ParseInLocation("20060102", date, loc); err != nil {
+				return nil, fmt.Errorf("rrule UNTIL %q is not a YYYYMMDD date", value)
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdays[strings.ToUpper(code)]
+				if !ok {
+					return nil, fmt.Errorf("rrule BYDAY %q is not a day code such as MO", code)
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		default:
+			return nil, fmt.Errorf("rrule part %s is not supported", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule needs a FREQ")
+	}
+	if (r.Count == 0) == r.Until.IsZero() {
+		return nil, fmt.Errorf("rrule needs either COUNT or UNTIL")
+	}
+	return r, nil
+}
+
+// Dates returns the dates the rule selects from start onwards, start being
+// a local midnight, up to two years ahead. Weeks start on Monday. It fails
+// if there would be no dates or more than limit.
+func (r *Recurrence) Dates(start time.Time, limit int) ([]time.Time, error) {
+	onDay := make(map[time.Weekday]bool)
+	for _, day := range r.ByDay {
+		onDay[day] = true
+	}
+	if r.Freq == "WEEKLY" && len(onDay) == 0 {
+		onDay[start.Weekday()] = true
+	}
+
+	// Monday of the week start falls in, for counting weekly intervals
+	weekStart := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+
+	var dates []time.Time
+	for day := 0; day <= maxScheduleDays; day++ {
+		date := start.AddDate(0, 0, day)
+		if !r.Until.IsZero() && date.After(r.Until) {
+			break
+		}
+
+		selected := true
+		switch r.Freq {
+		case "DAILY":
+			selected = day%r.Interval == 0 && (len(onDay) == 0 || onDay[date.Weekday()])
+		case "WEEKLY":
+			week := daysBetween(weekStart, date) / 7
+			selected = week%r.Interval == 0 && onDay[date.Weekday()]
+		}
+		if !selected {
+			continue
+		}
+
+		if len(dates) == limit {
+			return nil, fmt.Errorf("rrule selects more than %d dates", limit)
+		}
+		dates = append(dates, date)
+		if r.Count > 0 && len(dates) == r.Count {
+			break
+		}
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("rrule selects no dates")
+	}
+	return dates, nil
+}
+
+// daysBetween counts calendar days from a to b, ignoring daylight saving
+// changes
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+// showsOn returns a show at each of times on every date, in the dates' time
+// zone
+func showsOn(dates []time.Time, times []ShowTime, theaterID int64) ([]Show, error) {
+	clocks := make([]time.Time, len(times))
+	for i, t := range times {
+		var err error
+		if clocks[i], err = time.Parse("15:04", t.Time); err != nil {
+			return nil, fmt.Errorf("time %q is not an HH:MM time", t.Time)
+		}
+	}
+
+	var shows []Show
+	for _, date := range dates {
+		for i, t := range times {
+			shows = append(shows, Show{
+				TheaterID: theaterID,
+				StartTime: time.Date(date.Year(), date.Month(), date.Day(),
+					clocks[i].Hour(), clocks[i].Minute(), 0, 0, date.Location()),
+				Price:  t.Price,
+				Prices: t.Prices,
+			})
+		}
+	}
+	return shows, nil
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceDates(t *testing.T) {
+	// 2030-11-01 is a Friday
+	start := time.Date(2030, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule string
+		want []string
+	}{
+		{"Weekdays Until", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20301108", []string{
+			"2030-11-01", "2030-11-04", "2030-11-05", "2030-11-06", "2030-11-07", "2030-11-08",
+		}},
+		{"Daily Count", "FREQ=DAILY;COUNT=3", []string{"2030-11-01", "2030-11-02", "2030-11-03"}},
+		{"Daily Interval", "RRULE:FREQ=DAILY;INTERVAL=2;COUNT=3", []string{"2030-11-01", "2030-11-03", "2030-11-05"}},
+		{"Weekly On Start Day", "FREQ=WEEKLY;COUNT=2", []string{"2030-11-01", "2030-11-08"}},
+		// Weeks start on Monday, so the Sunday after the start is still in
+		// the first week and the next one falls in the skipped week
+		{"Fortnightly Weekends", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU;UNTIL=20301117T235959Z", []string{
+			"2030-11-02", "2030-11-03", "2030-11-16", "2030-11-17",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseRRule failed: %v", err)
+			}
+			dates, err := rule.Dates(start, 100)
+			if err != nil {
+				t.Fatalf("Dates failed: %v", err)
+			}
+			if len(dates) != len(tt.want) {
+				t.Fatalf("Expected %d dates, got %v", len(tt.want), dates)
+			}
+			for i, w := range tt.want {
+				if got := dates[i].Format("2006-01-02"); got != w {
+					t.Errorf("Date %d: expected %s, got %s", i, w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrenceInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"No Freq", "COUNT=3"},
+		{"Monthly", "FREQ=MONTHLY;COUNT=3"},
+		{"No End", "FREQ=DAILY"},
+		{"Count And Until", "FREQ=DAILY;COUNT=3;UNTIL=20301130"},
+		{"Bad Interval", "FREQ=DAILY;INTERVAL=0;COUNT=3"},
+		{"Bad Day", "FREQ=WEEKLY;BYDAY=XX;COUNT=3"},
+		{"Unsupported Part", "FREQ=DAILY;COUNT=3;BYHOUR=19"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRRule(tt.rule, time.UTC); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	start := time.Date(2030, 11, 1, 0, 0, 0, 0, time.UTC)
+	rule, err := ParseRRule("FREQ=DAILY;UNTIL=20301031", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseRRule failed: %v", err)
+	}
+	if _, err := rule.Dates(start, 10); err == nil {
+		t.Error("Expected an error for a rule that ends before it starts")
+	}
+	rule, err = ParseRRule("FREQ=DAILY;COUNT=11", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseRRule failed: %v", err)
+	}
+	if _, err := rule.Dates(start, 10); err == nil {
+		t.Error("Expected an error for a rule over the limit")
+	}
+}
+
+func TestScheduleTemplateShows(t *testing.T) {
+	template := ScheduleTemplate{
+		MovieID:   3,
+		TheaterID: 2,
+		StartDate: "2030-11-01",
+		RRule:     "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20301130",
+		Times:     []ShowTime{{Time: "19:00", Price: 10}, {Time: "21:30", Price: 12}},
+	}
+
+	shows, err := template.Shows(time.UTC)
+	if err != nil {
+		t.Fatalf("Shows failed: %v", err)
+	}
+	// November 2030 has 21 weekdays
+	if len(shows) != 42 {
+		t.Fatalf("Expected 42 shows, got %d", len(shows))
+	}
+	if shows[0].MovieID != 3 || shows[0].TheaterID != 2 {
+		t.Errorf("Unexpected movie %d or theater %d", shows[0].MovieID, shows[0].TheaterID)
+	}
+	if got := shows[41].StartTime.Format(time.RFC3339); got != "2030-11-29T21:30:00Z" {
+		t.Errorf("Expected the last show on 2030-11-29 at 21:30, got %s", got)
+	}
+
+	template.RRule = "FREQ=DAILY;COUNT=251"
+	if _, err := template.Shows(time.UTC); err == nil {
+		t.Errorf("Expected an error for more than %d shows", MaxScheduledShows)
+	}
+}
+
+func TestScheduleTemplateShowsInZone(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	template := ScheduleTemplate{
+		TheaterID: 2,
+		StartDate: "2030-03-01",
+		RRule:     "FREQ=DAILY;COUNT=2",
+		Times:     []ShowTime{{Time: "18:00", Price: 10}, {Time: "21:30", Price: 12}},
+	}
+
+	shows, err := template.Shows(loc)
+	if err != nil {
+		t.Fatalf("Shows failed: %v", err)
+	}
+
+	want := []struct {
+		start string
+		price float64
+	}{
+		{"2030-03-01T18:00:00+01:00", 10},
+		{"2030-03-01T21:30:00+01:00", 12},
+		{"2030-03-02T18:00:00+01:00", 10},
+		{"2030-03-02T21:30:00+01:00", 12},
+	}
+	if len(shows) != len(want) {
+		t.Fatalf("Expected %d shows, got %d", len(want), len(shows))
+	}
+	for i, w := range want {
+		if got := shows[i].StartTime.Format(time.RFC3339); got != w.start {
+			t.Errorf("Show %d: expected start %s, got %s", i, w.start, got)
+		}
+		if shows[i].Price != w.price || shows[i].TheaterID != 2 {
+			t.Errorf("Show %d: unexpected price %v or theater %d", i, shows[i].Price, shows[i].TheaterID)
+		}
+	}
+}
+
+func TestScheduleTemplateShowsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template ScheduleTemplate
+	}{
+		{"Bad Date", ScheduleTemplate{StartDate: "03/01/2030", RRule: "FREQ=DAILY;COUNT=1", Times: []ShowTime{{Time: "18:00"}}}},
+		{"Bad Time", ScheduleTemplate{StartDate: "2030-03-01", RRule: "FREQ=DAILY;COUNT=1", Times: []ShowTime{{Time: "6pm"}}}},
+		{"No Times", ScheduleTemplate{StartDate: "2030-03-01", RRule: "FREQ=DAILY;COUNT=1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.template.Shows(time.UTC); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
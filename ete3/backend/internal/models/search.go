@@ -0,0 +1,149 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Movie catalogue sort orders
+const (
+	SortRelevance    = "relevance" // best search match first; the default when searching
+	SortTitle        = "title"     // the default otherwise
+	SortTitleDesc    = "-title"
+	SortDuration     = "duration"
+	SortDurationDesc = "-duration"
+	SortNewest       = "newest" // most recently added first
+)
+
+// Page sizes of the movie catalogue
+const (
+	DefaultMovieLimit = 20
+	MaxMovieLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that weren't issued for the
+// query's sort order
+var ErrInvalidCursor = errors.New("cursor is invalid or belongs to another sort order")
+
+// MovieQuery selects a page of the movie catalogue. Zero fields don't
+// filter.
+type MovieQuery struct {
+	// Search matches movies whose title or description contain every word
+	Search      string `form:"q" binding:"max=200"`
+	Genre       string `form:"genre"`
+	MinDuration int    `form:"min_duration" binding:"min=0"`
+	MaxDuration int    `form:"max_duration" binding:"min=0"`
+	// Date keeps movies with a show starting that day, as YYYY-MM-DD in
+	// the server's time zone
+	Date   string `form:"date"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=relevance title -title duration -duration newest"`
+	Limit  int    `form:"limit" binding:"min=0,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+// Validate reports what is wrong with the query beyond what its bindings
+// check. The cursor is checked by DecodeCursor.
+func (q *MovieQuery) Validate() error {
+	if q.MaxDuration > 0 && q.MaxDuration < q.MinDuration {
+		return fmt.Errorf("max_duration %d is less than min_duration %d", q.MaxDuration, q.MinDuration)
+	}
+	if _, _, err := q.ShowDay(); err != nil {
+		return err
+	}
+	if q.Sort == SortRelevance && len(q.SearchTerms()) == 0 {
+		return errors.New("sort=relevance needs a search")
+	}
+	return nil
+}
+
+// SearchTerms returns the words of Search, lower-cased
+func (q *MovieQuery) SearchTerms() []string {
+	return strings.FieldsFunc(strings.ToLower(q.Search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SortOrder returns Sort, or the default order if it's empty
+func (q *MovieQuery) SortOrder() string {
+	switch {
+	case q.Sort != "":
+		return q.Sort
+	case len(q.SearchTerms()) > 0:
+		return SortRelevance
+	}
+	return SortTitle
+}
+
+// PageSize returns Limit, or DefaultMovieLimit if it's zero, at most
+// MaxMovieLimit
+func (q *MovieQuery) PageSize() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultMovieLimit
+	case q.Limit > MaxMovieLimit:
+		return MaxMovieLimit
+	}
+	return q.Limit
+}
+
+// ShowDay returns the start and end of the day Date names, in local time,
+// or zero times if Date is empty
+func (q *MovieQuery) ShowDay() (from, until time.Time, err error) {
+	if q.Date == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	from, err = time.ParseInLocation("2006-01-02", q.Date, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("date %q is not YYYY-MM-DD", q.Date)
+	}
+	return from, from.AddDate(0, 0, 1), nil
+}
+
+// MovieCursor is the position after the last movie of a page in its sort
+// order. Only the key of the order is set.
+type MovieCursor struct {
+	Sort     string  `json:"s"`
+	Title    string  `json:"t,omitempty"` // lower-cased
+	Duration int     `json:"d,omitempty"`
+	Rank     float64 `json:"r,omitempty"`
+	ID       int64   `json:"i"`
+}
+
+// Encode returns the cursor as an opaque string
+func (c *MovieCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the position Cursor encodes, nil if it is empty,
+// or ErrInvalidCursor
+func (q *MovieQuery) DecodeCursor() (*MovieCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor MovieCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != q.SortOrder() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// MoviePage is one page of the movie catalogue
+type MoviePage struct {
+	Movies []Movie `json:"movies"`
+	// NextCursor fetches the following page; empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+	// SearchDegraded is set when a search ran without the full-text
+	// index: words match anywhere instead of as prefixes, and relevance
+	// sorts by title
+	SearchDegraded bool `json:"search_degraded,omitempty"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShowRequest creates or reschedules a show. The end time follows from the
+// movie's duration. Prices sets the price of seat categories that don't
+// cost Price.
+type ShowRequest struct {
+	MovieID   int64              `json:"movie_id" binding:"required"`
+	TheaterID int64              `json:"theater_id" binding:"required"`
+	StartTime time.Time          `json:"start_time" binding:"required"`
+	Price     float64            `json:"price" binding:"required,gt=0"`
+	Prices    map[string]float64 `json:"prices" binding:"dive,gt=0"`
+}
+
+// CreateMovieRequest is a movie with an optional schedule for its first
+// shows. The schedule leaves out its movie ID: the shows are for the new
+// movie.
+type CreateMovieRequest struct {
+	Movie
+	Schedule *ScheduleTemplate `json:"schedule"`
+}
+
+// MovieWithShows is a created movie with the shows its schedule produced
+type MovieWithShows struct {
+	Movie
+	Shows []Show `json:"shows,omitempty"`
+}
+
+// ShowTime is a daily showtime and its ticket prices
+type ShowTime struct {
+	Time   string             `json:"time" binding:"required"` // HH:MM
+	Price  float64            `json:"price" binding:"required,gt=0"`
+	Prices map[string]float64 `json:"prices" binding:"dive,gt=0"` // by seat category, as in Show
+}
+
+// ShowQuery selects the shows starting on Date, between the From and To
+// times of day, in the cinema's local time zone. Zero IDs don't filter.
+type ShowQuery struct {
+	Date      string `form:"date"` // YYYY-MM-DD; today if empty
+	From      string `form:"from"` // HH:MM; start of the day if empty
+	To        string `form:"to"`   // HH:MM, exclusive; end of the day if empty
+	TheaterID int64  `form:"theater_id" binding:"min=0"`
+	MovieID   int64  `form:"movie_id" binding:"min=0"`
+}
+
+// Window returns the start times the query covers, in now's time zone.
+// Shows that have started by now are left out, so the window may be empty.
+func (q *ShowQuery) Window(now time.Time) (from, until time.Time, err error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if q.Date != "" {
+		if day, err = time.ParseInLocation("2006-01-02", q.Date, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("date %q is not a YYYY-MM-DD date", q.Date)
+		}
+	}
+
+	from, until = day, day.AddDate(0, 0, 1)
+	for _, bound := range []struct {
+		name  string
+		value string
+		t     *time.Time
+	}{{"from", q.From, &from}, {"to", q.To, &until}} {
+		if bound.value == "" {
+			continue
+		}
+		clock, err := time.Parse("15:04", bound.value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%s %q is not an HH:MM time", bound.name, bound.value)
+		}
+		*bound.t = time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location())
+	}
+	if !from.Before(until) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from %s is not before to %s", from.Format("15:04"), until.Format("15:04"))
+	}
+
+	if from.Before(now) {
+		from = now
+	}
+	return from, until, nil
+}
+
+// TheaterSummary identifies the theater of a ShowListing
+type TheaterSummary struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// ShowListing is a show with its movie and theater and the number of its
+// seats that are neither booked nor held
+type ShowListing struct {
+	Show
+	Movie     Movie          `json:"movie"`
+	Theater   TheaterSummary `json:"theater"`
+	SeatsLeft int            `json:"seats_left"`
+}
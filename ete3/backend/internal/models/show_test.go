@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShowQueryWindow(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2030, 3, 1, 17, 30, 0, 0, loc)
+
+	tests := []struct {
+		name        string
+		query       ShowQuery
+		from, until string
+	}{
+		{"Rest Of Today", ShowQuery{}, "2030-03-01T17:30:00+01:00", "2030-03-02T00:00:00+01:00"},
+		{"Tonight", ShowQuery{From: "18:00", To: "23:00"}, "2030-03-01T18:00:00+01:00", "2030-03-01T23:00:00+01:00"},
+		{"Another Day", ShowQuery{Date: "2030-03-04", To: "12:00"}, "2030-03-04T00:00:00+01:00", "2030-03-04T12:00:00+01:00"},
+		{"Past Day", ShowQuery{Date: "2030-02-27"}, "2030-03-01T17:30:00+01:00", "2030-02-28T00:00:00+01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, until, err := tt.query.Window(now)
+			if err != nil {
+				t.Fatalf("Window failed: %v", err)
+			}
+			if got := from.Format(time.RFC3339); got != tt.from {
+				t.Errorf("Expected from %s, got %s", tt.from, got)
+			}
+			if got := until.Format(time.RFC3339); got != tt.until {
+				t.Errorf("Expected until %s, got %s", tt.until, got)
+			}
+		})
+	}
+
+	for _, query := range []ShowQuery{
+		{Date: "tomorrow"},
+		{From: "6pm"},
+		{From: "20:00", To: "18:00"},
+	} {
+		if _, _, err := query.Window(now); err == nil {
+			t.Errorf("Expected an error for %+v", query)
+		}
+	}
+}
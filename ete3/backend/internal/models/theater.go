@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TheaterRequest creates or renames a theater. SeatMap is only read when
+// creating; use the seat map endpoint to replace the seats later.
+type TheaterRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	SeatMap *SeatMap `json:"seat_map"`
+}
+
+// SeatCategory is a class of seats in a theater, such as recliners or
+// wheelchair spaces. Its one-character Code marks available seats of the
+// category in the theater layout string.
+type SeatCategory struct {
+	Code string `json:"code" binding:"required,len=1"`
+	Name string `json:"name" binding:"required"`
+}
+
+// StandardCategory is the category of every seat not put in another one
+var StandardCategory = SeatCategory{Code: "A", Name: "Standard"}
+
+// reservedCodes are the layout characters that can't be category codes:
+// the standard category and the booked, selected and unavailable states
+const reservedCodes = "ABSX"
+
+// SeatMap describes a theater's seating as a grid of Rows by SeatsPerRow
+// positions. Aisles leave a seat position empty in every row and Gaps leave
+// single positions empty. Seats are numbered by their position, so empty
+// positions show up as unavailable in the theater layout. Seats are
+// standard unless Categories puts them in another category.
+type SeatMap struct {
+	Rows        int               `json:"rows" binding:"required,min=1,max=100"`
+	SeatsPerRow int               `json:"seats_per_row" binding:"required,min=1,max=100"`
+	Aisles      []int             `json:"aisles"`
+	Gaps        []SeatPosition    `json:"gaps" binding:"dive"`
+	Categories  []CategorySection `json:"categories" binding:"dive"`
+}
+
+// CategorySection puts whole rows and single seats of a seat map in a
+// category
+type CategorySection struct {
+	SeatCategory
+	Rows  []int          `json:"rows"`
+	Seats []SeatPosition `json:"seats" binding:"dive"`
+}
+
+// SeatPosition is a row and seat position in a SeatMap, both from 1
+type SeatPosition struct {
+	Row  int `json:"row" binding:"required,min=1"`
+	Seat int `json:"seat" binding:"required,min=1"`
+}
+
+// Positions returns the positions that hold a seat, row by row. It fails
+// if an aisle or gap lies outside the grid or if no seat is left.
+func (m *SeatMap) Positions() ([]SeatPosition, error) {
+	if m.Rows < 1 || m.SeatsPerRow < 1 {
+		return nil, fmt.Errorf("rows and seats_per_row must be positive")
+	}
+
+	aisles := make(map[int]bool, len(m.Aisles))
+	for _, seat := range m.Aisles {
+		if seat < 1 || seat > m.SeatsPerRow {
+			return nil, fmt.Errorf("aisle %d is outside seats 1-%d", seat, m.SeatsPerRow)
+		}
+		aisles[seat] = true
+	}
+
+	gaps := make(map[SeatPosition]bool, len(m.Gaps))
+	for _, gap := range m.Gaps {
+		if gap.Row < 1 || gap.Row > m.Rows || gap.Seat < 1 || gap.Seat > m.SeatsPerRow {
+			return nil, fmt.Errorf("gap at row %d seat %d is outside the %dx%d grid", gap.Row, gap.Seat, m.Rows, m.SeatsPerRow)
+		}
+		gaps[gap] = true
+	}
+
+	var positions []SeatPosition
+	for row := 1; row <= m.Rows; row++ {
+		for seat := 1; seat <= m.SeatsPerRow; seat++ {
+			position := SeatPosition{Row: row, Seat: seat}
+			if !aisles[seat] && !gaps[position] {
+				positions = append(positions, position)
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("seat map has no seats")
+	}
+	return positions, nil
+}
+
+// SeatCategories returns the seat map's categories, starting with
+// StandardCategory, and the category code of each position outside the
+// standard category. It fails if a code is reserved or used twice, if a
+// row or seat lies outside the grid or if a position is in two categories.
+func (m *SeatMap) SeatCategories() ([]SeatCategory, map[SeatPosition]string, error) {
+	categories := []SeatCategory{StandardCategory}
+	codes := make(map[SeatPosition]string)
+	seen := make(map[string]bool)
+
+	for _, section := range m.Categories {
+		code := section.Code
+		if len(code) != 1 || !isCodeChar(code[0]) || strings.Contains(reservedCodes, code) {
+			return nil, nil, fmt.Errorf("category code %q must be one upper case letter or digit other than A, B, S and X", code)
+		}
+		if seen[code] {
+			return nil, nil, fmt.Errorf("category code %s is used twice", code)
+		}
+		seen[code] = true
+		categories = append(categories, section.SeatCategory)
+
+		positions := append([]SeatPosition(nil), section.Seats...)
+		for _, row := range section.Rows {
+			if row < 1 || row > m.Rows {
+				return nil, nil, fmt.Errorf("category %s row %d is outside rows 1-%d", code, row, m.Rows)
+			}
+			for seat := 1; seat <= m.SeatsPerRow; seat++ {
+				positions = append(positions, SeatPosition{Row: row, Seat: seat})
+			}
+		}
+		for _, p := range positions {
+			if p.Row < 1 || p.Row > m.Rows || p.Seat < 1 || p.Seat > m.SeatsPerRow {
+				return nil, nil, fmt.Errorf("category %s seat at row %d seat %d is outside the %dx%d grid", code, p.Row, p.Seat, m.Rows, m.SeatsPerRow)
+			}
+			if other, ok := codes[p]; ok {
+				return nil, nil, fmt.Errorf("row %d seat %d is in both category %s and %s", p.Row, p.Seat, other, code)
+			}
+			codes[p] = code
+		}
+	}
+	return categories, codes, nil
+}
+
+// isCodeChar reports whether c is an upper case letter or a digit
+func isCodeChar(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
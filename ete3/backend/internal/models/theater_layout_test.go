@@ -222,3 +222,45 @@ func TestTheaterLayoutUnmarshalEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestTheaterLayoutCategories(t *testing.T) {
+	theater := &Theater{ID: 1, Name: "Test Theater", Categories: []SeatCategory{StandardCategory, {Code: "R", Name: "Recliner"}}}
+	seats := []Seat{
+		{ID: 1, RowNumber: 1, SeatNumber: 1, Category: "A"},
+		{ID: 2, RowNumber: 1, SeatNumber: 2, Category: "A"},
+		{ID: 3, RowNumber: 2, SeatNumber: 1, Category: "R"},
+		{ID: 4, RowNumber: 2, SeatNumber: 2, Category: "R"},
+	}
+	layout := NewTheaterLayout(theater, seats, map[int64]string{2: "booked", 4: "booked"})
+	layout.SetPrices(&Show{Price: 10, Prices: map[string]float64{"R": 15}})
+
+	data, err := json.Marshal(layout)
+	if err != nil {
+		t.Fatalf("Failed to marshal TheaterLayout: %v", err)
+	}
+
+	var result struct {
+		Layout string             `json:"layout"`
+		Prices map[string]float64 `json:"prices"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal test result: %v", err)
+	}
+	if result.Layout != "AB|RB|" {
+		t.Errorf("Expected layout to be %q, got %q", "AB|RB|", result.Layout)
+	}
+	if result.Prices["A"] != 10 || result.Prices["R"] != 15 {
+		t.Errorf("Unexpected prices %v", result.Prices)
+	}
+
+	var unmarshaled TheaterLayout
+	if err := json.Unmarshal(data, &unmarshaled); err != nil {
+		t.Fatalf("Failed to unmarshal TheaterLayout: %v", err)
+	}
+	if seat := unmarshaled.Layout[1][0]; seat.Status != "available" || seat.Category != "R" {
+		t.Errorf("Expected an available recliner, got %+v", seat)
+	}
+	if seat := unmarshaled.Layout[1][1]; seat.Status != "booked" || seat.Category != "" {
+		t.Errorf("Expected a booked seat without category, got %+v", seat)
+	}
+}
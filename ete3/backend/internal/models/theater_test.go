@@ -0,0 +1,103 @@
+package models
+
+import "testing"
+
+func TestSeatMapPositions(t *testing.T) {
+	seatMap := SeatMap{
+		Rows:        2,
+		SeatsPerRow: 5,
+		Aisles:      []int{3},
+		Gaps:        []SeatPosition{{Row: 2, Seat: 5}},
+	}
+
+	positions, err := seatMap.Positions()
+	if err != nil {
+		t.Fatalf("Positions failed: %v", err)
+	}
+
+	want := []SeatPosition{
+		{1, 1}, {1, 2}, {1, 4}, {1, 5},
+		{2, 1}, {2, 2}, {2, 4},
+	}
+	if len(positions) != len(want) {
+		t.Fatalf("Expected %d seats, got %d: %v", len(want), len(positions), positions)
+	}
+	for i := range want {
+		if positions[i] != want[i] {
+			t.Errorf("Seat %d: expected %v, got %v", i, want[i], positions[i])
+		}
+	}
+}
+
+func TestSeatMapPositionsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		seatMap SeatMap
+	}{
+		{"Empty Grid", SeatMap{Rows: 0, SeatsPerRow: 5}},
+		{"Aisle Outside Row", SeatMap{Rows: 1, SeatsPerRow: 5, Aisles: []int{6}}},
+		{"Gap Outside Grid", SeatMap{Rows: 1, SeatsPerRow: 5, Gaps: []SeatPosition{{Row: 2, Seat: 1}}}},
+		{"No Seats Left", SeatMap{Rows: 1, SeatsPerRow: 2, Aisles: []int{1, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.seatMap.Positions(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestSeatMapCategories(t *testing.T) {
+	seatMap := SeatMap{
+		Rows:        3,
+		SeatsPerRow: 4,
+		Categories: []CategorySection{
+			{SeatCategory: SeatCategory{Code: "R", Name: "Recliner"}, Rows: []int{3}},
+			{SeatCategory: SeatCategory{Code: "W", Name: "Wheelchair"}, Seats: []SeatPosition{{Row: 1, Seat: 1}}},
+		},
+	}
+
+	categories, codes, err := seatMap.SeatCategories()
+	if err != nil {
+		t.Fatalf("SeatCategories failed: %v", err)
+	}
+	if len(categories) != 3 || categories[0] != StandardCategory || categories[1].Code != "R" {
+		t.Errorf("Unexpected categories %v", categories)
+	}
+	if len(codes) != 5 || codes[SeatPosition{3, 2}] != "R" || codes[SeatPosition{1, 1}] != "W" {
+		t.Errorf("Unexpected category codes %v", codes)
+	}
+	if _, ok := codes[SeatPosition{2, 2}]; ok {
+		t.Error("Expected row 2 to stay standard")
+	}
+}
+
+func TestSeatMapCategoriesInvalid(t *testing.T) {
+	recliner := SeatCategory{Code: "R", Name: "Recliner"}
+	tests := []struct {
+		name     string
+		sections []CategorySection
+	}{
+		{"Reserved Code", []CategorySection{{SeatCategory: SeatCategory{Code: "B", Name: "Balcony"}, Rows: []int{1}}}},
+		{"Lower Case Code", []CategorySection{{SeatCategory: SeatCategory{Code: "r", Name: "Recliner"}, Rows: []int{1}}}},
+		{"Long Code", []CategorySection{{SeatCategory: SeatCategory{Code: "VIP", Name: "VIP"}, Rows: []int{1}}}},
+		{"Duplicate Code", []CategorySection{{SeatCategory: recliner, Rows: []int{1}}, {SeatCategory: recliner, Rows: []int{2}}}},
+		{"Row Outside Grid", []CategorySection{{SeatCategory: recliner, Rows: []int{3}}}},
+		{"Seat Outside Grid", []CategorySection{{SeatCategory: recliner, Seats: []SeatPosition{{Row: 1, Seat: 5}}}}},
+		{"Overlapping Sections", []CategorySection{
+			{SeatCategory: recliner, Rows: []int{1}},
+			{SeatCategory: SeatCategory{Code: "W", Name: "Wheelchair"}, Seats: []SeatPosition{{Row: 1, Seat: 2}}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seatMap := SeatMap{Rows: 2, SeatsPerRow: 4, Categories: tt.sections}
+			if _, _, err := seatMap.SeatCategories(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
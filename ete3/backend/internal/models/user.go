@@ -1,10 +1,18 @@
 package models
 
+// User roles, from least to most privileged
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	ID       int64  `json:"id"`
 	Username string `json:"username" binding:"required,unique"`
 	Email    string `json:"email" binding:"required,email,unique"`
 	Password string `json:"-" binding:"required,min=6"`
+	Role     string `json:"role"` // "customer", "staff", "admin"
 }
 
 type LoginRequest struct {
@@ -17,3 +25,20 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 }
+
+// RoleRequest changes a user's role
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=customer staff admin"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is returned by login and refresh
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	User         *User  `json:"user,omitempty"`
+}
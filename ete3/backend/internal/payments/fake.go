@@ -0,0 +1,141 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Payment method tokens the fake gateway reacts to. Any other token,
+// including an empty one, is a card that always pays.
+const (
+	TokenDecline        = "tok_decline"         // authorization is declined
+	TokenTimeout        = "tok_timeout"         // authorization times out
+	TokenCaptureDecline = "tok_capture_decline" // authorizes but the capture is declined
+	TokenCaptureTimeout = "tok_capture_timeout" // authorizes but the capture times out
+)
+
+// Fake is a deterministic in-process gateway. Outcomes depend only on the
+// payment method token, authorizations are numbered in order and webhooks
+// are signed with an HMAC-SHA256 of the payload.
+type Fake struct {
+	secret []byte
+
+	mu             sync.Mutex
+	authorizations map[string]*fakeAuthorization
+	nextID         int
+}
+
+// fakeAuthorization is the fake gateway's record of an authorization
+type fakeAuthorization struct {
+	token    string
+	amount   float64
+	captured bool
+	refunded float64
+}
+
+// NewFake returns a fake gateway signing webhooks with secret
+func NewFake(secret string) *Fake {
+	return &Fake{secret: []byte(secret), authorizations: make(map[string]*fakeAuthorization)}
+}
+
+// Name implements Provider
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// Authorize implements Provider
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (*Authorization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ErrTimeout
+	}
+	switch req.Token {
+	case TokenDecline:
+		return nil, ErrDeclined
+	case TokenTimeout:
+		return nil, ErrTimeout
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("fake_auth_%d", f.nextID)
+	f.authorizations[id] = &fakeAuthorization{token: req.Token, amount: req.Amount}
+	return &Authorization{ID: id, Amount: req.Amount}, nil
+}
+
+// Capture implements Provider
+func (f *Fake) Capture(ctx context.Context, authorizationID string, amount float64) error {
+	if err := ctx.Err(); err != nil {
+		return ErrTimeout
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := f.authorizations[authorizationID]
+	if auth == nil {
+		return ErrUnknownPayment
+	}
+	switch auth.token {
+	case TokenCaptureDecline:
+		return ErrDeclined
+	case TokenCaptureTimeout:
+		return ErrTimeout
+	}
+	if auth.captured || amount > auth.amount {
+		return ErrDeclined
+	}
+	auth.captured = true
+	auth.amount = amount
+	return nil
+}
+
+// Refund implements Provider
+func (f *Fake) Refund(ctx context.Context, authorizationID string, amount float64) error {
+	if err := ctx.Err(); err != nil {
+		return ErrTimeout
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := f.authorizations[authorizationID]
+	if auth == nil {
+		return ErrUnknownPayment
+	}
+	if !auth.captured || math.Round((auth.refunded+amount)*100) > math.Round(auth.amount*100) {
+		return ErrDeclined
+	}
+	auth.refunded += amount
+	return nil
+}
+
+// VerifyWebhook implements Provider
+func (f *Fake) VerifyWebhook(payload []byte, signature string) (*Event, error) {
+	want, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(want, f.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("parsing webhook: %w", err)
+	}
+	return &event, nil
+}
+
+// SignWebhook returns the signature the fake gateway sends with payload,
+// for simulating webhooks
+func (f *Fake) SignWebhook(payload []byte) string {
+	return hex.EncodeToString(f.sign(payload))
+}
+
+func (f *Fake) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeCharges(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("secret")
+
+	auth, err := fake.Authorize(ctx, AuthorizeRequest{OrderID: 1, Amount: 20, Token: "tok_visa"})
+	require.NoError(t, err)
+	assert.Equal(t, "fake_auth_1", auth.ID)
+
+	// Nothing can be refunded or captured twice, nor more than was authorized
+	assert.ErrorIs(t, fake.Refund(ctx, auth.ID, 5), ErrDeclined)
+	assert.ErrorIs(t, fake.Capture(ctx, auth.ID, 25), ErrDeclined)
+	require.NoError(t, fake.Capture(ctx, auth.ID, 20))
+	assert.ErrorIs(t, fake.Capture(ctx, auth.ID, 20), ErrDeclined)
+
+	require.NoError(t, fake.Refund(ctx, auth.ID, 15))
+	assert.ErrorIs(t, fake.Refund(ctx, auth.ID, 5.01), ErrDeclined)
+	require.NoError(t, fake.Refund(ctx, auth.ID, 5))
+
+	assert.ErrorIs(t, fake.Capture(ctx, "fake_auth_99", 1), ErrUnknownPayment)
+}
+
+func TestFakeTokens(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("secret")
+
+	_, err := fake.Authorize(ctx, AuthorizeRequest{Amount: 10, Token: TokenDecline})
+	assert.ErrorIs(t, err, ErrDeclined)
+	_, err = fake.Authorize(ctx, AuthorizeRequest{Amount: 10, Token: TokenTimeout})
+	assert.ErrorIs(t, err, ErrTimeout)
+
+	auth, err := fake.Authorize(ctx, AuthorizeRequest{Amount: 10, Token: TokenCaptureDecline})
+	require.NoError(t, err)
+	assert.ErrorIs(t, fake.Capture(ctx, auth.ID, 10), ErrDeclined)
+	auth, err = fake.Authorize(ctx, AuthorizeRequest{Amount: 10, Token: TokenCaptureTimeout})
+	require.NoError(t, err)
+	assert.ErrorIs(t, fake.Capture(ctx, auth.ID, 10), ErrTimeout)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = fake.Authorize(cancelled, AuthorizeRequest{Amount: 10})
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestFakeWebhook(t *testing.T) {
+	fake := NewFake("secret")
+	payload := []byte(`{"id":"evt_1","type":"payment.refunded","authorization_id":"fake_auth_1","amount":5}`)
+
+	event, err := fake.VerifyWebhook(payload, fake.SignWebhook(payload))
+	require.NoError(t, err)
+	assert.Equal(t, &Event{ID: "evt_1", Type: EventRefunded, AuthorizationID: "fake_auth_1", Amount: 5}, event)
+
+	_, err = fake.VerifyWebhook(payload, NewFake("other").SignWebhook(payload))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = fake.VerifyWebhook(payload, "not hex")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	bad := []byte(`not json`)
+	_, err = fake.VerifyWebhook(bad, fake.SignWebhook(bad))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,68 @@
+// Package payments defines how bookings are paid for. A Provider talks to
+// a payment gateway; Fake is an in-process gateway for development and
+// tests.
+package payments
+
+import (
+	"context"
+	"errors"
+)
+
+// Provider errors. Gateways wrap them so callers can tell a customer's
+// declined card from a gateway that didn't answer.
+var (
+	// ErrDeclined means the gateway refused the charge
+	ErrDeclined = errors.New("payment declined")
+	// ErrTimeout means the gateway didn't answer in time; the charge may
+	// or may not have gone through
+	ErrTimeout = errors.New("payment gateway timed out")
+	// ErrUnknownPayment means the gateway has no such authorization
+	ErrUnknownPayment = errors.New("unknown payment")
+	// ErrInvalidSignature means a webhook wasn't signed by the gateway
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Provider authorizes, captures and refunds card payments at a gateway.
+// Amounts are in the cinema's currency.
+type Provider interface {
+	// Name identifies the provider in stored payments
+	Name() string
+	// Authorize reserves the amount on the customer's payment method
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Authorization, error)
+	// Capture collects an authorized amount
+	Capture(ctx context.Context, authorizationID string, amount float64) error
+	// Refund returns part or all of a captured amount
+	Refund(ctx context.Context, authorizationID string, amount float64) error
+	// VerifyWebhook checks a webhook's signature and parses its event
+	VerifyWebhook(payload []byte, signature string) (*Event, error)
+}
+
+// AuthorizeRequest asks to reserve Amount for an order
+type AuthorizeRequest struct {
+	OrderID int64
+	Amount  float64
+	// Token identifies the customer's payment method, as issued by the
+	// gateway's client-side library
+	Token string
+}
+
+// Authorization is an amount reserved on a payment method
+type Authorization struct {
+	ID     string
+	Amount float64
+}
+
+// Webhook event types
+const (
+	EventCaptured = "payment.captured"
+	EventRefunded = "payment.refunded"
+	EventFailed   = "payment.failed"
+)
+
+// Event is a change the gateway reports through a webhook
+type Event struct {
+	ID              string  `json:"id"`
+	Type            string  `json:"type"`
+	AuthorizationID string  `json:"authorization_id"`
+	Amount          float64 `json:"amount"`
+}
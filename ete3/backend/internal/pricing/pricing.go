@@ -0,0 +1,177 @@
+// Package pricing computes effective seat prices from a show's base prices
+// and configurable adjustment rules.
+package pricing
+
+import (
+	"ete3/internal/models"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Rule types
+const (
+	TimeOfDay = "time_of_day" // shows starting between From and To
+	Weekday   = "weekday"     // shows on Monday to Friday
+	Weekend   = "weekend"     // shows on Saturday or Sunday
+	Occupancy = "occupancy"   // shows with at least MinOccupancy of their seats taken
+	EarlyBird = "early_bird"  // bookings made at least DaysAhead days before the show
+	Matinee   = "matinee"     // shows starting before Before
+)
+
+// Rule changes the price of every seat of the shows it matches by Percent,
+// which is negative for discounts
+type Rule struct {
+	Name    string  `yaml:"name" toml:"name"`
+	Type    string  `yaml:"type" toml:"type"`
+	Percent float64 `yaml:"percent" toml:"percent"`
+
+	// From and To bound time_of_day rules as HH:MM local times; a range
+	// such as 22:00-02:00 wraps past midnight
+	From string `yaml:"from" toml:"from"`
+	To   string `yaml:"to" toml:"to"`
+	// Before is the HH:MM local time matinees start before, "17:00" if empty
+	Before string `yaml:"before" toml:"before"`
+	// MinOccupancy is the share of seats, from 0 to 1, that must be taken
+	// for an occupancy rule to apply
+	MinOccupancy float64 `yaml:"min_occupancy" toml:"min_occupancy"`
+	// DaysAhead is how many days before the show early bird bookings are made
+	DaysAhead int `yaml:"days_ahead" toml:"days_ahead"`
+}
+
+// Context is what rules look at when pricing the seats of one booking
+type Context struct {
+	Start time.Time // the show's start time
+	Now   time.Time // when the booking is made
+	// Occupancy is the share of the theater's seats held or booked before
+	// the booking
+	Occupancy float64
+}
+
+// Engine applies a set of rules. A nil Engine leaves prices unchanged.
+type Engine struct {
+	rules []rule
+	loc   *time.Location
+}
+
+// rule is a validated Rule with its times parsed into minutes after
+// midnight
+type rule struct {
+	Rule
+	from, to int
+}
+
+// New validates rules and returns an Engine applying them in loc, the time
+// zone show times are judged in; nil means time.Local
+func New(rules []Rule, loc *time.Location) (*Engine, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	e := &Engine{loc: loc}
+	for i, r := range rules {
+		parsed := rule{Rule: r}
+		if parsed.Name == "" {
+			parsed.Name = r.Type
+		}
+		if r.Percent < -100 {
+			return nil, fmt.Errorf("rule %d (%s): percent can't take more than the whole price", i+1, parsed.Name)
+		}
+
+		var err error
+		switch r.Type {
+		case TimeOfDay:
+			if parsed.from, err = minutes(r.From); err == nil {
+				parsed.to, err = minutes(r.To)
+			}
+		case Matinee:
+			before := r.Before
+			if before == "" {
+				before = "17:00"
+			}
+			parsed.to, err = minutes(before)
+		case Occupancy:
+			if r.MinOccupancy <= 0 || r.MinOccupancy > 1 {
+				err = fmt.Errorf("min_occupancy %v is not between 0 and 1", r.MinOccupancy)
+			}
+		case EarlyBird:
+			if r.DaysAhead < 1 {
+				err = fmt.Errorf("days_ahead must be at least 1")
+			}
+		case Weekday, Weekend:
+		default:
+			err = fmt.Errorf("type %q is not one of %s", r.Type,
+				strings.Join([]string{TimeOfDay, Weekday, Weekend, Occupancy, EarlyBird, Matinee}, ", "))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, parsed.Name, err)
+		}
+		e.rules = append(e.rules, parsed)
+	}
+	return e, nil
+}
+
+// Price applies every matching rule to base. Each rule adds its percentage
+// of base, so a 10% weekend surge and a 20% early bird discount give 90% of
+// base. The result is rounded to cents and never negative.
+func (e *Engine) Price(base float64, ctx Context) (float64, []models.PriceAdjustment) {
+	adjustments := []models.PriceAdjustment{}
+	if e == nil {
+		return base, adjustments
+	}
+
+	price := base
+	for _, r := range e.rules {
+		if !r.matches(ctx, e.loc) {
+			continue
+		}
+		amount := round(base * r.Percent / 100)
+		price += amount
+		adjustments = append(adjustments, models.PriceAdjustment{
+			Rule:    r.Name,
+			Type:    r.Type,
+			Percent: r.Percent,
+			Amount:  amount,
+		})
+	}
+	return math.Max(round(price), 0), adjustments
+}
+
+// matches reports whether the rule applies in ctx
+func (r *rule) matches(ctx Context, loc *time.Location) bool {
+	start := ctx.Start.In(loc)
+	minute := start.Hour()*60 + start.Minute()
+
+	switch r.Type {
+	case TimeOfDay:
+		if r.from <= r.to {
+			return minute >= r.from && minute < r.to
+		}
+		return minute >= r.from || minute < r.to
+	case Matinee:
+		return minute < r.to
+	case Weekday:
+		return start.Weekday() != time.Saturday && start.Weekday() != time.Sunday
+	case Weekend:
+		return start.Weekday() == time.Saturday || start.Weekday() == time.Sunday
+	case Occupancy:
+		return ctx.Occupancy >= r.MinOccupancy
+	case EarlyBird:
+		return ctx.Start.Sub(ctx.Now) >= time.Duration(r.DaysAhead)*24*time.Hour
+	}
+	return false
+}
+
+// minutes parses an HH:MM time into minutes after midnight
+func minutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not an HH:MM time", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// round rounds an amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,93 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrice(t *testing.T) {
+	engine, err := New([]Rule{
+		{Name: "Matinee", Type: Matinee, Percent: -20},
+		{Name: "Prime time", Type: TimeOfDay, From: "19:00", To: "22:00", Percent: 10},
+		{Name: "Late night", Type: TimeOfDay, From: "23:00", To: "01:00", Percent: -5},
+		{Name: "Weekend", Type: Weekend, Percent: 10},
+		{Name: "Weekday", Type: Weekday, Percent: -5},
+		{Name: "Surge", Type: Occupancy, MinOccupancy: 0.8, Percent: 25},
+		{Name: "Early bird", Type: EarlyBird, DaysAhead: 7, Percent: -15},
+	}, time.UTC)
+	require.NoError(t, err)
+
+	// 2030-11-02 is a Saturday
+	saturday := func(hour, minute int) time.Time { return time.Date(2030, 11, 2, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name      string
+		ctx       Context
+		wantPrice float64
+		wantRules []string
+	}{
+		{"Weekend Matinee", Context{Start: saturday(14, 0), Now: saturday(10, 0)}, 9, []string{"Matinee", "Weekend"}},
+		{"Weekend Prime Time", Context{Start: saturday(19, 0), Now: saturday(10, 0)}, 12, []string{"Prime time", "Weekend"}},
+		{"Prime Time Ends", Context{Start: saturday(22, 0), Now: saturday(10, 0)}, 11, []string{"Weekend"}},
+		{"Past Midnight", Context{Start: saturday(0, 30), Now: saturday(0, 0)}, 8.5, []string{"Matinee", "Late night", "Weekend"}},
+		{"Weekday Surge", Context{Start: saturday(19, 0).AddDate(0, 0, 2), Now: saturday(10, 0).AddDate(0, 0, 2), Occupancy: 0.8}, 13, []string{"Prime time", "Weekday", "Surge"}},
+		{"Early Bird", Context{Start: saturday(18, 0), Now: saturday(18, 0).AddDate(0, 0, -7)}, 9.5, []string{"Weekend", "Early bird"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, adjustments := engine.Price(10, tt.ctx)
+			assert.Equal(t, tt.wantPrice, price)
+			var rules []string
+			for _, a := range adjustments {
+				rules = append(rules, a.Rule)
+			}
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+}
+
+func TestPriceNeverNegative(t *testing.T) {
+	engine, err := New([]Rule{
+		{Type: Weekend, Percent: -100},
+		{Type: Matinee, Percent: -50},
+	}, time.UTC)
+	require.NoError(t, err)
+
+	price, adjustments := engine.Price(10, Context{Start: time.Date(2030, 11, 2, 14, 0, 0, 0, time.UTC)})
+	assert.Equal(t, 0.0, price)
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, "weekend", adjustments[0].Rule, "unnamed rules are named after their type")
+	assert.Equal(t, -10.0, adjustments[0].Amount)
+}
+
+func TestNilEngine(t *testing.T) {
+	var engine *Engine
+	price, adjustments := engine.Price(12.5, Context{})
+	assert.Equal(t, 12.5, price)
+	assert.Empty(t, adjustments)
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"Unknown Type", Rule{Type: "full_moon"}},
+		{"Bad Time", Rule{Type: TimeOfDay, From: "7pm", To: "22:00"}},
+		{"Missing To", Rule{Type: TimeOfDay, From: "19:00"}},
+		{"Bad Matinee Time", Rule{Type: Matinee, Before: "noon"}},
+		{"Occupancy Above One", Rule{Type: Occupancy, MinOccupancy: 80}},
+		{"No Days Ahead", Rule{Type: EarlyBird}},
+		{"Below Free", Rule{Type: Weekend, Percent: -120}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]Rule{tt.rule}, time.UTC)
+			assert.Error(t, err)
+		})
+	}
+}
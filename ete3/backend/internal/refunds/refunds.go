@@ -0,0 +1,98 @@
+// Package refunds decides how much of a cancelled booking's payment is
+// handed back, depending on how long before the show it is cancelled.
+package refunds
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Tier refunds Percent of what was paid for cancellations made at least
+// Before the show starts
+type Tier struct {
+	Name string `yaml:"name" toml:"name"`
+	// Before is a duration such as "24h"; "0s" covers every cancellation
+	// up to the start of the show
+	Before  string  `yaml:"before" toml:"before"`
+	Percent float64 `yaml:"percent" toml:"percent"`
+}
+
+// DefaultTiers refund everything up to a day before the show, half after
+// that and nothing once it has started
+func DefaultTiers() []Tier {
+	return []Tier{
+		{Name: "full", Before: "24h", Percent: 100},
+		{Name: "late", Before: "0s", Percent: 50},
+	}
+}
+
+// Policy applies a set of tiers. A nil Policy refunds everything.
+type Policy struct {
+	tiers []tier
+}
+
+// tier is a validated Tier with Before parsed
+type tier struct {
+	Tier
+	before time.Duration
+}
+
+// New validates tiers and returns a Policy applying them. Cancellations no
+// tier covers get nothing back.
+func New(tiers []Tier) (*Policy, error) {
+	p := &Policy{}
+	for i, t := range tiers {
+		parsed := tier{Tier: t}
+		if parsed.Name == "" {
+			parsed.Name = fmt.Sprintf("tier %d", i+1)
+		}
+		before, err := time.ParseDuration(t.Before)
+		if err != nil {
+			return nil, fmt.Errorf("tier %d (%s): before %q is not a duration", i+1, parsed.Name, t.Before)
+		}
+		if t.Percent < 0 || t.Percent > 100 {
+			return nil, fmt.Errorf("tier %d (%s): percent %v is not between 0 and 100", i+1, parsed.Name, t.Percent)
+		}
+		parsed.before = before
+		p.tiers = append(p.tiers, parsed)
+	}
+	// The tier with the longest notice a cancellation gives wins
+	sort.SliceStable(p.tiers, func(i, j int) bool { return p.tiers[i].before > p.tiers[j].before })
+	return p, nil
+}
+
+// Refund returns the share of paid handed back for cancelling at now a
+// show starting at start, rounded to cents, with its percentage and the
+// name of the tier that applied; the name is empty if none did.
+func (p *Policy) Refund(paid float64, start, now time.Time) (amount, percent float64, name string) {
+	if p == nil {
+		return paid, 100, "full"
+	}
+	notice := start.Sub(now)
+	for _, t := range p.tiers {
+		if notice >= t.before {
+			return Amount(paid, t.Percent), t.Percent, t.Name
+		}
+	}
+	return 0, 0, ""
+}
+
+// Amount returns percent of paid rounded to cents
+func Amount(paid, percent float64) float64 {
+	return math.Round(paid*percent) / 100
+}
+
+// Share returns the part of paid, a payment for seats priced total in all,
+// that covers seats priced cancelling when seats priced before have
+// already been cancelled. Shares are rounded so that those of every seat
+// add up to paid, and never exceed what is left after refunded.
+func Share(paid, refunded, total, before, cancelling float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	round := func(amount float64) float64 { return math.Round(amount*100) / 100 }
+	share := round(paid*(before+cancelling)/total) - round(paid*before/total)
+	return round(math.Min(share, paid-refunded))
+}
@@ -0,0 +1,91 @@
+package refunds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefund(t *testing.T) {
+	policy, err := New(DefaultTiers())
+	require.NoError(t, err)
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		now     time.Time
+		amount  float64
+		percent float64
+		tier    string
+	}{
+		{"two days ahead", start.Add(-48 * time.Hour), 25.5, 100, "full"},
+		{"exactly a day ahead", start.Add(-24 * time.Hour), 25.5, 100, "full"},
+		{"an hour ahead", start.Add(-time.Hour), 12.75, 50, "late"},
+		{"at the start", start, 12.75, 50, "late"},
+		{"after the start", start.Add(time.Minute), 0, 0, ""},
+	} {
+		amount, percent, tier := policy.Refund(25.5, start, tc.now)
+		assert.Equal(t, tc.amount, amount, tc.name)
+		assert.Equal(t, tc.percent, percent, tc.name)
+		assert.Equal(t, tc.tier, tier, tc.name)
+	}
+}
+
+func TestRefundTierOrder(t *testing.T) {
+	// Tiers may be listed in any order
+	policy, err := New([]Tier{
+		{Before: "2h", Percent: 25},
+		{Name: "week", Before: "168h", Percent: 90},
+		{Before: "-30m", Percent: 10},
+	})
+	require.NoError(t, err)
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	_, percent, name := policy.Refund(10, start, start.Add(-200*time.Hour))
+	assert.Equal(t, 90.0, percent)
+	assert.Equal(t, "week", name)
+	_, percent, name = policy.Refund(10, start, start.Add(-3*time.Hour))
+	assert.Equal(t, 25.0, percent)
+	assert.Equal(t, "tier 1", name)
+	amount, _, _ := policy.Refund(10, start, start.Add(10*time.Minute))
+	assert.Equal(t, 1.0, amount)
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+	start := time.Now()
+	amount, percent, _ := policy.Refund(30, start, start.Add(time.Hour))
+	assert.Equal(t, 30.0, amount)
+	assert.Equal(t, 100.0, percent)
+}
+
+func TestShare(t *testing.T) {
+	// 25.00 paid for three 10.00 seats, cancelled one at a time
+	assert.Equal(t, 8.33, Share(25, 0, 30, 0, 10))
+	assert.Equal(t, 8.34, Share(25, 8.33, 30, 10, 10))
+	assert.Equal(t, 8.33, Share(25, 16.67, 30, 20, 10))
+
+	// All at once, never more than is left, and nothing for free seats
+	assert.Equal(t, 25.0, Share(25, 0, 30, 0, 30))
+	assert.Equal(t, 5.0, Share(25, 20, 30, 0, 30))
+	assert.Zero(t, Share(0, 0, 0, 0, 0))
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tiers := range [][]Tier{
+		{{Before: "a day", Percent: 100}},
+		{{Before: "24h", Percent: 120}},
+		{{Before: "24h", Percent: -5}},
+	} {
+		_, err := New(tiers)
+		assert.Error(t, err, "%+v", tiers)
+	}
+
+	policy, err := New(nil)
+	require.NoError(t, err)
+	amount, _, name := policy.Refund(10, time.Now().Add(48*time.Hour), time.Now())
+	assert.Zero(t, amount)
+	assert.Empty(t, name)
+}
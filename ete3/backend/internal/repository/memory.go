@@ -0,0 +1,1598 @@
+package repository
+
+import (
+	"database/sql"
+	"ete3/internal/database"
+	"ete3/internal/live"
+	"ete3/internal/models"
+	"ete3/internal/pricing"
+	"ete3/internal/refunds"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Memory is an in-memory implementation of every repository for tests. It
+// returns the same domain errors as database.Store. AddTheater and AddShow
+// seed theaters and shows without the checks the repository methods make.
+type Memory struct {
+	mu       sync.Mutex
+	movies   []*models.Movie
+	theaters []*models.Theater
+	seats    []*models.Seat
+	shows    []*models.Show
+	orders   []*models.Order
+	promos   []*models.PromoCode
+	payments []*models.Payment
+	refunds  []*models.Refund
+	users    []*models.User
+	tokens   []*memoryToken
+	pricing  *pricing.Engine
+	policy   *refunds.Policy
+	events   *live.Hub
+
+	nextBookingID int64
+	nextPaymentID int64
+	nextRefundID  int64
+	nextSeatID    int64
+	nextShowID    int64
+}
+
+// memoryToken is a stored refresh token
+type memoryToken struct {
+	userID    int64
+	familyID  string
+	hash      string
+	expiresAt time.Time
+	revoked   bool
+}
+
+// NewMemory returns an empty Memory
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Repositories returns m as every repository
+func (m *Memory) Repositories() Repositories {
+	return Repositories{
+		Movies:   m,
+		Theaters: m,
+		Shows:    m,
+		Seats:    m,
+		Bookings: m,
+		Promos:   m,
+		Payments: m,
+		Users:    m,
+	}
+}
+
+// SetPricing makes bookings and quotes apply engine's rules, like
+// database.Store.SetPricing
+func (m *Memory) SetPricing(engine *pricing.Engine) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pricing = engine
+}
+
+// SetRefundPolicy makes cancellations refund what policy allows, like
+// database.Store.SetRefundPolicy
+func (m *Memory) SetRefundPolicy(policy *refunds.Policy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = policy
+}
+
+// SetSeatEvents makes bookings publish their seats' state changes to hub,
+// like database.Store.SetSeatEvents. There is no reaper, so expired holds
+// are only published once a booking for their show expires them.
+func (m *Memory) SetSeatEvents(hub *live.Hub) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = hub
+}
+
+// AddTheater adds a theater with rows x seatsPerRow seats
+func (m *Memory) AddTheater(name string, rows, seatsPerRow int) *models.Theater {
+	theater := &models.Theater{Name: name}
+	if err := m.CreateTheater(theater, &models.SeatMap{Rows: rows, SeatsPerRow: seatsPerRow}); err != nil {
+		panic(err)
+	}
+	return theater
+}
+
+// AddShow schedules movieID in theaterID at start. The show ends after the
+// movie's duration.
+func (m *Memory) AddShow(movieID, theaterID int64, start time.Time, price float64) *models.Show {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	end := start
+	if movie := m.movie(movieID); movie != nil {
+		end = start.Add(time.Duration(movie.Duration) * time.Minute)
+	}
+
+	now := time.Now()
+	m.nextShowID++
+	show := &models.Show{
+		ID:        m.nextShowID,
+		MovieID:   movieID,
+		TheaterID: theaterID,
+		StartTime: start,
+		EndTime:   end,
+		Price:     price,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.shows = append(m.shows, show)
+
+	copied := *show
+	return &copied
+}
+
+// Movie operations
+
+// GetMovies matches search words anywhere in the title or description,
+// like database.Store without FTS5, so sorting by relevance sorts by title
+func (m *Memory) GetMovies(query *models.MovieQuery) (*models.MoviePage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return nil, database.ErrInvalidCursor
+	}
+	dayStart, dayEnd, err := query.ShowDay()
+	if err != nil {
+		return nil, database.NewError(database.KindInvalid, "invalid_request", err.Error())
+	}
+	terms := query.SearchTerms()
+
+	var movies []models.Movie
+	for _, movie := range m.movies {
+		text := strings.ToLower(movie.Title + "\n" + movie.Description)
+		match := true
+		for _, term := range terms {
+			match = match && strings.Contains(text, term)
+		}
+		switch {
+		case !match,
+			query.Genre != "" && !strings.EqualFold(movie.Genre, query.Genre),
+			query.MinDuration > 0 && movie.Duration < query.MinDuration,
+			query.MaxDuration > 0 && movie.Duration > query.MaxDuration,
+			!dayStart.IsZero() && !m.hasShowBetween(movie.ID, dayStart, dayEnd):
+			continue
+		}
+		movies = append(movies, *movie)
+	}
+
+	order := query.SortOrder()
+	key := func(movie *models.Movie) models.MovieCursor {
+		c := models.MovieCursor{Sort: order, ID: movie.ID}
+		switch order {
+		case models.SortRelevance, models.SortTitle, models.SortTitleDesc:
+			c.Title = strings.ToLower(movie.Title)
+		case models.SortDuration, models.SortDurationDesc:
+			c.Duration = movie.Duration
+		}
+		return c
+	}
+	desc := order == models.SortTitleDesc || order == models.SortDurationDesc || order == models.SortNewest
+	before := func(a, b models.MovieCursor) bool {
+		if a.Title != b.Title {
+			return a.Title < b.Title != desc
+		}
+		if a.Duration != b.Duration {
+			return a.Duration < b.Duration != desc
+		}
+		return a.ID < b.ID != desc
+	}
+	sort.Slice(movies, func(i, j int) bool {
+		return before(key(&movies[i]), key(&movies[j]))
+	})
+
+	page := &models.MoviePage{Movies: []models.Movie{}}
+	for i := range movies {
+		if cursor != nil && !before(*cursor, key(&movies[i])) {
+			continue
+		}
+		if len(page.Movies) == query.PageSize() {
+			last := key(&page.Movies[len(page.Movies)-1])
+			page.NextCursor = last.Encode()
+			break
+		}
+		page.Movies = append(page.Movies, movies[i])
+	}
+	return page, nil
+}
+
+// hasShowBetween reports whether movieID has a show starting in [from, until)
+func (m *Memory) hasShowBetween(movieID int64, from, until time.Time) bool {
+	for _, show := range m.shows {
+		if show.MovieID == movieID && !show.StartTime.Before(from) && show.StartTime.Before(until) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) GetMovieByID(movieID int64) (*models.Movie, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	movie := m.movie(movieID)
+	if movie == nil {
+		return nil, database.ErrMovieNotFound
+	}
+	copied := *movie
+	return &copied, nil
+}
+
+func (m *Memory) CreateMovie(movie *models.Movie, shows []models.Show) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	movie.ID = int64(len(m.movies) + 1)
+	movie.CreatedAt = now
+	movie.UpdatedAt = now
+
+	copied := *movie
+	movies := m.movies
+	m.movies = append(m.movies, &copied)
+
+	for i := range shows {
+		shows[i].MovieID = movie.ID
+	}
+	if err := m.createShows(shows); err != nil {
+		// Roll back like the database transaction would
+		m.movies = movies
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) UpdateMovie(movie *models.Movie) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.movie(movie.ID)
+	if stored == nil {
+		return database.ErrMovieNotFound
+	}
+	stored.Title = movie.Title
+	stored.Description = movie.Description
+	stored.Duration = movie.Duration
+	stored.Genre = movie.Genre
+	stored.PosterURL = movie.PosterURL
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+// Theater operations
+
+func (m *Memory) GetTheaters() ([]models.Theater, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var theaters []models.Theater
+	for _, theater := range m.theaters {
+		theaters = append(theaters, *theater)
+	}
+	sort.SliceStable(theaters, func(i, j int) bool {
+		return theaters[i].Name < theaters[j].Name
+	})
+	return theaters, nil
+}
+
+func (m *Memory) GetTheaterByID(theaterID int64) (*models.Theater, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	theater := m.theater(theaterID)
+	if theater == nil {
+		return nil, database.ErrTheaterNotFound
+	}
+	copied := *theater
+	return &copied, nil
+}
+
+func (m *Memory) GetAllSeatsForTheater(theaterID int64) ([]models.Seat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var seats []models.Seat
+	for _, seat := range m.seats {
+		if seat.TheaterID == theaterID {
+			seats = append(seats, *seat)
+		}
+	}
+	return seats, nil
+}
+
+func (m *Memory) CreateTheater(theater *models.Theater, seatMap *models.SeatMap) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var positions []models.SeatPosition
+	var codes map[models.SeatPosition]string
+	categories := []models.SeatCategory{models.StandardCategory}
+	if seatMap != nil {
+		var err error
+		if positions, categories, codes, err = seatLayout(seatMap); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	theater.ID = 1
+	if len(m.theaters) > 0 {
+		theater.ID = m.theaters[len(m.theaters)-1].ID + 1
+	}
+	theater.CreatedAt = now
+	theater.UpdatedAt = now
+
+	copied := *theater
+	m.theaters = append(m.theaters, &copied)
+	m.addSeats(&copied, positions, categories, codes)
+	theater.Capacity = copied.Capacity
+	theater.Categories = copied.Categories
+	return nil
+}
+
+func (m *Memory) UpdateTheater(theater *models.Theater) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.theater(theater.ID)
+	if stored == nil {
+		return database.ErrTheaterNotFound
+	}
+	stored.Name = theater.Name
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *Memory) DeleteTheater(theaterID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkTheaterUnused(theaterID); err != nil {
+		return err
+	}
+
+	m.removeSeats(theaterID)
+	for i, theater := range m.theaters {
+		if theater.ID == theaterID {
+			m.theaters = append(m.theaters[:i], m.theaters[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Memory) SetSeatMap(theaterID int64, seatMap *models.SeatMap) (*models.Theater, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkTheaterUnused(theaterID); err != nil {
+		return nil, err
+	}
+	positions, categories, codes, err := seatLayout(seatMap)
+	if err != nil {
+		return nil, err
+	}
+
+	theater := m.theater(theaterID)
+	m.removeSeats(theaterID)
+	m.addSeats(theater, positions, categories, codes)
+
+	copied := *theater
+	return &copied, nil
+}
+
+// Show operations
+
+func (m *Memory) GetShowsByMovie(movieID int64) ([]models.Show, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var shows []models.Show
+	for _, show := range m.shows {
+		if show.MovieID == movieID && show.StartTime.After(now) {
+			shows = append(shows, *show)
+		}
+	}
+	sort.Slice(shows, func(i, j int) bool {
+		return shows[i].StartTime.Before(shows[j].StartTime)
+	})
+	return shows, nil
+}
+
+func (m *Memory) GetShows(query *models.ShowQuery) ([]models.ShowListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, until, err := query.Window(time.Now())
+	if err != nil {
+		return nil, database.NewError(database.KindInvalid, "invalid_request", err.Error())
+	}
+
+	listings := []models.ShowListing{}
+	for _, show := range m.shows {
+		switch {
+		case show.StartTime.Before(from), !show.StartTime.Before(until),
+			query.TheaterID != 0 && show.TheaterID != query.TheaterID,
+			query.MovieID != 0 && show.MovieID != query.MovieID:
+			continue
+		}
+		listing := models.ShowListing{Show: *show}
+		if movie := m.movie(show.MovieID); movie != nil {
+			listing.Movie = *movie
+		}
+		if theater := m.theater(show.TheaterID); theater != nil {
+			listing.Theater = models.TheaterSummary{ID: theater.ID, Name: theater.Name, Capacity: theater.Capacity}
+		}
+		for _, seat := range m.seats {
+			if seat.TheaterID == show.TheaterID {
+				listing.SeatsLeft++
+			}
+		}
+		listing.SeatsLeft -= len(m.occupiedSeats(show.ID))
+		listings = append(listings, listing)
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		a, b := listings[i], listings[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		if a.Theater.Name != b.Theater.Name {
+			return a.Theater.Name < b.Theater.Name
+		}
+		return a.ID < b.ID
+	})
+	return listings, nil
+}
+
+func (m *Memory) GetShowByID(showID int64) (*models.Show, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	show := m.show(showID)
+	if show == nil {
+		return nil, database.ErrShowNotFound
+	}
+	copied := *show
+	return &copied, nil
+}
+
+func (m *Memory) CreateShow(show *models.Show) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	show.ID = 0
+	return m.saveShow(show)
+}
+
+func (m *Memory) UpdateShow(show *models.Show) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.show(show.ID)
+	if stored == nil {
+		return database.ErrShowNotFound
+	}
+	if !stored.StartTime.After(time.Now()) {
+		return database.ErrShowStarted
+	}
+	moved := show.MovieID != stored.MovieID || show.TheaterID != stored.TheaterID ||
+		!show.StartTime.Equal(stored.StartTime)
+	if moved && len(m.occupiedSeats(show.ID)) > 0 {
+		return database.ErrShowHasBookings
+	}
+	return m.saveShow(show)
+}
+
+func (m *Memory) DeleteShow(showID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.show(showID)
+	if stored == nil {
+		return database.ErrShowNotFound
+	}
+	if !stored.StartTime.After(time.Now()) {
+		return database.ErrShowStarted
+	}
+	if len(m.occupiedSeats(showID)) > 0 {
+		return database.ErrShowHasBookings
+	}
+	for _, payment := range m.payments {
+		if order := m.order(payment.OrderID); order != nil && order.ShowID == showID {
+			return database.ErrShowHasPayments
+		}
+	}
+	for _, refund := range m.refunds {
+		if order := m.order(refund.OrderID); order != nil && order.ShowID == showID {
+			return database.ErrShowHasPayments
+		}
+	}
+
+	orders := m.orders[:0]
+	for _, order := range m.orders {
+		if order.ShowID != showID {
+			orders = append(orders, order)
+		}
+	}
+	m.orders = orders
+
+	for i, show := range m.shows {
+		if show.ID == showID {
+			m.shows = append(m.shows[:i], m.shows[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Memory) PreviewShows(shows []models.Show) ([]models.ScheduledShow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved, nextShowID := m.shows, m.nextShowID
+	defer func() { m.shows, m.nextShowID = saved, nextShowID }()
+
+	scheduled, _, err := m.scheduleShows(shows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scheduled {
+		scheduled[i].ID = 0
+	}
+	return scheduled, nil
+}
+
+func (m *Memory) CreateShows(shows []models.Show) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createShows(shows)
+}
+
+// createShows stores all of shows or, if any conflict, none of them
+func (m *Memory) createShows(shows []models.Show) error {
+	saved, nextShowID := m.shows, m.nextShowID
+	scheduled, conflicts, err := m.scheduleShows(shows)
+	if err != nil || conflicts > 0 {
+		m.shows, m.nextShowID = saved, nextShowID
+	}
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return database.ErrScheduleConflict.WithDetails(database.ConflictingShows(scheduled))
+	}
+
+	for i := range shows {
+		shows[i] = scheduled[i].Show
+	}
+	return nil
+}
+
+// Seat operations
+
+func (m *Memory) GetAvailableSeats(showID int64) ([]models.Seat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	show := m.show(showID)
+	if show == nil {
+		return nil, nil
+	}
+
+	occupied := m.occupiedSeats(showID)
+	var seats []models.Seat
+	for _, seat := range m.seats {
+		if seat.TheaterID == show.TheaterID && occupied[seat.ID] == "" {
+			seats = append(seats, *seat)
+		}
+	}
+	return seats, nil
+}
+
+func (m *Memory) GetTheaterLayout(showID int64) (*models.TheaterLayout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	show := m.show(showID)
+	if show == nil {
+		return nil, database.ErrShowNotFound
+	}
+
+	theater := m.theater(show.TheaterID)
+	if theater == nil {
+		return nil, database.ErrTheaterNotFound
+	}
+
+	var seats []models.Seat
+	for _, seat := range m.seats {
+		if seat.TheaterID == theater.ID {
+			seats = append(seats, *seat)
+		}
+	}
+
+	layout := models.NewTheaterLayout(theater, seats, m.occupiedSeats(showID))
+	layout.SetPrices(show)
+	return layout, nil
+}
+
+// Booking operations
+
+func (m *Memory) QuoteSeats(showID int64, seatIDs []int64) (*models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	show, err := m.validateBooking(showID, seatIDs)
+	if err != nil {
+		return nil, err
+	}
+	return m.quoteSeats(show, seatIDs), nil
+}
+
+func (m *Memory) CreateHold(userID, showID int64, seatIDs []int64, promoCode string, ttl time.Duration) (*models.BookingResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, err := m.createOrder(userID, showID, seatIDs, promoCode, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BookingResponse{
+		BookingID: order.ID,
+		Status:    "pending",
+		Message:   "Seats held successfully",
+		ExpiresAt: order.ExpiresAt,
+		Seats:     append([]models.Booking(nil), order.Items...),
+		PromoCode: order.PromoCode,
+		Discount:  order.Discount,
+		Total:     order.Total(),
+	}, nil
+}
+
+func (m *Memory) ConfirmHold(holdID, userID int64) (*models.BookingResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order := m.order(holdID)
+	if order == nil {
+		return nil, database.ErrHoldNotFound
+	}
+	if order.UserID != userID {
+		return nil, database.ErrBookingNotOwned
+	}
+	if order.Status == "expired" || (order.Status == "pending" && holdExpired(order)) {
+		return nil, database.ErrHoldExpired
+	}
+	if order.Status != "pending" {
+		return nil, database.ErrHoldNotFound
+	}
+
+	var payment *models.Payment
+	paid := 0.0
+	for _, p := range m.payments {
+		if p.OrderID == holdID && p.Status == models.PaymentCaptured {
+			paid += p.Amount - p.Refunded
+			copied := *p
+			payment = &copied
+		}
+	}
+	if math.Round(paid*100) < math.Round(order.Total()*100) {
+		return nil, database.ErrPaymentRequired
+	}
+
+	m.setStatus(order, "pending", "confirmed")
+	order.ExpiresAt = nil
+	for i := range order.Items {
+		order.Items[i].ExpiresAt = nil
+	}
+
+	return &models.BookingResponse{
+		BookingID: holdID,
+		Status:    "success",
+		Message:   "Booking confirmed successfully",
+		Seats:     append([]models.Booking(nil), order.Items...),
+		PromoCode: order.PromoCode,
+		Discount:  order.Discount,
+		Total:     order.Total(),
+		Payment:   payment,
+	}, nil
+}
+
+func (m *Memory) ReleaseHold(holdID, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order := m.order(holdID)
+	if order == nil {
+		return database.ErrHoldNotFound
+	}
+	if order.UserID != userID {
+		return database.ErrBookingNotOwned
+	}
+	if order.Status == "expired" || (order.Status == "pending" && holdExpired(order)) {
+		return database.ErrHoldExpired
+	}
+	if order.Status != "pending" {
+		return database.ErrHoldNotFound
+	}
+
+	m.setStatus(order, "pending", "cancelled")
+	return nil
+}
+
+func (m *Memory) GetOrder(orderID, userID int64) (*models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order := m.order(orderID)
+	if order == nil {
+		return nil, database.ErrBookingNotFound
+	}
+	if order.UserID != userID {
+		return nil, database.ErrBookingNotOwned
+	}
+	copied := *order
+	copied.Items = append([]models.Booking(nil), order.Items...)
+	return &copied, nil
+}
+
+func (m *Memory) GetBookings(userID int64) ([]models.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Newest first, like database.Store
+	var bookings []models.Booking
+	for i := len(m.orders) - 1; i >= 0; i-- {
+		for _, b := range m.orders[i].Items {
+			if b.UserID == userID {
+				bookings = append(bookings, b)
+			}
+		}
+	}
+	return bookings, nil
+}
+
+func (m *Memory) CancelBooking(orderID, userID int64, override *models.RefundOverride) (*models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order := m.order(orderID); order != nil && override != nil {
+		userID = order.UserID
+	}
+	order, err := m.ownedOrder(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	refund := m.recordRefund(order, 0, override)
+	m.setStatus(order, "confirmed", "cancelled")
+	return refund, nil
+}
+
+func (m *Memory) CancelBookingSeat(orderID, seatID, userID int64, override *models.RefundOverride) (*models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order := m.order(orderID); order != nil && override != nil {
+		userID = order.UserID
+	}
+	order, err := m.ownedOrder(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *models.Booking
+	remaining := 0
+	for i := range order.Items {
+		switch {
+		case order.Items[i].Status != "confirmed":
+		case order.Items[i].SeatID == seatID:
+			item = &order.Items[i]
+		default:
+			remaining++
+		}
+	}
+	if item == nil {
+		return nil, database.ErrSeatNotInBooking
+	}
+
+	refund := m.recordRefund(order, seatID, override)
+	item.Status = "cancelled"
+	item.UpdatedAt = time.Now()
+	if remaining == 0 {
+		order.Status = "cancelled"
+	}
+	m.events.Publish(models.SeatEvent{ShowID: order.ShowID, SeatIDs: []int64{seatID}, State: models.SeatReleased})
+	return refund, nil
+}
+
+// recordRefund records the refund due for cancelling seatID of order, or
+// all of its confirmed seats if seatID is 0, like database.Store. It must
+// run before the seats are cancelled.
+func (m *Memory) recordRefund(order *models.Order, seatID int64, override *models.RefundOverride) *models.Refund {
+	refund := &models.Refund{OrderID: order.ID}
+	var paid float64
+	if payment := m.capturedPayment(order.ID); payment != nil {
+		paymentID := payment.ID
+		refund.PaymentID = &paymentID
+
+		var total, before, cancelling float64
+		for _, b := range order.Items {
+			total += b.Price
+			switch {
+			case b.Status == "cancelled":
+				before += b.Price
+			case b.Status == "confirmed" && (seatID == 0 || b.SeatID == seatID):
+				cancelling += b.Price
+			}
+		}
+		paid = refunds.Share(payment.Amount, payment.Refunded, total, before, cancelling)
+	}
+	if override != nil {
+		issuedBy := override.IssuedBy
+		refund.Amount = refunds.Amount(paid, override.Percent)
+		refund.Percent = override.Percent
+		refund.Tier = models.OverrideTier
+		refund.Reason = override.Reason
+		refund.IssuedBy = &issuedBy
+	} else {
+		refund.Amount, refund.Percent, refund.Tier = m.policy.Refund(paid, m.show(order.ShowID).StartTime, time.Now())
+	}
+	refund.Status = models.RefundCompleted
+	if refund.Amount > 0 {
+		refund.Status = models.RefundPending
+	}
+
+	m.nextRefundID++
+	refund.ID = m.nextRefundID
+	refund.CreatedAt = time.Now()
+	refund.UpdatedAt = refund.CreatedAt
+	m.refunds = append(m.refunds, refund)
+	copied := *refund
+	return &copied
+}
+
+// Promo code operations
+
+func (m *Memory) CreatePromoCode(promo *models.PromoCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if promo.MovieID != nil && m.movie(*promo.MovieID) == nil {
+		return database.ErrMovieNotFound
+	}
+	if promo.ShowID != nil && m.show(*promo.ShowID) == nil {
+		return database.ErrShowNotFound
+	}
+	if m.promoByCode(promo.Code) != nil {
+		return database.ErrPromoCodeExists
+	}
+
+	promo.ID = int64(len(m.promos) + 1)
+	promo.CreatedAt = time.Now()
+	copied := *promo
+	m.promos = append(m.promos, &copied)
+	return nil
+}
+
+func (m *Memory) GetPromoCodes() ([]models.PromoCodeUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	promos := append([]*models.PromoCode(nil), m.promos...)
+	sort.Slice(promos, func(i, j int) bool { return promos[i].Code < promos[j].Code })
+
+	usages := []models.PromoCodeUsage{}
+	for _, promo := range promos {
+		usage := m.promoUsage(promo)
+		usage.Orders = nil
+		usages = append(usages, *usage)
+	}
+	return usages, nil
+}
+
+func (m *Memory) GetPromoCodeUsage(promoID int64) (*models.PromoCodeUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, promo := range m.promos {
+		if promo.ID == promoID {
+			return m.promoUsage(promo), nil
+		}
+	}
+	return nil, database.ErrPromoCodeNotFound
+}
+
+// Payment operations
+
+func (m *Memory) CreatePayment(payment *models.Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.nextPaymentID++
+	payment.ID = m.nextPaymentID
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+	copied := *payment
+	m.payments = append(m.payments, &copied)
+	return nil
+}
+
+func (m *Memory) UpdatePayment(payment *models.Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.payments {
+		if stored.ID == payment.ID {
+			stored.ProviderRef = payment.ProviderRef
+			stored.Amount = payment.Amount
+			stored.Refunded = payment.Refunded
+			stored.Status = payment.Status
+			stored.Error = payment.Error
+			stored.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return database.ErrPaymentNotFound
+}
+
+func (m *Memory) GetPayment(paymentID int64) (*models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, payment := range m.payments {
+		if payment.ID == paymentID {
+			copied := *payment
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrPaymentNotFound
+}
+
+func (m *Memory) GetPaymentByRef(provider, ref string) (*models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, payment := range m.payments {
+		if payment.Provider == provider && payment.ProviderRef == ref {
+			copied := *payment
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrPaymentNotFound
+}
+
+func (m *Memory) UpdateRefund(refund *models.Refund) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.refunds {
+		if stored.ID == refund.ID {
+			stored.Status = refund.Status
+			stored.Error = refund.Error
+			stored.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return database.ErrRefundNotFound
+}
+
+// User operations
+
+func (m *Memory) CreateUser(req *models.RegisterRequest, role string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Username == req.Username || user.Email == req.Email {
+			return database.ErrUserExists
+		}
+	}
+	m.users = append(m.users, &models.User{
+		ID:       int64(len(m.users) + 1),
+		Username: req.Username,
+		Email:    req.Email,
+		Password: string(hashedPassword),
+		Role:     role,
+	})
+	return nil
+}
+
+func (m *Memory) GetUserByUsername(username string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByID(userID int64) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.ID == userID {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *Memory) SetUserRole(userID int64, role string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.ID == userID {
+			user.Role = role
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func (m *Memory) CreateRefreshToken(userID int64, familyID, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens = append(m.tokens, &memoryToken{
+		userID:    userID,
+		familyID:  familyID,
+		hash:      tokenHash,
+		expiresAt: expiresAt,
+	})
+	return nil
+}
+
+func (m *Memory) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token := m.token(oldHash)
+	if token == nil {
+		return 0, database.ErrRefreshTokenInvalid
+	}
+	if token.revoked {
+		m.revokeFamily(token.familyID)
+		return 0, database.ErrRefreshTokenReused
+	}
+	if time.Now().After(token.expiresAt) {
+		return 0, database.ErrRefreshTokenInvalid
+	}
+
+	token.revoked = true
+	m.tokens = append(m.tokens, &memoryToken{
+		userID:    token.userID,
+		familyID:  token.familyID,
+		hash:      newHash,
+		expiresAt: expiresAt,
+	})
+	return token.userID, nil
+}
+
+func (m *Memory) RevokeRefreshToken(tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token := m.token(tokenHash)
+	if token == nil {
+		return database.ErrRefreshTokenInvalid
+	}
+	m.revokeFamily(token.familyID)
+	return nil
+}
+
+// Lookups and helpers below expect m.mu to be held
+
+func (m *Memory) movie(movieID int64) *models.Movie {
+	for _, movie := range m.movies {
+		if movie.ID == movieID {
+			return movie
+		}
+	}
+	return nil
+}
+
+// scheduleShows saves shows one by one like database.Store does, recording
+// validation failures as conflicts. Callers restore m.shows to roll back.
+func (m *Memory) scheduleShows(shows []models.Show) ([]models.ScheduledShow, int, error) {
+	// Stored shows are replaced rather than modified, so the caller's copy
+	// of the slice header is enough to roll back
+	m.shows = append([]*models.Show(nil), m.shows...)
+
+	scheduled := make([]models.ScheduledShow, len(shows))
+	indexByID := make(map[int64]int)
+	conflicts := 0
+	for i, show := range shows {
+		show.ID = 0
+		err := m.saveShow(&show)
+		scheduled[i].Show = show
+		if conflict := database.ScheduleConflict(err, indexByID); conflict != nil {
+			scheduled[i].ID = 0
+			scheduled[i].Conflict = conflict
+			conflicts++
+		} else if err != nil {
+			return nil, 0, err
+		} else {
+			indexByID[show.ID] = i
+		}
+	}
+	return scheduled, conflicts, nil
+}
+
+// saveShow validates show the way database.Store does and stores it as a
+// new show, or over the stored one if it has an ID
+func (m *Memory) saveShow(show *models.Show) error {
+	if !show.StartTime.After(time.Now()) {
+		return database.ErrShowInPast
+	}
+	movie := m.movie(show.MovieID)
+	if movie == nil {
+		return database.ErrMovieNotFound
+	}
+	theater := m.theater(show.TheaterID)
+	if theater == nil {
+		return database.ErrTheaterNotFound
+	}
+	show.EndTime = show.StartTime.Add(time.Duration(movie.Duration) * time.Minute)
+
+	categories := make([]string, 0, len(show.Prices))
+	for category := range show.Prices {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if !hasCategory(theater, category) {
+			return database.ErrUnknownSeatCategory.WithDetails(map[string]string{"category": category})
+		}
+	}
+
+	for _, other := range m.shows {
+		if other.TheaterID == show.TheaterID && other.ID != show.ID &&
+			other.StartTime.Before(show.EndTime.Add(database.CleaningBuffer)) &&
+			other.EndTime.After(show.StartTime.Add(-database.CleaningBuffer)) {
+			return database.ErrShowOverlap.WithDetails(map[string]int64{"show_id": other.ID})
+		}
+	}
+
+	// The stored show gets its own copy of the price table
+	if show.Prices != nil {
+		prices := make(map[string]float64, len(show.Prices))
+		for category, price := range show.Prices {
+			prices[category] = price
+		}
+		show.Prices = prices
+	}
+
+	now := time.Now()
+	show.UpdatedAt = now
+	if show.ID == 0 {
+		m.nextShowID++
+		show.ID = m.nextShowID
+		show.CreatedAt = now
+		copied := *show
+		m.shows = append(m.shows, &copied)
+		return nil
+	}
+
+	stored := m.show(show.ID)
+	show.CreatedAt = stored.CreatedAt
+	*stored = *show
+	return nil
+}
+
+func (m *Memory) theater(theaterID int64) *models.Theater {
+	for _, theater := range m.theaters {
+		if theater.ID == theaterID {
+			return theater
+		}
+	}
+	return nil
+}
+
+func hasCategory(theater *models.Theater, code string) bool {
+	for _, category := range theater.Categories {
+		if category.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTheaterUnused mirrors the database check before theater seats are
+// removed
+func (m *Memory) checkTheaterUnused(theaterID int64) error {
+	if m.theater(theaterID) == nil {
+		return database.ErrTheaterNotFound
+	}
+	for _, show := range m.shows {
+		if show.TheaterID == theaterID {
+			return database.ErrTheaterInUse
+		}
+	}
+	return nil
+}
+
+// seatLayout returns the seat positions and categories of seatMap like
+// database.Store reads them
+func seatLayout(seatMap *models.SeatMap) ([]models.SeatPosition, []models.SeatCategory, map[models.SeatPosition]string, error) {
+	positions, err := seatMap.Positions()
+	if err != nil {
+		return nil, nil, nil, database.ErrInvalidSeatMap.WithDetails(err.Error())
+	}
+	categories, codes, err := seatMap.SeatCategories()
+	if err != nil {
+		return nil, nil, nil, database.ErrInvalidSeatMap.WithDetails(err.Error())
+	}
+	return positions, categories, codes, nil
+}
+
+// addSeats adds a seat at each position, in the category codes gives it,
+// and sets the theater's capacity and categories
+func (m *Memory) addSeats(theater *models.Theater, positions []models.SeatPosition, categories []models.SeatCategory, codes map[models.SeatPosition]string) {
+	now := time.Now()
+	for _, p := range positions {
+		category, ok := codes[p]
+		if !ok {
+			category = models.StandardCategory.Code
+		}
+		m.nextSeatID++
+		m.seats = append(m.seats, &models.Seat{
+			ID:         m.nextSeatID,
+			TheaterID:  theater.ID,
+			RowNumber:  p.Row,
+			SeatNumber: p.Seat,
+			Category:   category,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	theater.Capacity = len(positions)
+	theater.Categories = categories
+	theater.UpdatedAt = now
+}
+
+func (m *Memory) removeSeats(theaterID int64) {
+	seats := m.seats[:0]
+	for _, seat := range m.seats {
+		if seat.TheaterID != theaterID {
+			seats = append(seats, seat)
+		}
+	}
+	m.seats = seats
+}
+
+func (m *Memory) show(showID int64) *models.Show {
+	for _, show := range m.shows {
+		if show.ID == showID {
+			return show
+		}
+	}
+	return nil
+}
+
+func (m *Memory) seat(seatID int64) *models.Seat {
+	for _, seat := range m.seats {
+		if seat.ID == seatID {
+			return seat
+		}
+	}
+	return nil
+}
+
+func (m *Memory) order(orderID int64) *models.Order {
+	for _, order := range m.orders {
+		if order.ID == orderID {
+			return order
+		}
+	}
+	return nil
+}
+
+// capturedPayment returns the order's latest captured payment, or nil
+func (m *Memory) capturedPayment(orderID int64) *models.Payment {
+	var captured *models.Payment
+	for _, payment := range m.payments {
+		if payment.OrderID == orderID && payment.Status == models.PaymentCaptured {
+			captured = payment
+		}
+	}
+	return captured
+}
+
+func (m *Memory) token(hash string) *memoryToken {
+	for _, token := range m.tokens {
+		if token.hash == hash {
+			return token
+		}
+	}
+	return nil
+}
+
+func (m *Memory) revokeFamily(familyID string) {
+	for _, token := range m.tokens {
+		if token.familyID == familyID {
+			token.revoked = true
+		}
+	}
+}
+
+// occupiedSeats maps the seats of a show taken by confirmed bookings to
+// "booked" and those taken by live holds to "unavailable"
+func (m *Memory) occupiedSeats(showID int64) map[int64]string {
+	occupied := make(map[int64]string)
+	for _, order := range m.orders {
+		if order.ShowID != showID {
+			continue
+		}
+		for _, b := range order.Items {
+			switch {
+			case b.Status == "confirmed":
+				occupied[b.SeatID] = "booked"
+			case b.Status == "pending" && !holdExpired(order):
+				occupied[b.SeatID] = "unavailable"
+			}
+		}
+	}
+	return occupied
+}
+
+// validateBooking checks a booking request the way database.Store does and
+// returns the show
+func (m *Memory) validateBooking(showID int64, seatIDs []int64) (*models.Show, error) {
+	seen := make(map[int64]bool, len(seatIDs))
+	for _, seatID := range seatIDs {
+		if seen[seatID] {
+			return nil, database.ErrDuplicateSeatInRequest.WithDetails(map[string]int64{"seat_id": seatID})
+		}
+		seen[seatID] = true
+	}
+
+	show := m.show(showID)
+	if show == nil {
+		return nil, database.ErrShowNotFound
+	}
+	if !show.StartTime.After(time.Now()) {
+		return nil, database.ErrShowStarted
+	}
+
+	for _, seatID := range seatIDs {
+		if seat := m.seat(seatID); seat == nil || seat.TheaterID != show.TheaterID {
+			return nil, database.ErrSeatNotInTheater.WithDetails(map[string]int64{"seat_id": seatID})
+		}
+	}
+	return show, nil
+}
+
+// quoteSeats prices seats of a validated booking request like
+// database.Store does
+func (m *Memory) quoteSeats(show *models.Show, seatIDs []int64) *models.Quote {
+	ctx := pricing.Context{Start: show.StartTime, Now: time.Now()}
+	if theater := m.theater(show.TheaterID); theater != nil && theater.Capacity > 0 {
+		ctx.Occupancy = float64(len(m.occupiedSeats(show.ID))) / float64(theater.Capacity)
+	}
+
+	quote := &models.Quote{ShowID: show.ID}
+	for _, seatID := range seatIDs {
+		category := m.seat(seatID).Category
+		seat := models.SeatQuote{SeatID: seatID, Category: category, BasePrice: show.PriceFor(category)}
+		seat.Price, seat.Adjustments = m.pricing.Price(seat.BasePrice, ctx)
+		quote.Seats = append(quote.Seats, seat)
+		quote.Total += seat.Price
+	}
+	quote.Total = math.Round(quote.Total*100) / 100
+	return quote
+}
+
+// createOrder validates a booking request the way database.Store does and
+// stores a pending order that expires after ttl, with one item per seat,
+// redeeming promoCode on it unless that is empty
+func (m *Memory) createOrder(userID, showID int64, seatIDs []int64, promoCode string, ttl time.Duration) (*models.Order, error) {
+	show, err := m.validateBooking(showID, seatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lapsed holds give up their seats, like database.Store expiring them
+	for _, order := range m.orders {
+		if order.ShowID == showID && order.Status == "pending" && holdExpired(order) {
+			m.setStatus(order, "pending", "expired")
+		}
+	}
+
+	occupied := m.occupiedSeats(showID)
+	for _, seatID := range seatIDs {
+		if occupied[seatID] != "" {
+			return nil, database.ErrSeatUnavailable.WithDetails(map[string]int64{"seat_id": seatID})
+		}
+	}
+	quote := m.quoteSeats(show, seatIDs)
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	var promo *models.PromoCode
+	if promoCode != "" {
+		if promo, err = m.checkPromoCode(promoCode, userID, show, len(seatIDs)); err != nil {
+			return nil, err
+		}
+	}
+
+	order := &models.Order{
+		ID:        int64(len(m.orders) + 1),
+		UserID:    userID,
+		ShowID:    showID,
+		Status:    "pending",
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if promo != nil {
+		order.PromoCode = promo.Code
+		order.Discount = promo.Discount(quote.Total)
+	}
+
+	for i, seatID := range seatIDs {
+		m.nextBookingID++
+		order.Items = append(order.Items, models.Booking{
+			ID:        m.nextBookingID,
+			OrderID:   order.ID,
+			ShowID:    showID,
+			SeatID:    seatID,
+			UserID:    userID,
+			Status:    "pending",
+			Price:     quote.Seats[i].Price,
+			ExpiresAt: order.ExpiresAt,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	m.orders = append(m.orders, order)
+	m.events.Publish(models.SeatEvent{ShowID: showID, SeatIDs: seatIDs, State: models.SeatHeld})
+	return order, nil
+}
+
+// checkPromoCode finds a promo code and checks it the way database.Store
+// does before redeeming it
+func (m *Memory) checkPromoCode(code string, userID int64, show *models.Show, seats int) (*models.PromoCode, error) {
+	promo := m.promoByCode(code)
+	if promo == nil || !promo.ValidAt(time.Now()) {
+		return nil, database.ErrPromoCodeInvalid
+	}
+	if promo.ShowID != nil && *promo.ShowID != show.ID {
+		return nil, database.ErrPromoCodeNotApplicable.WithDetails(map[string]int64{"show_id": *promo.ShowID})
+	}
+	if promo.MovieID != nil && *promo.MovieID != show.MovieID {
+		return nil, database.ErrPromoCodeNotApplicable.WithDetails(map[string]int64{"movie_id": *promo.MovieID})
+	}
+	if seats < promo.MinSeats {
+		return nil, database.ErrPromoCodeNotApplicable.WithDetails(map[string]int{"min_seats": promo.MinSeats})
+	}
+
+	usage := m.promoUsage(promo)
+	byUser := 0
+	for _, r := range usage.Orders {
+		if r.UserID == userID && (r.Status == "pending" || r.Status == "confirmed") {
+			byUser++
+		}
+	}
+	if promo.MaxRedemptions > 0 && usage.Redemptions >= promo.MaxRedemptions {
+		return nil, database.ErrPromoCodeExhausted
+	}
+	if promo.MaxPerUser > 0 && byUser >= promo.MaxPerUser {
+		return nil, database.ErrPromoCodeExhausted.WithDetails(map[string]int{"max_per_user": promo.MaxPerUser})
+	}
+	return promo, nil
+}
+
+// promoByCode returns the promo code a customer typed as code, or nil
+func (m *Memory) promoByCode(code string) *models.PromoCode {
+	code = models.NormalizePromoCode(code)
+	for _, promo := range m.promos {
+		if promo.Code == code {
+			return promo
+		}
+	}
+	return nil
+}
+
+// promoUsage reports on promo from the orders it was redeemed on, like
+// database.Store.GetPromoCodeUsage
+func (m *Memory) promoUsage(promo *models.PromoCode) *models.PromoCodeUsage {
+	usage := &models.PromoCodeUsage{PromoCode: *promo, Orders: []models.PromoRedemption{}}
+	users := make(map[int64]bool)
+	for i := len(m.orders) - 1; i >= 0; i-- {
+		order := m.orders[i]
+		if order.PromoCode != promo.Code {
+			continue
+		}
+		r := models.PromoRedemption{
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			ShowID:    order.ShowID,
+			Status:    order.Status,
+			Discount:  order.Discount,
+			CreatedAt: order.CreatedAt,
+		}
+		if r.Status == "pending" && holdExpired(order) {
+			r.Status = "expired"
+		}
+		if r.Status == "pending" || r.Status == "confirmed" {
+			usage.Redemptions++
+			usage.TotalDiscount += r.Discount
+			users[r.UserID] = true
+		}
+		usage.Orders = append(usage.Orders, r)
+	}
+	usage.Users = len(users)
+	usage.TotalDiscount = math.Round(usage.TotalDiscount*100) / 100
+	return usage
+}
+
+// ownedOrder returns a confirmed order owned by userID, mirroring the
+// checks database.Store makes before cancelling
+func (m *Memory) ownedOrder(orderID, userID int64) (*models.Order, error) {
+	order := m.order(orderID)
+	if order == nil {
+		return nil, database.ErrBookingNotFound
+	}
+	if order.UserID != userID {
+		return nil, database.ErrBookingNotOwned
+	}
+	if order.Status != "confirmed" {
+		return nil, database.ErrBookingNotFound
+	}
+	return order, nil
+}
+
+// setStatus moves the order and its items in status from to status to
+// and publishes the new state of their seats
+func (m *Memory) setStatus(order *models.Order, from, to string) {
+	now := time.Now()
+	order.Status = to
+	order.UpdatedAt = now
+	event := models.SeatEvent{ShowID: order.ShowID, State: models.SeatReleased}
+	if to == "confirmed" {
+		event.State = models.SeatBooked
+	}
+	for i := range order.Items {
+		if order.Items[i].Status == from {
+			order.Items[i].Status = to
+			order.Items[i].UpdatedAt = now
+			event.SeatIDs = append(event.SeatIDs, order.Items[i].SeatID)
+		}
+	}
+	m.events.Publish(event)
+}
+
+// holdExpired reports whether a pending order's hold has lapsed
+func holdExpired(order *models.Order) bool {
+	return order.ExpiresAt != nil && !order.ExpiresAt.After(time.Now())
+}
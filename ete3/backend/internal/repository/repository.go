@@ -0,0 +1,190 @@
+// Package repository defines the storage interfaces the HTTP handlers
+// depend on. database.Store implements them on SQLite or PostgreSQL and
+// Memory is an in-memory fake for tests.
+package repository
+
+import (
+	"ete3/internal/database"
+	"ete3/internal/models"
+	"time"
+)
+
+// MovieRepository stores the movie catalogue
+type MovieRepository interface {
+	// GetMovies returns a page of the movies matching query, or
+	// database.ErrInvalidCursor if its cursor can't be used
+	GetMovies(query *models.MovieQuery) (*models.MoviePage, error)
+	// GetMovieByID returns database.ErrMovieNotFound for unknown IDs
+	GetMovieByID(movieID int64) (*models.Movie, error)
+	// CreateMovie stores the movie and schedules shows for it in the same
+	// transaction, as CreateShows does; shows may be empty
+	CreateMovie(movie *models.Movie, shows []models.Show) error
+	// UpdateMovie returns database.ErrMovieNotFound for unknown IDs
+	UpdateMovie(movie *models.Movie) error
+}
+
+// TheaterRepository stores theaters and their seats
+type TheaterRepository interface {
+	GetTheaters() ([]models.Theater, error)
+	// GetTheaterByID returns database.ErrTheaterNotFound for unknown IDs
+	GetTheaterByID(theaterID int64) (*models.Theater, error)
+	GetAllSeatsForTheater(theaterID int64) ([]models.Seat, error)
+	// CreateTheater returns database.ErrInvalidSeatMap for a bad seat map
+	CreateTheater(theater *models.Theater, seatMap *models.SeatMap) error
+	// UpdateTheater returns database.ErrTheaterNotFound for unknown IDs
+	UpdateTheater(theater *models.Theater) error
+	// DeleteTheater and SetSeatMap return database.ErrTheaterInUse once
+	// shows are scheduled in the theater
+	DeleteTheater(theaterID int64) error
+	SetSeatMap(theaterID int64, seatMap *models.SeatMap) (*models.Theater, error)
+}
+
+// ShowRepository stores scheduled screenings
+type ShowRepository interface {
+	// GetShowsByMovie returns the movie's shows that haven't started yet
+	GetShowsByMovie(movieID int64) ([]models.Show, error)
+	// GetShows returns the shows in the query's window that haven't
+	// started, by start time, with their movie, theater and free seats
+	GetShows(query *models.ShowQuery) ([]models.ShowListing, error)
+	// GetShowByID returns database.ErrShowNotFound for unknown IDs
+	GetShowByID(showID int64) (*models.Show, error)
+	// CreateShow and UpdateShow set the show's end time from the movie and
+	// return database.ErrShowOverlap if the theater is busy, counting
+	// database.CleaningBuffer between shows. UpdateShow returns
+	// database.ErrShowHasBookings if the show has held or booked seats and
+	// its movie, theater or start time would change.
+	CreateShow(show *models.Show) error
+	UpdateShow(show *models.Show) error
+	// DeleteShow returns database.ErrShowHasBookings while seats are held
+	// or booked, and database.ErrShowHasPayments if any of its orders was
+	// paid or refunded
+	DeleteShow(showID int64) error
+	// PreviewShows reports which of shows CreateShows would refuse, without
+	// storing anything
+	PreviewShows(shows []models.Show) ([]models.ScheduledShow, error)
+	// CreateShows stores all of shows or, returning
+	// database.ErrScheduleConflict, none of them
+	CreateShows(shows []models.Show) error
+}
+
+// SeatRepository reports seat availability for shows
+type SeatRepository interface {
+	GetAvailableSeats(showID int64) ([]models.Seat, error)
+	// GetTheaterLayout returns database.ErrShowNotFound for unknown shows
+	GetTheaterLayout(showID int64) (*models.TheaterLayout, error)
+}
+
+// BookingRepository books, holds and cancels seats. Implementations must
+// never let two active bookings share a seat of the same show and report
+// that as database.ErrSeatUnavailable. Booked seats are priced like
+// QuoteSeats prices them.
+type BookingRepository interface {
+	// QuoteSeats prices seats without checking that they are free
+	QuoteSeats(showID int64, seatIDs []int64) (*models.Quote, error)
+	// CreateHold redeems promoCode, unless it is empty, atomically with
+	// the hold and fails with one of the database.ErrPromoCode errors if
+	// it can't be redeemed. Seats are only booked once ConfirmHold sees
+	// the hold paid for.
+	CreateHold(userID, showID int64, seatIDs []int64, promoCode string, ttl time.Duration) (*models.BookingResponse, error)
+	// ConfirmHold returns database.ErrPaymentRequired unless captured
+	// payments cover the hold's total
+	ConfirmHold(holdID, userID int64) (*models.BookingResponse, error)
+	ReleaseHold(holdID, userID int64) error
+	// GetOrder returns database.ErrBookingNotFound for unknown orders
+	GetOrder(orderID, userID int64) (*models.Order, error)
+	GetBookings(userID int64) ([]models.Booking, error)
+	// CancelBooking records the refund due under the refund policy, or
+	// under override when staff cancel, and leaves it pending if there is
+	// money to return through the payment provider
+	CancelBooking(orderID, userID int64, override *models.RefundOverride) (*models.Refund, error)
+	// CancelBookingSeat refunds the seat's share of the payment the same
+	// way, so cancelling every seat refunds as much as cancelling the order
+	CancelBookingSeat(orderID, seatID, userID int64, override *models.RefundOverride) (*models.Refund, error)
+}
+
+// PromoRepository stores promo codes. They are redeemed through the
+// BookingRepository.
+type PromoRepository interface {
+	// CreatePromoCode returns database.ErrPromoCodeExists for a taken code
+	CreatePromoCode(promo *models.PromoCode) error
+	GetPromoCodes() ([]models.PromoCodeUsage, error)
+	// GetPromoCodeUsage returns database.ErrPromoCodeNotFound for unknown
+	// IDs
+	GetPromoCodeUsage(promoID int64) (*models.PromoCodeUsage, error)
+}
+
+// PaymentRepository records payment attempts for orders and the refunds
+// of cancelled ones
+type PaymentRepository interface {
+	CreatePayment(payment *models.Payment) error
+	// UpdatePayment, GetPayment and GetPaymentByRef return
+	// database.ErrPaymentNotFound for unknown payments
+	UpdatePayment(payment *models.Payment) error
+	GetPayment(paymentID int64) (*models.Payment, error)
+	GetPaymentByRef(provider, ref string) (*models.Payment, error)
+	// UpdateRefund returns database.ErrRefundNotFound for unknown refunds
+	UpdateRefund(refund *models.Refund) error
+}
+
+// UserRepository stores accounts and their refresh token sessions
+type UserRepository interface {
+	// CreateUser returns database.ErrUserExists for a taken username or email
+	CreateUser(req *models.RegisterRequest, role string) error
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(userID int64) (*models.User, error)
+	// SetUserRole returns database.ErrUserNotFound for unknown users
+	SetUserRole(userID int64, role string) (*models.User, error)
+
+	CreateRefreshToken(userID int64, familyID, tokenHash string, expiresAt time.Time) error
+	// RotateRefreshToken returns database.ErrRefreshTokenReused when oldHash
+	// was already rotated or revoked
+	RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (int64, error)
+	RevokeRefreshToken(tokenHash string) error
+}
+
+// Repositories bundles the repositories the handlers need
+type Repositories struct {
+	Movies   MovieRepository
+	Theaters TheaterRepository
+	Shows    ShowRepository
+	Seats    SeatRepository
+	Bookings BookingRepository
+	Promos   PromoRepository
+	Payments PaymentRepository
+	Users    UserRepository
+}
+
+// SQL returns repositories backed by store
+func SQL(store *database.Store) Repositories {
+	return Repositories{
+		Movies:   store,
+		Theaters: store,
+		Shows:    store,
+		Seats:    store,
+		Bookings: store,
+		Promos:   store,
+		Payments: store,
+		Users:    store,
+	}
+}
+
+// Compile-time checks that both implementations satisfy every repository
+var (
+	_ MovieRepository   = (*database.Store)(nil)
+	_ TheaterRepository = (*database.Store)(nil)
+	_ ShowRepository    = (*database.Store)(nil)
+	_ SeatRepository    = (*database.Store)(nil)
+	_ BookingRepository = (*database.Store)(nil)
+	_ PromoRepository   = (*database.Store)(nil)
+	_ PaymentRepository = (*database.Store)(nil)
+	_ UserRepository    = (*database.Store)(nil)
+
+	_ MovieRepository   = (*Memory)(nil)
+	_ TheaterRepository = (*Memory)(nil)
+	_ ShowRepository    = (*Memory)(nil)
+	_ SeatRepository    = (*Memory)(nil)
+	_ BookingRepository = (*Memory)(nil)
+	_ PromoRepository   = (*Memory)(nil)
+	_ PaymentRepository = (*Memory)(nil)
+	_ UserRepository    = (*Memory)(nil)
+)
@@ -1,71 +1,339 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"ete3/internal/config"
 	"ete3/internal/database"
 	"ete3/internal/handlers"
+	"ete3/internal/live"
+	"ete3/internal/models"
+	"ete3/internal/payments"
+	"ete3/internal/pricing"
+	"ete3/internal/refunds"
+	"ete3/internal/repository"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/term"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "create-admin":
+			createAdmin(os.Args[2:])
+			return
+		case "migrate":
+			migrate(os.Args[2:])
+			return
+		}
+	}
+
 	fmt.Println("Starting cinema booking application...")
 
+	// Load configuration
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err == nil {
+		err = cfg.ValidateServer()
+	}
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	// ValidateServer only lets these through with server.development enabled
+	if cfg.JWT.UsesDefaultSecret() {
+		log.Println("DEVELOPMENT MODE: signing tokens with the publicly known default JWT secret")
+	}
+	if cfg.Payments.WebhookSecret == "" {
+		log.Println("DEVELOPMENT MODE: payment webhook secret is not set, webhooks can be forged")
+	}
+	if cfg.Payments.Provider == "fake" {
+		log.Println("DEVELOPMENT MODE: payments go through the fake gateway, nobody is charged")
+	}
+
 	// Initialize database
 	fmt.Println("Initializing database...")
-	database.InitDB()
+	store := database.InitDB(cfg.Database.DSN)
+	defer store.Close()
 	fmt.Println("Database initialized successfully")
+	if !store.FullTextSearch() {
+		log.Println("WARNING: movie search is DEGRADED: no full-text index, so words match with LIKE and sort=relevance falls back to title order")
+	}
+
+	// Price seats with the configured rules, in the server's time zone
+	engine, err := pricing.New(cfg.Pricing.Rules, time.Local)
+	if err != nil {
+		log.Fatal("Invalid pricing rules: ", err)
+	}
+	store.SetPricing(engine)
+
+	policy, err := refunds.New(cfg.Refunds.Tiers)
+	if err != nil {
+		log.Fatal("Invalid refund tiers: ", err)
+	}
+	store.SetRefundPolicy(policy)
+
+	// Push seat changes to live seat maps as bookings commit
+	hub := live.NewHub(live.DefaultBuffer)
+	store.SetSeatEvents(hub)
+
+	// Release expired seat holds in the background
+	stopReaper := store.StartHoldReaper(time.Minute)
+	defer stopReaper()
+
+	h := handlers.New(repository.SQL(store), handlers.NewJWTConfig(cfg.JWT))
+	h.SetPaymentProvider(payments.NewFake(cfg.Payments.WebhookSecret))
+	h.SetSeatEvents(hub)
 
 	// Create Gin router
 	fmt.Println("Setting up Gin router...")
 	r := gin.Default()
+	r.Use(handlers.RequestID(), handlers.ErrorHandler())
 
 	// Configure CORS middleware
-	fmt.Println("Configuring CORS to allow all origins...")
+	fmt.Println("Configuring CORS for origins:", strings.Join(cfg.Server.CORSOrigins, ", "))
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.Server.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
 	}))
 
 	// Serve static files from docs directory
-	r.Static("/docs", "./docs")
-	r.StaticFile("/openapi.yaml", "./docs/openapi.yaml")
+	r.Static("/docs", cfg.Server.DocsDir)
+	r.StaticFile("/openapi.yaml", filepath.Join(cfg.Server.DocsDir, "openapi.yaml"))
 
 	// API routes
 	api := r.Group("/api")
 	{
+		// Auth routes
+		auth := api.Group("/auth")
+		{
+			auth.POST("/register", h.Register)
+			auth.POST("/login", h.Login)
+			auth.POST("/refresh", h.Refresh)
+			auth.POST("/logout", h.Logout)
+		}
+
 		// Cinema routes
 		cinema := api.Group("/cinema")
 		{
 			// Movies
-			cinema.GET("/movies", handlers.GetMovies)
-			cinema.GET("/movies/:id", handlers.GetMovie)
-			cinema.POST("/movies", handlers.CreateMovie)
-			cinema.PUT("/movies/:id", handlers.UpdateMovie)
-			cinema.GET("/movies/:id/shows", handlers.GetShowsByMovie)
+			cinema.GET("/movies", h.GetMovies)
+			cinema.GET("/movies/:id", h.GetMovie)
+			cinema.GET("/movies/:id/shows", h.GetShowsByMovie)
+
+			// Catalogue administration
+			admin := cinema.Group("", h.AuthMiddleware(), handlers.RequireRole(models.RoleAdmin))
+			{
+				admin.POST("/movies", h.CreateMovie)
+				admin.PUT("/movies/:id", h.UpdateMovie)
+
+				admin.POST("/theaters", h.CreateTheater)
+				admin.PUT("/theaters/:id", h.UpdateTheater)
+				admin.DELETE("/theaters/:id", h.DeleteTheater)
+				admin.PUT("/theaters/:id/seats", h.SetSeatMap)
+
+				admin.POST("/shows", h.CreateShow)
+				admin.PUT("/shows/:id", h.UpdateShow)
+				admin.DELETE("/shows/:id", h.DeleteShow)
+
+				admin.POST("/schedules/preview", h.PreviewSchedule)
+				admin.POST("/schedules", h.CommitSchedule)
+
+				admin.POST("/promo-codes", h.CreatePromoCode)
+				admin.GET("/promo-codes", h.GetPromoCodes)
+				admin.GET("/promo-codes/:id", h.GetPromoCodeUsage)
+			}
+
+			// Theaters
+			cinema.GET("/theaters", h.GetTheaters)
+			cinema.GET("/theaters/:id", h.GetTheater)
+			cinema.GET("/theaters/:id/seats", h.GetTheaterSeats)
 
 			// Shows and Seats
-			cinema.GET("/shows/:id/seats", handlers.GetAvailableSeats)
-			cinema.GET("/shows/:id/layout", handlers.GetTheaterLayout)
+			cinema.GET("/shows", h.GetShows)
+			cinema.GET("/shows/:id/seats", h.GetAvailableSeats)
+			cinema.GET("/shows/:id/layout", h.GetTheaterLayout)
+			cinema.GET("/shows/:id/quote", h.QuoteSeats)
+			cinema.GET("/shows/:id/live", h.LiveSeats)
 
 			// Bookings
-			bookings := cinema.Group("/bookings")
+			bookings := cinema.Group("/bookings", h.AuthMiddleware())
 			{
-				bookings.POST("", handlers.CreateBooking)
-				bookings.GET("", handlers.GetBookings)
-				bookings.DELETE("/:id", handlers.CancelBooking)
+				bookings.POST("", h.CreateBooking)
+				bookings.GET("", h.GetBookings)
+				bookings.DELETE("/:id", h.CancelBooking)
+				bookings.DELETE("/:id/seats/:seatId", h.CancelBookingSeat)
+				bookings.POST("/holds", h.CreateHold)
+				bookings.POST("/holds/:id/confirm", h.ConfirmHold)
 			}
 		}
+
+		// Account administration
+		users := api.Group("/admin/users", h.AuthMiddleware(), handlers.RequireRole(models.RoleAdmin))
+		{
+			users.PUT("/:id/role", h.SetUserRole)
+		}
+
+		// Payment provider callbacks, authenticated by their signature
+		api.POST("/payments/webhook", h.PaymentWebhook)
 	}
 
 	// Start server
-	fmt.Println("Starting server on port 8080...")
-	if err := r.Run(":8080"); err != nil {
+	fmt.Println("Starting server on", cfg.Server.Addr+"...")
+	if err := r.Run(cfg.Server.Addr); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+// createAdmin bootstraps an admin account from the command line. The
+// password is read from ADMIN_PASSWORD, or else from standard input so it
+// never shows up in the process list or shell history:
+//
+//	go run . create-admin -username admin -email admin@example.com
+//	ADMIN_PASSWORD=secret go run . create-admin -username admin -email admin@example.com
+//
+// Admins grant the staff role with PUT /api/admin/users/{id}/role.
+func createAdmin(args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "admin username")
+	email := fs.String("email", "", "admin email")
+	fs.Parse(args)
+
+	if *username == "" || *email == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal("Failed to read password: ", err)
+	}
+	if len(password) < 6 {
+		log.Fatal("Password must be at least 6 characters")
+	}
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	store := database.InitDB(cfg.Database.DSN)
+	defer store.Close()
+	err = store.CreateUser(&models.RegisterRequest{
+		Username: *username,
+		Email:    *email,
+		Password: password,
+	}, models.RoleAdmin)
+	if err != nil {
+		log.Fatal("Failed to create admin: ", err)
+	}
+	fmt.Printf("Admin user %q created\n", *username)
+}
+
+// readPassword returns ADMIN_PASSWORD if it's set. Otherwise it prompts for
+// the password twice without echoing it on a terminal, or reads the first
+// line of standard input when it's piped in.
+func readPassword() (string, error) {
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	repeated, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(repeated) {
+		return "", errors.New("passwords don't match")
+	}
+	return string(password), nil
+}
+
+// migrate applies, reverts or lists schema migrations:
+//
+//	go run . migrate up      apply every pending migration
+//	go run . migrate down    revert the latest applied migration
+//	go run . migrate status  list migrations and when they were applied
+func migrate(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	store, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		log.Fatal("Failed to open database: ", err)
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := store.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+	case "down":
+		reverted, err := store.MigrateDown()
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		if reverted == nil {
+			fmt.Println("No migrations to revert")
+			return
+		}
+		fmt.Printf("Reverted %04d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := store.MigrationStatus()
+		if err != nil {
+			log.Fatal("Failed to read migration status: ", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status")
+		os.Exit(2)
+	}
+}
@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { ShowListing, ShowQuery } from '../types';
 
 const API_URL = 'http://localhost:8080/api';
 
@@ -10,9 +11,16 @@ const api = axios.create({
 });
 
 // Movie API
+// getMovies follows next_cursor through every page of the catalogue
 export const getMovies = async () => {
-  const response = await api.get('/cinema/movies');
-  return response.data;
+  const movies = [];
+  let cursor: string | undefined;
+  do {
+    const response = await api.get('/cinema/movies', { params: { limit: 100, cursor } });
+    movies.push(...response.data.movies);
+    cursor = response.data.next_cursor;
+  } while (cursor);
+  return movies;
 };
 
 export const getMovie = async (id: number) => {
@@ -36,6 +44,12 @@ export const getMovieShows = async (movieId: number) => {
   return response.data;
 };
 
+// getShows lists the shows of a day across all movies that haven't started
+export const getShows = async (query: ShowQuery = {}): Promise<ShowListing[]> => {
+  const response = await api.get('/cinema/shows', { params: query });
+  return response.data;
+};
+
 // Seat API
 export const getShowSeats = async (showId: number) => {
   try {
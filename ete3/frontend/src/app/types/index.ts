@@ -20,6 +20,24 @@ export interface Show {
   updated_at: string;
 }
 
+export interface ShowListing extends Show {
+  movie: Movie;
+  theater: {
+    id: number;
+    name: string;
+    capacity: number;
+  };
+  seats_left: number;
+}
+
+export interface ShowQuery {
+  date?: string; // YYYY-MM-DD, today if omitted
+  from?: string; // HH:MM
+  to?: string; // HH:MM
+  theater_id?: number;
+  movie_id?: number;
+}
+
 export interface Seat {
   id: number;
   theater_id: number;